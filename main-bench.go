@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"os"
 	"time"
 
 	vc "github.com/hyperproofs/hyperproofs-go/vcs"
@@ -13,7 +14,10 @@ func BenchmarkVCSCommit(L uint8, txnLimit uint64) string {
 	N := uint64(1) << L
 	K := txnLimit
 	vcs := vc.VCS{}
-	vcs.KeyGenLoad(16, L, FOLDER, K)
+	if err := vcs.KeyGenLoad(16, L, FOLDER, K); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
 
 	aFr := vc.GenerateVector(N)
 	dt := time.Now()
@@ -30,7 +34,10 @@ func BenchmarkVCSOpenAll(L uint8, txnLimit uint64) string {
 	N := uint64(1) << L
 	K := txnLimit
 	vcs := vc.VCS{}
-	vcs.KeyGenLoad(16, L, FOLDER, K)
+	if err := vcs.KeyGenLoad(16, L, FOLDER, K); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
 
 	aFr := vc.GenerateVector(N)
 	dt := time.Now()
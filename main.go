@@ -4,6 +4,7 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"strconv"
 	"testing"
 	"time"
 
@@ -35,6 +36,12 @@ func main() {
 	} else {
 		if args[1] == "1" {
 			snarks_verifier()
+		} else if args[1] == "derive" {
+			hyperDeriveKeys(args[2:])
+		} else if args[1] == "inspect" {
+			hyperInspectKeys(args[2:])
+		} else if args[1] == "plan" {
+			hyperPlan(args[2:])
 		} else {
 			Benchmark() // Uncomment this benchmark Commit and OpenAll.
 		}
@@ -48,22 +55,185 @@ func hyperGenerateKeys(L uint8, fake bool) *vcs.VCS {
 
 	fmt.Println("L:", L, "N:", N)
 	folderPath := fmt.Sprintf("pkvk-%02d", L)
+	var err error
 	if fake {
-		vcs.KeyGenFake(16, L, folderPath, 1<<12)
+		err = vcs.KeyGenFake(16, L, folderPath, 1<<12)
 	} else {
-		vcs.KeyGen(16, L, folderPath, 1<<12)
+		err = vcs.KeyGen(16, L, folderPath, 1<<12)
+	}
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
 	}
 
 	fmt.Println("KeyGen ... Done")
 	return &vcs
 }
 
+// Usage: derive <ell> <source folder> <destination folder>
+// Writes a standalone ell folder from a folder with a larger ell, e.g., pkvk-20 from pkvk-30.
+func hyperDeriveKeys(args []string) {
+
+	if len(args) != 3 {
+		fmt.Println("Usage: derive <ell> <source folder> <destination folder>")
+		os.Exit(1)
+	}
+	L, err := strconv.ParseUint(args[0], 10, 8)
+	if err != nil {
+		fmt.Println("Bad ell:", args[0])
+		os.Exit(1)
+	}
+
+	vcs := vcs.VCS{}
+	if err := vcs.KeyGenDerive(16, uint8(L), args[1], args[2]); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	fmt.Println("KeyGenDerive ... Done")
+}
+
+// Usage: inspect [-check] [-spot count] [-txn limit] <folder>
+// Prints what the key folder holds and the memory needed to serve it. With -check, the keys are also verified.
+func hyperInspectKeys(args []string) {
+
+	flags := flag.NewFlagSet("inspect", flag.ExitOnError)
+	runChecks := flags.Bool("check", false, "Verify the checksums, run ValidateParams and spot-check UPK paths")
+	spot := flags.Int("spot", 16, "Number of random UPK paths to check with VerifyUPK")
+	txnLimit := flags.Uint64("txn", 1<<12, "Block size for the memory estimates")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		fmt.Println("Usage: inspect [-check] [-spot count] [-txn limit] <folder>")
+		os.Exit(1)
+	}
+	folder := flags.Arg(0)
+
+	keys := vcs.VCS{}
+	info, err := keys.InspectKeys(folder, *txnLimit)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	fmt.Println("Folder:", folder)
+	fmt.Println("ell:", info.L, "N:", info.N, "Shards:", info.NumShards)
+	fmt.Println("Manifest:", info.HasManifest, "Public parameters:", info.HasPublicParams, "Trapdoors:", info.HasTrapdoors)
+	fmt.Println("PRK:", info.HasPRK, "GIPA keys:", info.HasAggKeys, "MN:", info.MN, "Ceremony transcript:", info.HasTranscript)
+	if info.HasAggKeys {
+		fmt.Println("Largest block:", info.MN/uint64(info.L), "updates")
+	}
+	fmt.Println(vcs.SEP)
+	for _, file := range info.Files {
+		fmt.Printf("%-24s %s %12s\n", file.Name[1:], vcs.BoundsPrint(file.Start, file.Stop), byteSize(file.Size))
+	}
+	fmt.Printf("%-24s %21s %12s\n", "Total", "", byteSize(info.TotalSize))
+	fmt.Println(vcs.SEP)
+	fmt.Println("Estimated RAM for blocks of", *txnLimit, "updates")
+	fmt.Println("Aggregation keys:", byteSize(info.RAMAggKeys))
+	fmt.Println("Full mode:       ", byteSize(info.RAMFull), "+", byteSize(info.RAMPrk), "with PRK")
+	fmt.Println("Pruned mode:     ", byteSize(info.RAMPruned))
+
+	if *runChecks {
+		if err := keys.CheckKeys(16, info.L, folder, *spot); err != nil {
+			fmt.Println("Check failed:", err)
+			os.Exit(1)
+		}
+		fmt.Println("Check ... Done")
+	}
+}
+
+// Usage: plan [-cores n] [-memory-ell n] [-mode full|pruned|verifier] [-bench file] <ell> <txn>
+// Predicts the key sizes and the memory. With -bench, also the operation times from benchmark output, e.g., json-parse/micro-macro-1024txn.json.
+func hyperPlan(args []string) {
+
+	flags := flag.NewFlagSet("plan", flag.ExitOnError)
+	cores := flags.Uint("cores", 16, "Number of cores for KeyGen and the key loading")
+	memoryEll := flags.Uint("memory-ell", vcs.PARAM_TOO_LARGE_ELL, "Largest ell whose keys are held in memory, as Config.MemoryEll")
+	modeName := flags.String("mode", "full", "Node mode: full, pruned or verifier")
+	benchFile := flags.String("bench", "", "Output of go test -bench, plain or -json, to predict the times")
+	flags.Parse(args)
+	if flags.NArg() != 2 {
+		fmt.Println("Usage: plan [-cores n] [-memory-ell n] [-mode full|pruned|verifier] [-bench file] <ell> <txn>")
+		os.Exit(1)
+	}
+	L, err1 := strconv.ParseUint(flags.Arg(0), 10, 8)
+	txnLimit, err2 := strconv.ParseUint(flags.Arg(1), 10, 64)
+	mode, err3 := vcs.ParseNodeMode(*modeName)
+	if err1 != nil || err2 != nil || err3 != nil || *cores == 0 || *cores > 255 || *memoryEll > 255 {
+		fmt.Println("Bad arguments:", args)
+		os.Exit(1)
+	}
+
+	calib := vcs.NewCalibration()
+	if *benchFile != "" {
+		f, err := os.Open(*benchFile)
+		if err == nil {
+			var samples int
+			samples, err = calib.ReadBenchmarks(f)
+			f.Close()
+			fmt.Println("Calibrated with", samples, "benchmark results from", *benchFile)
+		}
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+
+	plan, err := vcs.Plan(uint8(L), txnLimit, uint8(*cores), uint8(*memoryEll), mode, &calib)
+	if plan.N == 0 {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	fmt.Println("ell:", plan.L, "N:", plan.N, "Block:", plan.TxnLimit, "updates", "Mode:", plan.Mode, "Cores:", plan.NCores)
+	fmt.Println("MN:", plan.MN, "Largest block:", plan.MaxTxnLimit, "Keys in files only:", plan.ParamTooLarge)
+	fmt.Println(vcs.SEP)
+	fmt.Println("Keys on disk:    ", byteSize(plan.KeySize))
+	fmt.Println("  UPK:           ", byteSize(plan.UpkSize), "PRK:", byteSize(plan.PrkSize), "Aggregation keys:", byteSize(plan.AggKeysSize))
+	fmt.Println("RAM:             ", byteSize(plan.RAM))
+	fmt.Println("  Proof tree:    ", byteSize(plan.ProofTreeRAM))
+	fmt.Println("Proof:           ", byteSize(plan.ProofSize))
+	fmt.Println("Aggregated proof:", byteSize(plan.AggProofSize))
+	if plan.Mode == vcs.PrunedNode {
+		fmt.Println("UPK per block:   ", byteSize(plan.BlockUpkSize))
+	}
+	fmt.Println(vcs.SEP)
+	if *benchFile == "" {
+		fmt.Println("Times need benchmark results. Pass -bench with the output of scripts/hyper-bench.sh")
+	}
+	for _, op := range plan.Ops {
+		if op.Calibrated {
+			fmt.Printf("%-24s %16v\n", op.Name, op.Time)
+		} else if *benchFile != "" {
+			fmt.Printf("%-24s %16s\n", op.Name, "not benchmarked")
+		}
+	}
+	if err != nil {
+		fmt.Println(vcs.SEP)
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+func byteSize(n uint64) string {
+	units := []string{"B", "KiB", "MiB", "GiB", "TiB"}
+	size := float64(n)
+	i := 0
+	for size >= 1024 && i < len(units)-1 {
+		size /= 1024
+		i++
+	}
+	return fmt.Sprintf("%.1f %s", size, units[i])
+}
+
 func hyperLoadKeys(L uint8) *vcs.VCS {
 
 	folderPath := fmt.Sprintf("pkvk-%02d", L)
 	vcs := vcs.VCS{}
 
-	vcs.KeyGenLoad(16, L, folderPath, 1<<12)
+	if err := vcs.KeyGenLoad(16, L, folderPath, 1<<12); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
 
 	fmt.Println("KeyGenLoad ... Done")
 	return &vcs
@@ -8,3 +8,4 @@ sourcedir=$(cd $scriptdir/..; pwd -P)
 
 time go test -v ./vcs -run=TestVCSPruned
 time go test -v ./vcs -run=TestVCS
+time go test -v ./verifier
@@ -1,124 +1,259 @@
 package vcs
 
 import (
+	"bufio"
+	"context"
 	"fmt"
-	"math"
-	"os"
 	"sync"
+	"time"
 
 	"github.com/alinush/go-mcl"
 )
 
 // [start, stop)
-func (vcs *VCS) PrkGen(index uint8, start uint64, stop uint64, wg *sync.WaitGroup) {
-
-	os.MkdirAll(vcs.folderPath, os.ModePerm)
-	fileName := vcs.folderPath + fmt.Sprintf(PRKNAME, index)
-	f, err := os.Create(fileName)
-	check(err)
-
-	var result mcl.G1
-	for i := start; i < stop; i++ {
-		exponent := vcs.SelectPRK(i)
-		mcl.G1Mul(&result, &vcs.G, &exponent)
-		_, err = f.Write(result.Serialize())
-		check(err)
+func (vcs *VCS) PrkGen(index uint8, start uint64, stop uint64, progress chan<- uint64) error {
+	return vcs.prkGen(context.Background(), index, start, stop, progress)
+}
+
+func (vcs *VCS) prkGen(ctx context.Context, index uint8, start uint64, stop uint64, progress chan<- uint64) error {
+
+	fileName := fmt.Sprintf(vcs.Names.PRK, index)
+	exponents := prkExponents{vcs: vcs, ratios: vcs.ratios}
+	return vcs.genShard(ctx, fileName, start, stop, progress, func(i uint64) mcl.G1 {
+		var result mcl.G1
+		exponent := exponents.Next(i)
+		vcs.gTable.Mul(&result, &exponent)
 		if !vcs.PARAM_TOO_LARGE {
 			vcs.PRK[i] = result
 		}
-		// fmt.Println(i)
-	}
-	fmt.Println("Dumped ", fileName, BoundsPrint(start, stop))
-	defer f.Close()
-	defer wg.Done()
+		return result
+	})
 }
 
-func (vcs *VCS) PrkGenDriver() {
-	fmt.Println(SEP, "Generating the PRK", SEP)
+func (vcs *VCS) PrkGenDriver() error {
+	return vcs.PrkGenDriverContext(context.Background())
+}
+
+// Same as PrkGenDriver, but stops once ctx is done. See UpkGenDriverContext.
+func (vcs *VCS) PrkGenDriverContext(ctx context.Context) error {
+	vcs.log(SEP, "Generating the PRK", SEP)
 	if !vcs.PARAM_TOO_LARGE {
 		// Actually we can avoid during Save
 		vcs.PRK = make([]mcl.G1, vcs.N) // Allocate space for PRK
 	}
-	var wg sync.WaitGroup
-	step := uint64(math.Ceil(float64(vcs.N) / float64(NFILES))) // Maximum size of each file.
-
-	start := uint64(0)
-	stop := step
-	for i := uint8(0); i < NFILES; i++ {
-		wg.Add(1)
-		go vcs.PrkGen(i, start, stop, &wg)
+	vcs.gTable = NewFixedBaseG1(&vcs.G)
+	vcs.ratios = vcs.trapdoorRatios()
+	return vcs.genShards(ctx, "PRK", vcs.Names.PRK, vcs.N, vcs.prkGen, func(j uint64, data []byte) error {
+		return vcs.PRK[j].Deserialize(data)
+	})
+}
 
-		start += step
-		stop += step
-		if (i+1)%NCORES == 0 {
-			wg.Wait()
-		}
-	}
-	wg.Wait()
+func (vcs *VCS) UpkGen(index uint8, start uint64, stop uint64, progress chan<- uint64) error {
+	return vcs.upkGen(context.Background(), index, start, stop, progress)
 }
 
-func (vcs *VCS) UpkGen(index uint8, start uint64, stop uint64, wg *sync.WaitGroup) {
+func (vcs *VCS) upkGen(ctx context.Context, index uint8, start uint64, stop uint64, progress chan<- uint64) error {
 
-	os.MkdirAll(vcs.folderPath, os.ModePerm)
-	fileName := vcs.folderPath + fmt.Sprintf(UPKNAME, index)
-	f, err := os.Create(fileName)
-	check(err)
-	// fmt.Println(fileName)
-	var result mcl.G1
-	for j := start; j < stop; j++ {
+	fileName := fmt.Sprintf(vcs.Names.UPK, index)
+	exponents := upkExponents{vcs: vcs, ratios: vcs.ratios}
+	return vcs.genShard(ctx, fileName, start, stop, progress, func(j uint64) mcl.G1 {
+		var result mcl.G1
 		i, k := IndexInTheLevel(j)
-		exponent := vcs.SelectUPK(i, k)
-		mcl.G1Mul(&result, &vcs.G, &exponent)
-		_, err = f.Write(result.Serialize())
-		check(err)
+		exponent := exponents.Next(j)
+		vcs.gTable.Mul(&result, &exponent)
 		if !vcs.PARAM_TOO_LARGE {
 			vcs.UPK[i][k] = result
 		}
+		return result
+	})
+}
 
-		// fmt.Println(i, k, exponent.IsZero(), result.IsZero(), vcs.PRK[i][k].IsZero())
-	}
-
-	fmt.Println("Dumped ", fileName, BoundsPrint(start, stop))
-	defer f.Close()
-	defer wg.Done()
+func (vcs *VCS) UpkGenDriver() error {
+	return vcs.UpkGenDriverContext(context.Background())
 }
 
-func (vcs *VCS) UpkGenDriver() {
+// Same as UpkGenDriver, but stops once ctx is done and returns ctx.Err().
+// Shards being written are removed. Completed shards stay in the checkpoint, thus KeyGenResume picks up from there.
+func (vcs *VCS) UpkGenDriverContext(ctx context.Context) error {
 
-	var wg sync.WaitGroup
 	if !vcs.PARAM_TOO_LARGE {
 		// Allocate space for UPK
 		vcs.MallocUpk()
 	}
-	fmt.Println(SEP, "Generating the UPK", SEP)
+	vcs.log(SEP, "Generating the UPK", SEP)
 
 	numUPK := (uint64(1) << (vcs.L + 1)) - 1 // Number of nodes in the UPK tree
-	step := uint64(math.Ceil(float64(numUPK) / float64(NFILES)))
-	start := uint64(0)
-	stop := step
+	vcs.gTable = NewFixedBaseG1(&vcs.G)
+	vcs.ratios = vcs.trapdoorRatios()
+	return vcs.genShards(ctx, "UPK", vcs.Names.UPK, numUPK, vcs.upkGen, func(j uint64, data []byte) error {
+		i, k := IndexInTheLevel(j)
+		return vcs.UPK[i][k].Deserialize(data)
+	})
+}
 
-	for i := uint8(0); i < NFILES; i++ {
-		// fmt.Println(i, start, stop)
-		wg.Add(1)
-		go vcs.UpkGen(i, start, stop, &wg)
+func (vcs *VCS) PrkUpkGen() error {
 
-		start += step
-		stop += step
-		stop = minUint64(stop, numUPK)
+	if err := vcs.UpkGenDriver(); err != nil {
+		return err
+	}
+	if !vcs.DISCARD_PRK {
+		vcs.log(SEP)
+		if err := vcs.PrkGenDriver(); err != nil { // Streams to disk when PARAM_TOO_LARGE
+			return err
+		}
+	}
+	vcs.log(SEP)
+	return nil
+}
 
-		if (i+1)%NCORES == 0 {
-			wg.Wait()
+// Write the points point(start), ..., point(stop - 1) to fileName in the key store.
+// The shard is written to a temporary file, which is renamed once complete. Thus, a crash never leaves a partial shard behind.
+// Number of points written is sent to progress every PROGRESS_STEP points, which is also when ctx is checked.
+// The temporary file is removed on error, e.g., once ctx is done.
+func (vcs *VCS) genShard(ctx context.Context, fileName string, start uint64, stop uint64, progress chan<- uint64, point func(uint64) mcl.G1) (err error) {
+
+	f, err := vcs.store.Create(fileName + ".tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			vcs.store.Remove(fileName + ".tmp")
 		}
+	}()
+
+	w := bufio.NewWriter(f)
+	for j := start; j < stop; j++ {
+		result := point(j)
+		if _, err = w.Write(result.Serialize()); err != nil {
+			return err
+		}
+		if (j-start+1)%PROGRESS_STEP == 0 {
+			progress <- PROGRESS_STEP
+			if err = ctx.Err(); err != nil {
+				return err
+			}
+		}
+	}
+	progress <- (stop - start) % PROGRESS_STEP
+
+	if err = w.Flush(); err != nil {
+		return err
+	}
+	if err = syncKeyFile(f); err != nil {
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	if err = vcs.store.Rename(fileName+".tmp", fileName); err != nil {
+		return err
+	}
+	vcs.log("Dumped ", fileName, BoundsPrint(start, stop))
+	return nil
+}
+
+type shardTask struct {
+	index uint8
+	ManifestEntry
+}
+
+// Generate the shards of format holding total points on up to NCores goroutines.
+// Shards recorded in the checkpoint are kept and, unless PARAM_TOO_LARGE, decoded into memory instead.
+// A kept shard that does not decode is generated again.
+// Returns the first error of the workers, or ctx.Err() once ctx is done. No new shard is started after an error.
+func (vcs *VCS) genShards(ctx context.Context, stage string, format string, total uint64,
+	gen func(context.Context, uint8, uint64, uint64, chan<- uint64) error, decode func(uint64, []byte) error) error {
+
+	done, err := vcs.LoadCheckpoint()
+	if err != nil {
+		return err
+	}
+
+	var tasks []shardTask
+	skipped := uint64(0)
+	for i := uint8(0); i < vcs.nfiles; i++ {
+		start, stop := ShardBounds(total, vcs.nfiles, i)
+		entry := ManifestEntry{Name: fmt.Sprintf(format, i), Start: start, Stop: stop}
+		if prev, ok := done[entry.Name]; ok && prev.Start == start && prev.Stop == stop {
+			size, err := vcs.store.Size(entry.Name)
+			if err == nil && uint64(size) == (stop-start)*uint64(GetG1ByteSize()) {
+				if !vcs.PARAM_TOO_LARGE && start < stop {
+					err = vcs.loadShards(ctx, []ManifestEntry{entry}, decode)
+				}
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				if err == nil {
+					skipped += stop - start
+					vcs.log("Resumed", vcs.folderPath+entry.Name, BoundsPrint(start, stop))
+					continue
+				}
+				vcs.log("Regenerating", vcs.folderPath+entry.Name, err)
+			}
+		}
+		tasks = append(tasks, shardTask{i, entry})
+	}
+
+	progress := make(chan uint64, vcs.NCores)
+	reported := make(chan struct{})
+	go vcs.reportProgress(stage, skipped, total, progress, reported)
+
+	var mu sync.Mutex
+	var firstErr error
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, vcs.NCores)
+	for _, task := range tasks {
+		sem <- struct{}{}
+		mu.Lock()
+		failed := firstErr != nil
+		mu.Unlock()
+		if failed || ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func(task shardTask) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			err := gen(ctx, task.index, task.Start, task.Stop, progress)
+			mu.Lock()
+			defer mu.Unlock()
+			if err == nil {
+				err = vcs.Checkpoint(task.ManifestEntry)
+			}
+			if err != nil && firstErr == nil {
+				firstErr = fmt.Errorf("KeyGen: %s: %v", task.Name, err)
+			}
+		}(task)
 	}
 	wg.Wait()
+	close(progress)
+	<-reported
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return firstErr
 }
 
-func (vcs *VCS) PrkUpkGen() {
+// Sum up the points generated by the workers and pass them on to the Progress callback.
+// The time remaining is estimated from the rate of this run only, as skipped shards took no time.
+func (vcs *VCS) reportProgress(stage string, skipped uint64, total uint64, progress <-chan uint64, reported chan<- struct{}) {
 
-	vcs.UpkGenDriver()
-	if !vcs.DISCARD_PRK && !vcs.PARAM_TOO_LARGE {
-		fmt.Println(SEP)
-		vcs.PrkGenDriver() // This also allocates memory for PRK
+	defer close(reported)
+	begin := time.Now()
+	count := skipped
+	for n := range progress {
+		count += n
+		if vcs.Progress == nil {
+			continue
+		}
+		elapsed := time.Since(begin)
+		var remaining time.Duration
+		if count > skipped {
+			remaining = time.Duration(float64(elapsed) * float64(total-count) / float64(count-skipped))
+		}
+		vcs.Progress(KeyGenProgress{stage, count, total, elapsed, remaining})
 	}
-	fmt.Println(SEP)
 }
@@ -1,41 +1,136 @@
 package vcs
 
 import (
-	"fmt"
+	"context"
 	"math"
+	"sync"
 
 	"github.com/alinush/go-mcl"
 	"github.com/hyperproofs/gipa-go/batch"
 	"github.com/hyperproofs/gipa-go/cm"
+	"github.com/hyperproofs/gipa-go/gipakzg"
 	"github.com/hyperproofs/gipa-go/utils"
+	"github.com/hyperproofs/kzg-go/kzg"
 )
 
-func (vcs *VCS) GenAggGipa() {
+func (vcs *VCS) GenAggGipa() error {
+	return vcs.GenAggGipaContext(context.Background())
+}
+
+// Same as GenAggGipa, but stops once ctx is done and returns ctx.Err(). Nothing is saved then.
+func (vcs *VCS) GenAggGipaContext(ctx context.Context) error {
+	return vcs.genAggGipa(ctx, MAX_AGG_SIZE) // short circuiting things
+}
 
+// Aggregation keys for up to mn proofs. LoadAggGipa can then load any size up to mn.
+func (vcs *VCS) genAggGipa(ctx context.Context, mn uint64) error {
+
+	if !vcs.hasTrapdoors {
+		return newError(ErrNoTrapdoors, "GenAggGipa: Trapdoors are not available")
+	}
 	{
-		mn := uint64(MAX_AGG_SIZE) // short circuiting things
-		ck, kzg1, kzg2 := cm.IPPSetupKZG(mn, vcs.alpha, vcs.beta, vcs.G, vcs.H)
-		cm.IPPSaveCmKzg(ck, kzg1, kzg2, vcs.folderPath)
+		ck, kzg1, kzg2, err := vcs.ippSetupKZG(ctx, mn)
+		if err != nil {
+			return err
+		}
+		if err := vcs.SaveAggKeys(ck, kzg1, kzg2); err != nil {
+			return err
+		}
 	}
-	vcs.LoadAggGipa()
+	return vcs.LoadAggGipa()
 }
 
-func (self *VCS) LoadAggGipa() {
+// Same keys as cm.IPPSetupKZG, i.e., g^(alpha^i) and h^(beta^i) for i < 2mn - 1, computed on NCores goroutines.
+// The workers check ctx every CANCEL_CHECK powers.
+func (vcs *VCS) ippSetupKZG(ctx context.Context, mn uint64) (*cm.Ck, *kzg.KZG1Settings, *kzg.KZG2Settings, error) {
 
-	L := uint64(self.L)
-	limit := L * self.TxnLimit
+	if err := ctx.Err(); err != nil {
+		return nil, nil, nil, err
+	}
+	degree := 2*mn - 1
+	pkG := make([]mcl.G1, degree)
+	pkH := make([]mcl.G2, degree)
+
+	var wg sync.WaitGroup
+	step := uint64(math.Ceil(float64(degree) / float64(vcs.NCores)))
+	for start := uint64(0); start < degree; start += step {
+		wg.Add(1)
+		go func(start uint64, stop uint64) {
+			defer wg.Done()
+			a := utils.FrPow(vcs.alpha, int64(start))
+			b := utils.FrPow(vcs.beta, int64(start))
+			for i := start; i < stop; i++ {
+				if (i-start)%CANCEL_CHECK == 0 && ctx.Err() != nil {
+					return
+				}
+				mcl.G1Mul(&pkG[i], &vcs.G, &a)
+				mcl.G2Mul(&pkH[i], &vcs.H, &b)
+				mcl.FrMul(&a, &a, &vcs.alpha)
+				mcl.FrMul(&b, &b, &vcs.beta)
+			}
+		}(start, minUint64(start+step, degree))
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, nil, nil, err
+	}
 
-	self.MN = utils.NextPowOf2(limit)
+	vkG := []mcl.G1{vcs.G, {}}
+	vkH := []mcl.G2{vcs.H, {}}
+	mcl.G1Mul(&vkG[1], &vcs.G, &vcs.beta)
+	mcl.G2Mul(&vkH[1], &vcs.H, &vcs.alpha)
+
+	ck := cm.Ck{M: mn, V: make([]mcl.G2, mn), W: make([]mcl.G1, mn)}
+	for i := uint64(0); i < mn; i++ {
+		ck.W[i] = pkG[2*i]
+		ck.V[i] = pkH[2*i]
+	}
+	return &ck, kzg.NewKZG1Settings(pkG, vkH), kzg.NewKZG2Settings(pkH, vkG), nil
+}
+
+// Returns ErrBlockTooLarge if the aggregation keys of the folder are too small for L * TxnLimit.
+func (self *VCS) LoadAggGipa() error {
 
-	self.ck, self.kzg1, self.kzg2 = cm.IPPCMLoadCmKzg(self.MN, self.folderPath)
+	self.setAggSizes()
+
+	var err error
+	self.ck, self.kzg1, self.kzg2, err = self.LoadAggKeys(self.MN)
+	if err != nil {
+		return err
+	}
 	self.aggProver = batch.Prover{}
-	self.aggVerifier = batch.Verifier{}
 
+	self.log("Size:", len(self.ck.V), len(self.ck.W), len(self.kzg1.PK), len(self.kzg1.VK), len(self.kzg2.PK), len(self.kzg2.VK))
+	self.log("padding:", self.nDiff, self.mnDiff)
+	return nil
+}
+
+// batch.Verifier of the aggregated proofs. Its Init requires the 2MN - 1 KZG prover keys, although the verifier only
+// reads the first one, i.e., the generator. Thus, it is set up directly and kzg1, kzg2 only need PK[0].
+func newAggVerifier(M uint32, N uint32, MN uint64, W []mcl.G1, kzg1 *kzg.KZG1Settings, kzg2 *kzg.KZG2Settings,
+	P []mcl.G1, Q []mcl.G2, B []mcl.G2) batch.Verifier {
+
+	return batch.Verifier{
+		Verifier: gipakzg.Verifier{M: MN, KZG1: *kzg1, KZG2: *kzg2},
+		W:        W,
+		N:        N,
+		M:        M,
+		MN:       MN,
+		B:        B,
+		P:        P,
+		Q:        Q,
+	}
+}
+
+// MN and the padding of the GIPA instance for L * TxnLimit proofs.
+func (self *VCS) setAggSizes() {
+
+	L := uint64(self.L)
+	limit := L * self.TxnLimit
+
+	self.MN = utils.NextPowOf2(limit)
 	self.nDiff = int64(uint64(math.Ceil(float64(self.MN)/float64(L))) - self.TxnLimit) // This is the size of padding for P and Q vector (gipa)
 	self.mnDiff = int64(self.MN - (L * self.TxnLimit))                                 // This is the size of padding for A and B vector (gipa)
-
-	fmt.Println("Size:", len(self.ck.V), len(self.ck.W), len(self.kzg1.PK), len(self.kzg1.VK), len(self.kzg2.PK), len(self.kzg2.VK))
-	fmt.Println("padding:", self.nDiff, self.mnDiff)
 }
 
 // This resets the variable MN and txnLimit.
@@ -47,7 +142,24 @@ func (self *VCS) ResizeAgg(txnLimit uint64) {
 	self.MN = utils.NextPowOf2(limit)
 }
 
-func (vcs *VCS) AggProve(indexVec []uint64, proofVec [][]mcl.G1) batch.Proof {
+// Aggregate exactly TxnLimit proofs. Returns ErrBadBlockSize or ErrBadProofLength for malformed inputs.
+// One proof is computed at a time, thus a call waits for the proof of a stopped AggProveContext to finish.
+func (vcs *VCS) AggProve(indexVec []uint64, proofVec [][]mcl.G1) (batch.Proof, error) {
+
+	A, B, err := vcs.aggProveInputs(indexVec, proofVec)
+	if err != nil {
+		return batch.Proof{}, err
+	}
+	vcs.aggMu.Lock()
+	defer vcs.aggMu.Unlock()
+	vcs.aggProver.Init(uint32(vcs.L), uint32(vcs.TxnLimit+uint64(vcs.nDiff)), vcs.MN, &vcs.ck, &vcs.kzg1, &vcs.kzg2, A, B)
+
+	proof := vcs.aggProver.Prove()
+	return proof, nil
+}
+
+// Vectors A and B of the GIPA instance, padded to MN.
+func (vcs *VCS) aggProveInputs(indexVec []uint64, proofVec [][]mcl.G1) ([]mcl.G1, []mcl.G2, error) {
 
 	var A []mcl.G1
 	var B []mcl.G2
@@ -55,12 +167,12 @@ func (vcs *VCS) AggProve(indexVec []uint64, proofVec [][]mcl.G1) batch.Proof {
 	L := int(vcs.L)
 
 	if len(indexVec) != txnLimit || len(proofVec) != txnLimit {
-		panic("AggProof: Vectors are not of the expected size")
+		return nil, nil, newError(ErrBadBlockSize, "AggProve: Got %d indices and %d proofs, expected %d", len(indexVec), len(proofVec), txnLimit)
 	}
 
 	for t := range proofVec {
-		if len(proofVec[t]) != L {
-			panic(fmt.Sprintf("Bad proof: %d", t))
+		if len(proofVec[t]) != L || indexVec[t] >= vcs.N {
+			return nil, nil, newError(ErrBadProofLength, "AggProve: Bad proof: %d", t)
 		}
 		A = append(A, proofVec[t]...)
 	}
@@ -84,59 +196,10 @@ func (vcs *VCS) AggProve(indexVec []uint64, proofVec [][]mcl.G1) batch.Proof {
 	bPad := make([]mcl.G2, vcs.mnDiff)
 	A = append(A, aPad...)
 	B = append(B, bPad...)
-
-	vcs.aggProver.Init(uint32(vcs.L), uint32(vcs.TxnLimit+uint64(vcs.nDiff)), vcs.MN, &vcs.ck, &vcs.kzg1, &vcs.kzg2, A, B)
-
-	proof := vcs.aggProver.Prove()
-	return proof
+	return A, B, nil
 }
 
-func (vcs *VCS) AggVerify(proof batch.Proof, digest mcl.G1, indexVec []uint64, a_i []mcl.Fr) bool {
-
-	txnLimit := int(vcs.TxnLimit)
-	L := int(vcs.L)
-
-	if len(indexVec) != txnLimit || len(a_i) != txnLimit {
-		panic("AggProof: Vectors are not of the expected size")
-	}
-
-	P := make([]mcl.G1, txnLimit)
-	Q := make([]mcl.G2, txnLimit)
-	var p mcl.G1 // temp variables
-
-	for t := range a_i {
-		mcl.G1Mul(&p, &vcs.G, &a_i[t])
-		mcl.G1Sub(&p, &digest, &p)
-		P[t] = p
-		Q[t] = vcs.H
-	}
-
-	pPad := make([]mcl.G1, vcs.nDiff)
-	qPad := make([]mcl.G2, vcs.nDiff)
-	P = append(P, pPad...)
-	Q = append(Q, qPad...)
-
-	var B []mcl.G2
-	var binary []bool
-	b := make([]mcl.G2, vcs.L)
-	for t := range indexVec {
-		binary = ToBinary(indexVec[t], vcs.L)
-		for i := 0; i < L; i++ {
-			if binary[i] == true {
-				// mcl.G2Sub(&b[i], &vcs.VRK[i], &vcs.H)
-				b[i] = vcs.VRKSubOneRev[i]
-			} else {
-				b[i] = vcs.VRK[i]
-			}
-		}
-		B = append(B, b...)
-	}
-	bPad := make([]mcl.G2, vcs.mnDiff)
-	B = append(B, bPad...)
-
-	// fmt.Println("Agg Verifier", L, vcs.N, len(P))
-	vcs.aggVerifier.Init(uint32(L), uint32(vcs.TxnLimit+uint64(vcs.nDiff)), vcs.MN, vcs.ck.W, &vcs.kzg1, &vcs.kzg2, P, Q, B)
-	status := vcs.aggVerifier.VerifyEdrax(proof)
-	// status := vcs.aggVerifier.Verify(proof, P, Q, B)
-	return status
+// Returns ErrBadBlockSize if the vectors do not hold TxnLimit updates. See AggregatedProof.Verify.
+func (vcs *VCS) AggVerify(proof batch.Proof, digest mcl.G1, indexVec []uint64, a_i []mcl.Fr) (bool, error) {
+	return AggregatedProof{Indices: indexVec, Proof: proof}.Verify(vcs, Digest(digest), a_i)
 }
@@ -0,0 +1,462 @@
+// Multi-party (powers-of-tau style) setup ceremony for the VCS parameters.
+// Each participant re-randomizes UPK, VRK and the aggregation keys, and publishes a contribution with proofs of knowledge.
+// As long as one participant is honest and forgets its randomness, nobody knows the trapdoors.
+// The trapdoor s_i is shifted, s_i -> s_i + t_i, while the KZG/GIPA trapdoors are scaled, alpha -> alpha * a and beta -> beta * b.
+// The ceremony never writes a trapdoor file; use KeyGenLoad on the final folder.
+package vcs
+
+import (
+	"bufio"
+	"encoding/binary"
+	"math"
+	"sync"
+
+	"github.com/alinush/go-mcl"
+	"github.com/hyperproofs/gipa-go/utils"
+)
+
+// Generators of the ceremony are hashed to the curve, thus their discrete log relation is unknown.
+const CEREMONY_DOMAIN_G = "hyperproofs-go ceremony G"
+const CEREMONY_DOMAIN_H = "hyperproofs-go ceremony H"
+const POK_DOMAIN = "hyperproofs-go pok"
+
+// Schnorr proof of knowledge of x such that Y = Base^x in G1.
+type PoKG1 struct {
+	R mcl.G1
+	Z mcl.Fr
+}
+
+// Schnorr proof of knowledge of x such that Y = Base^x in G2.
+type PoKG2 struct {
+	R mcl.G2
+	Z mcl.Fr
+}
+
+// Public record of a single contribution to the ceremony.
+type Contribution struct {
+	Shifts      []mcl.G2 // h^{t_i}. VRK[i] is shifted by this.
+	ShiftProofs []PoKG2  // Knowledge of t_i w.r.t. h
+	AlphaPrev   mcl.G2   // h^{alpha} before the contribution
+	AlphaNext   mcl.G2   // h^{alpha * a}
+	AlphaProof  PoKG2    // Knowledge of a w.r.t. AlphaPrev
+	BetaPrev    mcl.G1   // g^{beta} before the contribution
+	BetaNext    mcl.G1   // g^{beta * b}
+	BetaProof   PoKG1    // Knowledge of b w.r.t. BetaPrev
+}
+
+// Creates the genesis parameters of the ceremony in folder.
+// Genesis uses s_i = 0 and alpha = beta = 1, i.e., there is nothing to hide yet.
+// The parameters must not be used before at least one honest Contribute.
+func (vcs *VCS) CeremonyInit(ncores uint8, L uint8, folder string, txnLimit uint64) error {
+
+	vcs.setCores(ncores)
+	if err := vcs.Init(L, folder, txnLimit); err != nil {
+		return err
+	}
+
+	vcs.G.HashAndMapTo([]byte(CEREMONY_DOMAIN_G))
+	vcs.H.HashAndMapTo([]byte(CEREMONY_DOMAIN_H))
+
+	var frOne mcl.Fr
+	frOne.SetInt64(1)
+	for i := range vcs.trapdoors {
+		vcs.trapdoors[i].Clear()
+		vcs.trapdoorsSubOne[i] = frOne
+		mcl.FrNeg(&vcs.trapdoorsSubOneRev[i], &frOne)
+
+		vcs.VRK[i].Clear()
+		vcs.VRKSubOne[i] = vcs.H
+		mcl.G2Neg(&vcs.VRKSubOneRev[i], &vcs.H)
+	}
+	vcs.alpha = frOne
+	vcs.beta = frOne
+	vcs.hasTrapdoors = true // Genesis trapdoors are public
+
+	for _, step := range []func() error{vcs.SavePublicParams, vcs.SaveVrk, vcs.UpkGenDriver, vcs.GenAggGipa} {
+		if err := step(); err != nil {
+			return err
+		}
+	}
+	vcs.ZeroizeTrapdoors()
+	vcs.Transcript = nil
+	for _, step := range []func() error{vcs.SaveTranscript, vcs.SaveManifest, vcs.ClearCheckpoint} {
+		if err := step(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Load the current state of the ceremony.
+// The whole UPK tree and the aggregation keys of size MAX_AGG_SIZE are kept in memory.
+func (vcs *VCS) CeremonyLoad(ncores uint8, L uint8, folder string, txnLimit uint64) error {
+
+	vcs.setCores(ncores)
+	if err := vcs.Init(L, folder, txnLimit); err != nil {
+		return err
+	}
+	if err := vcs.LoadManifest(); err != nil {
+		return err
+	}
+	if err := vcs.LoadPublicParams(L); err != nil {
+		return err
+	}
+	if err := vcs.UpkLoadDriver(); err != nil {
+		return err
+	}
+	var err error
+	if vcs.ck, vcs.kzg1, vcs.kzg2, err = vcs.LoadAggKeys(MAX_AGG_SIZE); err != nil {
+		return err
+	}
+	return vcs.LoadTranscript()
+}
+
+// Re-randomize the loaded parameters with fresh randomness.
+// The randomness always comes from mcl's CSPRNG, even after SetSeed or SetRandomSource, as a seeded contribution could be recomputed.
+// It is discarded before returning. Use CeremonySave to write the result.
+func (vcs *VCS) Contribute() Contribution {
+
+	var c Contribution
+	var t, a, b mcl.Fr
+
+	vcs.log(SEP, "Contributing to the ceremony", SEP)
+
+	c.Shifts = make([]mcl.G2, vcs.L)
+	c.ShiftProofs = make([]PoKG2, vcs.L)
+	for i := uint8(0); i < vcs.L; i++ {
+		t.Random()
+		mcl.G2Mul(&c.Shifts[i], &vcs.H, &t)
+		c.ShiftProofs[i] = provePoKG2(&vcs.H, &t)
+
+		vcs.UpkShift(i, t)
+
+		mcl.G2Add(&vcs.VRK[i], &vcs.VRK[i], &c.Shifts[i])
+		mcl.G2Sub(&vcs.VRKSubOne[i], &vcs.H, &vcs.VRK[i])
+		mcl.G2Sub(&vcs.VRKSubOneRev[i], &vcs.VRK[i], &vcs.H)
+		vcs.log("Shifted trapdoor", i)
+	}
+	t.Clear()
+
+	for a.IsZero() {
+		a.Random()
+	}
+	for b.IsZero() {
+		b.Random()
+	}
+
+	c.AlphaPrev = vcs.kzg1.VK[1]
+	mcl.G2Mul(&c.AlphaNext, &c.AlphaPrev, &a)
+	c.AlphaProof = provePoKG2(&c.AlphaPrev, &a)
+
+	c.BetaPrev = vcs.kzg2.VK[1]
+	mcl.G1Mul(&c.BetaNext, &c.BetaPrev, &b)
+	c.BetaProof = provePoKG1(&c.BetaPrev, &b)
+
+	vcs.AggRescale(a, b)
+	a.Clear()
+	b.Clear()
+
+	vcs.Transcript = append(vcs.Transcript, c)
+	return c
+}
+
+// Write the current state of the ceremony to folder.
+func (vcs *VCS) CeremonySave(folder string) error {
+	vcs.folderPath = folder
+	return vcs.CeremonySaveTo(NewDirKeyStore(folder))
+}
+
+// Same as CeremonySave, for any key store. Later saves and loads of the instance also use store.
+func (vcs *VCS) CeremonySaveTo(store KeyStore) error {
+
+	vcs.store = store
+	saveAggKeys := func() error { return vcs.SaveAggKeys(&vcs.ck, &vcs.kzg1, &vcs.kzg2) }
+	for _, step := range []func() error{vcs.SavePublicParams, vcs.SaveVrk, vcs.UpkSaveDriver, saveAggKeys, vcs.SaveTranscript, vcs.SaveManifest} {
+		if err := step(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Check the transcript against the loaded parameters.
+// Replays the contributions from genesis and then checks the parameters with ValidateParams.
+func (vcs *VCS) VerifyCeremony() bool {
+
+	var G mcl.G1
+	var H mcl.G2
+	G.HashAndMapTo([]byte(CEREMONY_DOMAIN_G))
+	H.HashAndMapTo([]byte(CEREMONY_DOMAIN_H))
+	if !G.IsEqual(&vcs.G) || !H.IsEqual(&vcs.H) {
+		vcs.log("VerifyCeremony: Generators are not the hashed generators")
+		return false
+	}
+
+	if len(vcs.Transcript) == 0 {
+		vcs.log("VerifyCeremony: There are no contributions")
+		return false
+	}
+
+	vrk := make([]mcl.G2, vcs.L) // Genesis has s_i = 0
+	alpha := vcs.H               // Genesis has alpha = 1
+	beta := vcs.G                // Genesis has beta = 1
+
+	for j := range vcs.Transcript {
+		c := &vcs.Transcript[j]
+		if len(c.Shifts) != int(vcs.L) || len(c.ShiftProofs) != int(vcs.L) {
+			vcs.log("VerifyCeremony: Bad contribution length:", j)
+			return false
+		}
+
+		for i := uint8(0); i < vcs.L; i++ {
+			if !verifyPoKG2(&vcs.H, &c.Shifts[i], &c.ShiftProofs[i]) {
+				vcs.log("VerifyCeremony: Bad shift proof:", j, i)
+				return false
+			}
+			mcl.G2Add(&vrk[i], &vrk[i], &c.Shifts[i])
+		}
+
+		if !c.AlphaPrev.IsEqual(&alpha) || c.AlphaNext.IsZero() || !verifyPoKG2(&c.AlphaPrev, &c.AlphaNext, &c.AlphaProof) {
+			vcs.log("VerifyCeremony: Bad alpha update:", j)
+			return false
+		}
+		alpha = c.AlphaNext
+
+		if !c.BetaPrev.IsEqual(&beta) || c.BetaNext.IsZero() || !verifyPoKG1(&c.BetaPrev, &c.BetaNext, &c.BetaProof) {
+			vcs.log("VerifyCeremony: Bad beta update:", j)
+			return false
+		}
+		beta = c.BetaNext
+	}
+
+	for i := uint8(0); i < vcs.L; i++ {
+		if !vcs.VRK[i].IsEqual(&vrk[i]) {
+			vcs.log("VerifyCeremony: VRK does not match the transcript:", i)
+			return false
+		}
+	}
+
+	if len(vcs.kzg1.VK) != 2 || len(vcs.kzg2.VK) != 2 ||
+		!vcs.kzg1.VK[1].IsEqual(&alpha) || !vcs.kzg2.VK[1].IsEqual(&beta) {
+		vcs.log("VerifyCeremony: Aggregation verification keys do not match the transcript")
+		return false
+	}
+
+	if err := vcs.ValidateParams(); err != nil {
+		vcs.log("VerifyCeremony:", err)
+		return false
+	}
+	return true
+}
+
+// Replace s_i by s_i + t in the UPK tree.
+// Nodes that depend on s_i come in pairs, g^{P(1-s_i)} and g^{P s_i}.
+// Their sum is g^{P}, thus the new pair is g^{P(1-s_i)} / g^{tP} and g^{P s_i} * g^{tP}.
+func (vcs *VCS) UpkShift(i uint8, t mcl.Fr) {
+
+	var wg sync.WaitGroup
+	for l := i + 1; l <= vcs.L; l++ {
+		numPairs := uint64(1) << (l - 1)
+		step := uint64(math.Ceil(float64(numPairs) / float64(vcs.NCores)))
+		for start := uint64(0); start < numPairs; start += step {
+			wg.Add(1)
+			go vcs.upkShiftRange(l, i, t, start, minUint64(start+step, numPairs), &wg)
+		}
+		wg.Wait()
+	}
+}
+
+// Worker for UpkShift. Handles pairs [start, stop) at level l.
+func (vcs *VCS) upkShiftRange(l uint8, i uint8, t mcl.Fr, start uint64, stop uint64, wg *sync.WaitGroup) {
+
+	var q mcl.G1
+	bit := uint64(1) << i
+	for j := start; j < stop; j++ {
+		k0 := ((j >> i) << (i + 1)) | (j & (bit - 1)) // Insert a zero at the ith bit of j
+		k1 := k0 | bit
+
+		mcl.G1Add(&q, &vcs.UPK[l][k0], &vcs.UPK[l][k1])
+		mcl.G1Mul(&q, &q, &t)
+		mcl.G1Sub(&vcs.UPK[l][k0], &vcs.UPK[l][k0], &q)
+		mcl.G1Add(&vcs.UPK[l][k1], &vcs.UPK[l][k1], &q)
+	}
+	wg.Done()
+}
+
+// Replace alpha by alpha * a and beta by beta * b in the aggregation keys.
+// The ith power in the KZG keys gets scaled by a^i (resp. b^i).
+func (vcs *VCS) AggRescale(a mcl.Fr, b mcl.Fr) {
+
+	var wg sync.WaitGroup
+	degree := uint64(len(vcs.kzg1.PK))
+	step := uint64(math.Ceil(float64(degree) / float64(vcs.NCores)))
+	for start := uint64(0); start < degree; start += step {
+		wg.Add(1)
+		go vcs.aggRescaleRange(a, b, start, minUint64(start+step, degree), &wg)
+	}
+	wg.Wait()
+
+	mcl.G2Mul(&vcs.kzg1.VK[1], &vcs.kzg1.VK[1], &a)
+	mcl.G1Mul(&vcs.kzg2.VK[1], &vcs.kzg2.VK[1], &b)
+
+	for i := uint64(0); i < vcs.ck.M; i++ {
+		vcs.ck.W[i] = vcs.kzg1.PK[2*i]
+		vcs.ck.V[i] = vcs.kzg2.PK[2*i]
+	}
+}
+
+// Worker for AggRescale. Handles powers [start, stop).
+func (vcs *VCS) aggRescaleRange(a mcl.Fr, b mcl.Fr, start uint64, stop uint64, wg *sync.WaitGroup) {
+
+	x := utils.FrPow(a, int64(start))
+	y := utils.FrPow(b, int64(start))
+	for i := start; i < stop; i++ {
+		mcl.G1Mul(&vcs.kzg1.PK[i], &vcs.kzg1.PK[i], &x)
+		mcl.G2Mul(&vcs.kzg2.PK[i], &vcs.kzg2.PK[i], &y)
+		mcl.FrMul(&x, &x, &a)
+		mcl.FrMul(&y, &y, &b)
+	}
+	wg.Done()
+}
+
+func pokChallenge(parts ...[]byte) mcl.Fr {
+	var c mcl.Fr
+	buf := []byte(POK_DOMAIN)
+	for i := range parts {
+		buf = append(buf, parts[i]...)
+	}
+	c.SetHashOf(buf)
+	return c
+}
+
+func provePoKG1(base *mcl.G1, x *mcl.Fr) PoKG1 {
+	var proof PoKG1
+	var k mcl.Fr
+	var y mcl.G1
+
+	k.Random()
+	mcl.G1Mul(&y, base, x)
+	mcl.G1Mul(&proof.R, base, &k)
+	c := pokChallenge(base.Serialize(), y.Serialize(), proof.R.Serialize())
+	mcl.FrMul(&proof.Z, &c, x)
+	mcl.FrAdd(&proof.Z, &proof.Z, &k)
+	return proof
+}
+
+func verifyPoKG1(base *mcl.G1, y *mcl.G1, proof *PoKG1) bool {
+	var lhs, rhs mcl.G1
+	c := pokChallenge(base.Serialize(), y.Serialize(), proof.R.Serialize())
+	mcl.G1Mul(&lhs, base, &proof.Z)
+	mcl.G1Mul(&rhs, y, &c)
+	mcl.G1Add(&rhs, &rhs, &proof.R)
+	return lhs.IsEqual(&rhs)
+}
+
+func provePoKG2(base *mcl.G2, x *mcl.Fr) PoKG2 {
+	var proof PoKG2
+	var k mcl.Fr
+	var y mcl.G2
+
+	k.Random()
+	mcl.G2Mul(&y, base, x)
+	mcl.G2Mul(&proof.R, base, &k)
+	c := pokChallenge(base.Serialize(), y.Serialize(), proof.R.Serialize())
+	mcl.FrMul(&proof.Z, &c, x)
+	mcl.FrAdd(&proof.Z, &proof.Z, &k)
+	return proof
+}
+
+func verifyPoKG2(base *mcl.G2, y *mcl.G2, proof *PoKG2) bool {
+	var lhs, rhs mcl.G2
+	c := pokChallenge(base.Serialize(), y.Serialize(), proof.R.Serialize())
+	mcl.G2Mul(&lhs, base, &proof.Z)
+	mcl.G2Mul(&rhs, y, &c)
+	mcl.G2Add(&rhs, &rhs, &proof.R)
+	return lhs.IsEqual(&rhs)
+}
+
+func (vcs *VCS) SaveTranscript() error {
+
+	f, err := vcs.store.Create(vcs.Names.Ceremony)
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(f)
+	w := keyFileWriter{w: bw}
+
+	countBytes := make([]byte, 8)
+	binary.LittleEndian.PutUint64(countBytes, uint64(len(vcs.Transcript)))
+	w.write(countBytes)
+
+	for j := range vcs.Transcript {
+		c := &vcs.Transcript[j]
+		for i := range c.Shifts {
+			w.write(c.Shifts[i].Serialize())
+			w.write(c.ShiftProofs[i].R.Serialize())
+			w.write(c.ShiftProofs[i].Z.Serialize())
+		}
+		w.write(c.AlphaPrev.Serialize())
+		w.write(c.AlphaNext.Serialize())
+		w.write(c.AlphaProof.R.Serialize())
+		w.write(c.AlphaProof.Z.Serialize())
+		w.write(c.BetaPrev.Serialize())
+		w.write(c.BetaNext.Serialize())
+		w.write(c.BetaProof.R.Serialize())
+		w.write(c.BetaProof.Z.Serialize())
+	}
+	if w.err == nil {
+		w.err = bw.Flush()
+	}
+	if err := w.close(f); err != nil {
+		return err
+	}
+	vcs.log(SEP, "Saved the ceremony transcript:", len(vcs.Transcript), SEP)
+	return nil
+}
+
+func (vcs *VCS) LoadTranscript() error {
+
+	fileName := vcs.folderPath + vcs.Names.Ceremony
+	size, err := vcs.store.Size(vcs.Names.Ceremony)
+	if err != nil {
+		return corrupt(fileName, err)
+	}
+	f, err := vcs.store.Open(vcs.Names.Ceremony)
+	if err != nil {
+		return corrupt(fileName, err)
+	}
+	defer f.Close()
+
+	r := keyFileReader{r: bufio.NewReader(f)}
+	count := binary.LittleEndian.Uint64(r.read(8))
+
+	fr, g1, g2 := GetFrByteSize(), GetG1ByteSize(), GetG2ByteSize()
+	contribution := uint64(int(vcs.L)*(2*g2+fr) + 3*g2 + 3*g1 + 2*fr)
+	if r.err != nil || uint64(size) != 8+count*contribution {
+		return newError(ErrKeyFolderCorrupt, "%s: Size is %d bytes, expected %d contributions for ell = %d", fileName, size, count, vcs.L)
+	}
+
+	vcs.Transcript = make([]Contribution, count)
+	for j := range vcs.Transcript {
+		c := &vcs.Transcript[j]
+		c.Shifts = make([]mcl.G2, vcs.L)
+		c.ShiftProofs = make([]PoKG2, vcs.L)
+		for i := range c.Shifts {
+			r.deserialize(&c.Shifts[i], g2)
+			r.deserialize(&c.ShiftProofs[i].R, g2)
+			r.deserialize(&c.ShiftProofs[i].Z, fr)
+		}
+		r.deserialize(&c.AlphaPrev, g2)
+		r.deserialize(&c.AlphaNext, g2)
+		r.deserialize(&c.AlphaProof.R, g2)
+		r.deserialize(&c.AlphaProof.Z, fr)
+		r.deserialize(&c.BetaPrev, g1)
+		r.deserialize(&c.BetaNext, g1)
+		r.deserialize(&c.BetaProof.R, g1)
+		r.deserialize(&c.BetaProof.Z, fr)
+	}
+	if r.err != nil {
+		return corrupt(fileName, r.err)
+	}
+	return nil
+}
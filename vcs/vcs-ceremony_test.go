@@ -0,0 +1,90 @@
+package vcs
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/alinush/go-mcl"
+)
+
+// Run a two party ceremony and use its output as regular keys.
+func TestCeremony(t *testing.T) {
+
+	mcl.InitFromString("bls12-381")
+	r := testReader(t)
+
+	L := uint8(8)
+	N := uint64(1) << L
+	K := 16
+	txnLimit := uint64(K)
+	folder := t.TempDir()
+	folders := []string{folder + "/genesis", folder + "/round-1", folder + "/pkvk"}
+
+	vcs := VCS{}
+	check(vcs.CeremonyInit(16, L, folders[0], txnLimit))
+
+	for i := 1; i < len(folders); i++ {
+		vcs = VCS{}
+		check(vcs.CeremonyLoad(16, L, folders[i-1], txnLimit))
+		vcs.Contribute()
+		check(vcs.CeremonySave(folders[i]))
+	}
+
+	vcs = VCS{}
+	check(vcs.CeremonyLoad(16, L, folders[len(folders)-1], txnLimit))
+
+	t.Run(fmt.Sprintf("%d/VerifyCeremony;%d", L, len(vcs.Transcript)), func(t *testing.T) {
+		if len(vcs.Transcript) != len(folders)-1 {
+			t.Errorf("Transcript has %d contributions", len(vcs.Transcript))
+		}
+		if !vcs.VerifyCeremony() {
+			t.Errorf("Ceremony verification failed")
+		}
+		if _, err := os.Stat(folders[len(folders)-1] + TRAPDOORNAME); err == nil {
+			t.Errorf("Ceremony wrote a trapdoor file")
+		}
+	})
+
+	t.Run(fmt.Sprintf("%d/VerifyCeremonyTampered;", L), func(t *testing.T) {
+		node := vcs.UPK[L][3]
+		vcs.UPK[L][3] = vcs.UPK[L][4]
+		if vcs.VerifyCeremony() {
+			t.Errorf("Tampered UPK passed the verification")
+		}
+		vcs.UPK[L][3] = node
+	})
+
+	vcs = VCS{}
+	check(vcs.KeyGenLoad(16, L, folders[len(folders)-1], txnLimit))
+
+	aFr, err := GenerateVectorFrom(N, r)
+	check(err)
+	digest := vcs.Commit(aFr, uint64(L))
+	vcs.OpenAll(aFr)
+
+	indexVec := make([]uint64, K)
+	proofVec := make([][]mcl.G1, K)
+	valueVec := make([]mcl.Fr, K)
+	for k := 0; k < K; k++ {
+		indexVec[k], err = RandomIndex(r, N)
+		check(err)
+		proofVec[k] = vcs.GetProofPath(indexVec[k])
+		valueVec[k] = aFr[indexVec[k]]
+	}
+
+	t.Run(fmt.Sprintf("%d/VerifyMemoized;%d", L, K), func(t *testing.T) {
+		status, _ := vcs.VerifyMemoized(digest, indexVec, valueVec, proofVec)
+		if !status {
+			t.Errorf("Verification with ceremony keys failed")
+		}
+	})
+
+	t.Run(fmt.Sprintf("%d/AggregateVerify;%d", L, K), func(t *testing.T) {
+		aggProof, err := vcs.AggProve(indexVec, proofVec)
+		check(err)
+		if ok, err := vcs.AggVerify(aggProof, digest, indexVec, valueVec); err != nil || !ok {
+			t.Errorf("Aggregation with ceremony keys failed")
+		}
+	})
+}
@@ -0,0 +1,107 @@
+// Resumable key generation.
+// As each shard is written, it is recorded in the checkpoint file of the folder. KeyGenResume reuses the recorded shards
+// and generates the rest with the saved trapdoors. The checkpoint is removed once the manifest is written.
+package vcs
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Reported through the Progress callback of VCS while UPK and PRK are generated.
+type KeyGenProgress struct {
+	Stage     string // UPK or PRK
+	Done      uint64 // Points written so far, including those of a previous run
+	Total     uint64
+	Elapsed   time.Duration // Since this run started the stage
+	Remaining time.Duration // Estimate
+}
+
+// Shards recorded in the checkpoint file, by name. Empty if there is no checkpoint.
+func (vcs *VCS) LoadCheckpoint() (map[string]ManifestEntry, error) {
+
+	done := make(map[string]ManifestEntry)
+	f, err := vcs.store.Open(vcs.Names.Checkpoint)
+	if os.IsNotExist(err) {
+		return done, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry ManifestEntry
+		if _, err := fmt.Sscanf(scanner.Text(), "%s %d %d", &entry.Name, &entry.Start, &entry.Stop); err != nil {
+			break // Torn write of the last line
+		}
+		done[entry.Name] = entry
+	}
+	return done, scanner.Err()
+}
+
+// Record that entry is complete on disk.
+func (vcs *VCS) Checkpoint(entry ManifestEntry) error {
+
+	f, err := vcs.store.Append(vcs.Names.Checkpoint)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err = fmt.Fprintf(f, "%s %d %d\n", entry.Name, entry.Start, entry.Stop); err != nil {
+		return err
+	}
+	return syncKeyFile(f)
+}
+
+func (vcs *VCS) ClearCheckpoint() error {
+	err := vcs.store.Remove(vcs.Names.Checkpoint)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// Continue a KeyGen that was interrupted, e.g., by a crash.
+// The trapdoors are loaded from the folder, hence this does not work for KeyGenNoTrapdoor.
+func (vcs *VCS) KeyGenResume(ncores uint8, L uint8, folder string, txnLimit uint64) error {
+
+	vcs.setCores(ncores)
+	if err := vcs.Init(L, folder, txnLimit); err != nil {
+		return err
+	}
+	if err := vcs.LoadTrapdoor(L); err != nil {
+		return err
+	}
+	return vcs.keyGenFromTrapdoors()
+}
+
+// UPK, PRK and the aggregation keys, skipping whatever is in the checkpoint.
+func (vcs *VCS) keyGenFromTrapdoors() error {
+
+	if err := vcs.PrkUpkGen(); err != nil {
+		return err
+	}
+
+	done, err := vcs.LoadCheckpoint()
+	if err != nil {
+		return err
+	}
+	agg := ManifestEntry{Name: vcs.Names.CK}
+	if _, ok := done[agg.Name]; ok {
+		err = vcs.LoadAggGipa()
+	} else if err = vcs.GenAggGipa(); err == nil {
+		err = vcs.Checkpoint(agg)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := vcs.SaveManifest(); err != nil {
+		return err
+	}
+	return vcs.ClearCheckpoint()
+}
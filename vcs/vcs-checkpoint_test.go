@@ -0,0 +1,98 @@
+package vcs
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/alinush/go-mcl"
+)
+
+// Interrupt keygen after a few shards and resume it.
+func TestKeyGenResume(t *testing.T) {
+
+	mcl.InitFromString("bls12-381")
+
+	L := uint8(8)
+	txnLimit := uint64(8)
+	folder := t.TempDir()
+
+	vcs := VCS{}
+	check(vcs.KeyGen(16, L, folder, txnLimit))
+	numUPK := (uint64(1) << (L + 1)) - 1
+
+	original := make([][]byte, vcs.NumShards)
+	for i := range original {
+		var err error
+		original[i], err = os.ReadFile(folder + fmt.Sprintf(UPKNAME, i))
+		check(err)
+	}
+
+	// Crash after shards 0 to 4: Only these are in the checkpoint, the others are lost or partial.
+	for i := uint8(0); i < vcs.NumShards; i++ {
+		start, stop := ShardBounds(numUPK, vcs.NumShards, i)
+		if i < 5 {
+			check(vcs.Checkpoint(ManifestEntry{Name: fmt.Sprintf(UPKNAME, i), Start: start, Stop: stop}))
+		} else if i%2 == 0 {
+			check(os.Remove(folder + fmt.Sprintf(UPKNAME, i)))
+		} else {
+			check(os.WriteFile(folder+fmt.Sprintf(UPKNAME, i), original[i][:GetG1ByteSize()], 0644))
+		}
+	}
+	// Shard 1 is in the checkpoint and of the right size, but its first point does not decode.
+	corrupted := append([]byte{}, original[1]...)
+	copy(corrupted, bytes.Repeat([]byte{0xff}, GetG1ByteSize()))
+	check(os.WriteFile(folder+fmt.Sprintf(UPKNAME, 1), corrupted, 0644))
+	kept, err := os.Stat(folder + fmt.Sprintf(UPKNAME, 2))
+	check(err)
+
+	var reports []KeyGenProgress
+	vcs = VCS{}
+	vcs.Progress = func(p KeyGenProgress) {
+		reports = append(reports, p)
+	}
+	check(vcs.KeyGenResume(16, L, folder, txnLimit))
+
+	t.Run(fmt.Sprintf("%d/Resume;%d", L, vcs.NumShards), func(t *testing.T) {
+		for i := range original {
+			data, err := os.ReadFile(folder + fmt.Sprintf(UPKNAME, i))
+			if err != nil || !bytes.Equal(data, original[i]) {
+				t.Errorf("Shard %d differs after resuming", i)
+			}
+		}
+		info, err := os.Stat(folder + fmt.Sprintf(UPKNAME, 2))
+		if err != nil || !info.ModTime().Equal(kept.ModTime()) {
+			t.Errorf("Checkpointed shard was generated again")
+		}
+		if _, err := os.Stat(folder + CHECKPOINTNAME); err == nil {
+			t.Errorf("Checkpoint was not removed")
+		}
+		if err := vcs.LoadManifest(); err != nil {
+			t.Errorf("Manifest: %v", err)
+		}
+	})
+
+	t.Run(fmt.Sprintf("%d/Progress;%d", L, len(reports)), func(t *testing.T) {
+		_, skipped := ShardBounds(numUPK, vcs.NumShards, 4)
+		start, stop := ShardBounds(numUPK, vcs.NumShards, 1) // Generated again
+		skipped -= stop - start
+		if len(reports) == 0 {
+			t.Fatalf("No progress was reported")
+		}
+		last := reports[len(reports)-1]
+		if reports[0].Done <= skipped || last.Done != numUPK || last.Total != numUPK || last.Stage != "UPK" {
+			t.Errorf("Bad progress: first %+v, last %+v", reports[0], last)
+		}
+	})
+
+	t.Run(fmt.Sprintf("%d/WorkerError;", L), func(t *testing.T) {
+		check(vcs.ClearCheckpoint())
+		check(os.Mkdir(folder+fmt.Sprintf(UPKNAME, 3)+".tmp", 0755)) // The worker of shard 3 cannot create its file
+		err := vcs.UpkGenDriver()
+		if err == nil || !strings.Contains(err.Error(), fmt.Sprintf(UPKNAME, 3)) {
+			t.Errorf("Expected an error for shard 3, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,122 @@
+// Settings of an instance.
+// Each VCS carries its own Config, hence instances with different settings can coexist in one process.
+// Init fills in the defaults of the fields left unset. The ncores argument of KeyGen, KeyGenLoad, etc. overrides NCores.
+package vcs
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+)
+
+// Default names of the key files, relative to the folder. PRKNAME and UPKNAME take the shard number.
+const (
+	PRKNAME        = "/prk-%02d.data"
+	VRKNAME        = "/vrk.data"
+	TRAPDOORNAME   = "/trapdoors.data"
+	UPKNAME        = "/upk-%02d.data"
+	PPNAME         = "/pp.data"
+	CEREMONYNAME   = "/ceremony.data"
+	MANIFESTNAME   = "/manifest.data"
+	CHECKPOINTNAME = "/keygen.checkpoint"
+	CKNAME         = "/CK.data"  // GIPA commitment key
+	KZGNAME        = "/KZG.data" // KZG keys of GIPA
+)
+
+const DEFAULT_SHARDS = 16
+
+type FileNames struct {
+	PRK        string // Format with the shard number
+	UPK        string // Format with the shard number
+	VRK        string
+	Trapdoor   string
+	PP         string
+	Ceremony   string
+	Manifest   string
+	Checkpoint string
+	CK         string
+	KZG        string
+}
+
+type Config struct {
+	NCores      uint8     // Maximum number of goroutines. 0 uses the number of CPUs.
+	NumShards   uint8     // Number of files UPK and PRK are split into when generated. 0 defaults to DEFAULT_SHARDS.
+	Names       FileNames // Names of the key files. Empty names take the default, e.g., UPKNAME.
+	WithPRK     bool      // Generate and load PRK, e.g., to commit in the monomial basis. See vcs-prk.go
+	MemoryEll   uint8     // Largest ell whose keys are held in memory. Above it, PARAM_TOO_LARGE is set. 0 defaults to PARAM_TOO_LARGE_ELL.
+	Log         io.Writer // Progress messages. nil writes to os.Stdout. Use io.Discard to silence them.
+	Checksums   bool      // Hash every file of the manifest when it is loaded. Otherwise, only sizes and ranges are checked. CheckKeys always hashes.
+	TrustedKeys bool      // Skip the subgroup checks when loading UPK and PRK. Only for key files generated locally. Changes a setting of mcl global to the process, see loadShards.
+}
+
+func DefaultFileNames() FileNames {
+	return FileNames{PRKNAME, UPKNAME, VRKNAME, TRAPDOORNAME, PPNAME, CEREMONYNAME, MANIFESTNAME, CHECKPOINTNAME, CKNAME, KZGNAME}
+}
+
+func DefaultConfig() Config {
+	return Config{}.withDefaults()
+}
+
+// New instance with the settings of cfg. Call Init, KeyGen, KeyGenLoad, etc. next.
+func NewVCS(cfg Config) *VCS {
+	return &VCS{Config: cfg}
+}
+
+func (cfg Config) withDefaults() Config {
+
+	if cfg.NCores == 0 {
+		cfg.NCores = uint8(minUint64(uint64(runtime.NumCPU()), 255))
+	}
+	if cfg.NumShards == 0 {
+		cfg.NumShards = DEFAULT_SHARDS
+	}
+	if cfg.MemoryEll == 0 {
+		cfg.MemoryEll = PARAM_TOO_LARGE_ELL
+	}
+	if cfg.Log == nil {
+		cfg.Log = os.Stdout
+	}
+
+	names := &cfg.Names
+	setDefault := func(name *string, def string) {
+		if *name == "" {
+			*name = def
+		}
+	}
+	setDefault(&names.PRK, PRKNAME)
+	setDefault(&names.UPK, UPKNAME)
+	setDefault(&names.VRK, VRKNAME)
+	setDefault(&names.Trapdoor, TRAPDOORNAME)
+	setDefault(&names.PP, PPNAME)
+	setDefault(&names.Ceremony, CEREMONYNAME)
+	setDefault(&names.Manifest, MANIFESTNAME)
+	setDefault(&names.Checkpoint, CHECKPOINTNAME)
+	setDefault(&names.CK, CKNAME)
+	setDefault(&names.KZG, KZGNAME)
+	return cfg
+}
+
+// Set the core count from the ncores argument of KeyGen, KeyGenLoad, etc. 0 keeps NCores.
+func (vcs *VCS) setCores(ncores uint8) {
+	if ncores != 0 {
+		vcs.NCores = ncores
+	}
+}
+
+// Print a progress message to Log.
+func (vcs *VCS) log(a ...interface{}) {
+	w := vcs.Log
+	if w == nil {
+		w = os.Stdout
+	}
+	fmt.Fprintln(w, a...)
+}
+
+func (vcs *VCS) logf(format string, a ...interface{}) {
+	w := vcs.Log
+	if w == nil {
+		w = os.Stdout
+	}
+	fmt.Fprintf(w, format, a...)
+}
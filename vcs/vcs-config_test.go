@@ -0,0 +1,107 @@
+package vcs
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/alinush/go-mcl"
+)
+
+// Instances with different settings are generated side by side and loaded back.
+func TestConfig(t *testing.T) {
+
+	mcl.InitFromString("bls12-381")
+
+	L := uint8(6)
+	txnLimit := uint64(8)
+	folders := []string{t.TempDir(), t.TempDir()}
+	logs := []*bytes.Buffer{new(bytes.Buffer), new(bytes.Buffer)}
+	configs := []Config{
+		{NCores: 4, NumShards: 3, Log: logs[0]},
+		{NCores: 2, NumShards: 5, WithPRK: true, Log: logs[1], Names: FileNames{UPK: "/tree-%d.keys", VRK: "/verifier.keys", PRK: "/monomial-%d.keys", CK: "/gipa-ck.keys"}},
+	}
+
+	instances := make([]*VCS, len(configs))
+	var wg sync.WaitGroup
+	for i := range configs {
+		instances[i] = NewVCS(configs[i])
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			check(instances[i].KeyGen(16, L, folders[i], txnLimit))
+		}(i)
+	}
+	wg.Wait()
+
+	t.Run(fmt.Sprintf("%d/Coexist;%d", L, txnLimit), func(t *testing.T) {
+		for i, cfg := range configs {
+			loaded := NewVCS(cfg)
+			check(loaded.KeyGenLoad(0, L, folders[i], txnLimit))
+			if loaded.nfiles != cfg.NumShards || loaded.NCores != cfg.NCores {
+				t.Errorf("Instance %d: %d shards on %d cores, expected %d on %d", i, loaded.nfiles, loaded.NCores, cfg.NumShards, cfg.NCores)
+			}
+			if !SliceIsEqual(loaded.GetUpk(5), instances[i].GetUpk(5)) {
+				t.Errorf("Instance %d: UPK mismatch", i)
+			}
+			if cfg.WithPRK != (loaded.PRK != nil) {
+				t.Errorf("Instance %d: PRK policy was not applied", i)
+			}
+		}
+		for _, name := range []string{"/tree-4.keys", "/verifier.keys", "/gipa-ck.keys", "/monomial-4.keys"} {
+			if _, err := os.Stat(folders[1] + name); err != nil {
+				t.Errorf("Missing custom file: %v", err)
+			}
+		}
+		if _, err := os.Stat(folders[1] + fmt.Sprintf(UPKNAME, 0)); err == nil {
+			t.Errorf("Default UPK name was used")
+		}
+	})
+
+	t.Run(fmt.Sprintf("%d/Inspect;%d", L, txnLimit), func(t *testing.T) {
+		for i, cfg := range configs {
+			info, err := NewVCS(cfg).InspectKeys(folders[i], txnLimit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.NumShards != cfg.NumShards || info.HasPRK != cfg.WithPRK || !info.HasAggKeys {
+				t.Errorf("Instance %d: %d shards, PRK %v, aggregation keys %v", i, info.NumShards, info.HasPRK, info.HasAggKeys)
+			}
+		}
+	})
+
+	t.Run(fmt.Sprintf("%d/Log;%d", L, txnLimit), func(t *testing.T) {
+		if !strings.Contains(logs[0].String(), "upk-02.data") || strings.Contains(logs[0].String(), "tree-") {
+			t.Errorf("Messages of the first instance went elsewhere")
+		}
+		if !strings.Contains(logs[1].String(), "tree-4.keys") || strings.Contains(logs[1].String(), "upk-") {
+			t.Errorf("Messages of the second instance went elsewhere")
+		}
+		quiet := NewVCS(Config{Log: io.Discard})
+		check(quiet.KeyGenLoad(16, L, folders[0], txnLimit))
+	})
+
+	// A low threshold keeps the keys on disk, i.e., the UPK tree is mapped instead of loaded.
+	t.Run(fmt.Sprintf("%d/MemoryEll;%d", L, txnLimit), func(t *testing.T) {
+		small := NewVCS(Config{MemoryEll: L - 1, Log: io.Discard})
+		check(small.Init(L, folders[0], txnLimit))
+		if !small.PARAM_TOO_LARGE {
+			t.Fatalf("PARAM_TOO_LARGE is not set for ell above MemoryEll")
+		}
+		check(small.LoadManifest())
+		check(small.LoadPublicParams(L))
+		check(small.UpkMapDriver())
+		defer small.UpkUnmap()
+		if !small.VerifyUPK(5, small.GetUpk(5)) {
+			t.Errorf("Mapped UPK failed to verify")
+		}
+		if err := small.KeyGenInMemory(16, L, txnLimit); !errors.Is(err, ErrBadEll) {
+			t.Errorf("Expected %q, got %v", ErrBadEll, err)
+		}
+	})
+}
@@ -0,0 +1,119 @@
+// Variants of the long-running operations that take a context.Context.
+// They check ctx between chunks of work and return ctx.Err() once it is cancelled or past its deadline.
+// Results of a stopped call are discarded: The proof tree is kept as before, and temporary key files are removed.
+// UpkGenDriverContext, PrkGenDriverContext, UpkLoadDriverContext and GenAggGipaContext are next to the functions they extend.
+package vcs
+
+import (
+	"context"
+
+	"github.com/alinush/go-mcl"
+	"github.com/hyperproofs/gipa-go/batch"
+)
+
+// Same as Commit, but the multi-exponentiation is split into chunks of CANCEL_CHECK points.
+func (vcs *VCS) CommitContext(ctx context.Context, a []mcl.Fr, L uint64) (mcl.G1, error) {
+	upk, err := vcs.upkLevel(uint8(L))
+	if err != nil {
+		return mcl.G1{}, err
+	}
+	return commitChunks(ctx, upk, a)
+}
+
+func commitChunks(ctx context.Context, upk []mcl.G1, a []mcl.Fr) (mcl.G1, error) {
+
+	var digest, temp mcl.G1
+	digest.Clear()
+	for start := 0; start < len(a); start += CANCEL_CHECK {
+		if err := ctx.Err(); err != nil {
+			return mcl.G1{}, err
+		}
+		stop := start + CANCEL_CHECK
+		if stop > len(a) {
+			stop = len(a)
+		}
+		mcl.G1MulVec(&temp, upk[start:stop], a[start:stop])
+		mcl.G1Add(&digest, &digest, &temp)
+	}
+	return digest, nil
+}
+
+// Same as OpenAll. The new tree replaces ProofTree only once complete, thus the previous tree is served meanwhile.
+// Until then, both trees are in memory.
+func (vcs *VCS) OpenAllContext(ctx context.Context, a []mcl.Fr) error {
+
+	tree := make([][]mcl.G1, vcs.L)
+	for i := uint8(0); i < vcs.L; i++ {
+		tree[i] = make([]mcl.G1, 1<<i)
+	}
+	if err := vcs.openAllRec(ctx, tree, a, 0, vcs.N, vcs.L); err != nil {
+		return err
+	}
+
+	vcs.treeMu.Lock()
+	defer vcs.treeMu.Unlock()
+	vcs.ProofTree = tree
+	return nil
+}
+
+// Same as OpenAllRec, but fills tree instead of ProofTree.
+func (vcs *VCS) openAllRec(ctx context.Context, tree [][]mcl.G1, a []mcl.Fr, start uint64, end uint64, L uint8) error {
+
+	if end-start <= 1 {
+		return nil
+	}
+
+	mid := (start + end) / 2
+	bin := end - start
+	index := start / bin
+
+	aDiff := make([]mcl.Fr, mid-start)
+	for i := uint64(0); i < mid-start; i++ {
+		mcl.FrSub(&aDiff[i], &a[i+mid], &a[i+start])
+	}
+
+	result, err := vcs.CommitContext(ctx, aDiff, uint64(L-1))
+	if err != nil {
+		return err
+	}
+	tree[vcs.L-L][index] = result
+
+	if err := vcs.openAllRec(ctx, tree, a, start, mid, L-1); err != nil {
+		return err
+	}
+	return vcs.openAllRec(ctx, tree, a, mid, end, L-1)
+}
+
+// Same as AggProve, but returns ctx.Err() once ctx is done.
+// GIPA cannot be interrupted, thus the work of a stopped proof is not stopped: It finishes in the background and is discarded.
+// Proofs are computed one at a time, also the stopped ones. Thus, at most one runs in the background, and the next
+// AggProve or AggProveContext waits for it. A proof whose ctx is done before its turn is not computed.
+func (vcs *VCS) AggProveContext(ctx context.Context, indexVec []uint64, proofVec [][]mcl.G1) (batch.Proof, error) {
+
+	A, B, err := vcs.aggProveInputs(indexVec, proofVec)
+	if err != nil {
+		return batch.Proof{}, err
+	}
+	if err := ctx.Err(); err != nil {
+		return batch.Proof{}, err
+	}
+
+	done := make(chan batch.Proof, 1)
+	go func() {
+		vcs.aggMu.Lock()
+		defer vcs.aggMu.Unlock()
+		if ctx.Err() != nil {
+			return // Stopped while waiting for its turn
+		}
+		var prover batch.Prover
+		prover.Init(uint32(vcs.L), uint32(vcs.TxnLimit+uint64(vcs.nDiff)), vcs.MN, &vcs.ck, &vcs.kzg1, &vcs.kzg2, A, B)
+		done <- prover.Prove()
+	}()
+
+	select {
+	case proof := <-done:
+		return proof, nil
+	case <-ctx.Done():
+		return batch.Proof{}, ctx.Err()
+	}
+}
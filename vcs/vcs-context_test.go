@@ -0,0 +1,133 @@
+package vcs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/alinush/go-mcl"
+	"github.com/hyperproofs/gipa-go/cm"
+)
+
+// Stopped calls return ctx.Err() and leave nothing behind. Calls that are not stopped match the plain functions.
+func TestContext(t *testing.T) {
+
+	mcl.InitFromString("bls12-381")
+
+	L := uint8(6)
+	txnLimit := uint64(4)
+	vcs, err := NewVCSInMemory(16, L, txnLimit)
+	check(err)
+	aFr := GenerateVector(vcs.N)
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	expectCancelled := func(t *testing.T, err error) {
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected %q, got %v", context.Canceled, err)
+		}
+	}
+
+	t.Run(fmt.Sprintf("%d/Commit;%d", L, txnLimit), func(t *testing.T) {
+		digest, err := vcs.CommitContext(context.Background(), aFr, uint64(L))
+		check(err)
+		expected := vcs.Commit(aFr, uint64(L))
+		if !digest.IsEqual(&expected) {
+			t.Errorf("CommitContext does not match Commit")
+		}
+		_, err = vcs.CommitContext(cancelled, aFr, uint64(L))
+		expectCancelled(t, err)
+	})
+
+	vcs.OpenAll(aFr)
+	digest := vcs.Commit(aFr, uint64(L))
+	indexVec := make([]uint64, txnLimit)
+	valueVec := make([]mcl.Fr, txnLimit)
+	proofVec := make([][]mcl.G1, txnLimit)
+	for k := range indexVec {
+		indexVec[k] = uint64(k) * 13 % vcs.N
+		valueVec[k] = aFr[indexVec[k]]
+		proofVec[k] = vcs.GetProofPath(indexVec[k])
+	}
+
+	t.Run(fmt.Sprintf("%d/OpenAll;%d", L, txnLimit), func(t *testing.T) {
+		expectCancelled(t, vcs.OpenAllContext(cancelled, GenerateVector(vcs.N)))
+		for k := range indexVec {
+			if !SliceIsEqual(vcs.GetProofPath(indexVec[k]), proofVec[k]) {
+				t.Fatalf("Stopped OpenAllContext changed the proof tree")
+			}
+		}
+	})
+
+	t.Run(fmt.Sprintf("%d/AggProve;%d", L, txnLimit), func(t *testing.T) {
+		_, err := vcs.AggProveContext(cancelled, indexVec, proofVec)
+		expectCancelled(t, err)
+		aggProof, err := vcs.AggProveContext(context.Background(), indexVec, proofVec)
+		check(err)
+		if status, err := vcs.AggVerify(aggProof, digest, indexVec, valueVec); !status || err != nil {
+			t.Errorf("Aggregated proof failed to verify: %v", err)
+		}
+	})
+
+	t.Run(fmt.Sprintf("%d/GenAggGipa;%d", L, txnLimit), func(t *testing.T) {
+		mn := uint64(8)
+		ck, kzg1, kzg2, err := vcs.ippSetupKZG(context.Background(), mn)
+		check(err)
+		expectedCk, expectedKzg1, expectedKzg2 := cm.IPPSetupKZG(mn, vcs.alpha, vcs.beta, vcs.G, vcs.H)
+		if !SliceIsEqual(ck.W, expectedCk.W) || !SliceIsEqual(kzg1.PK, expectedKzg1.PK) || !SliceIsEqual(kzg2.VK, expectedKzg2.VK) {
+			t.Errorf("Aggregation keys do not match cm.IPPSetupKZG")
+		}
+		for i := range kzg2.PK {
+			if !kzg2.PK[i].IsEqual(&expectedKzg2.PK[i]) {
+				t.Fatalf("KZG2 key %d does not match cm.IPPSetupKZG", i)
+			}
+		}
+		expectCancelled(t, vcs.GenAggGipaContext(cancelled))
+	})
+
+	folder := t.TempDir()
+	keys := NewVCS(Config{Log: io.Discard})
+	check(keys.KeyGen(16, L, folder, txnLimit))
+
+	// The shard is stopped at the first check, after PROGRESS_STEP points.
+	t.Run(fmt.Sprintf("%d/UpkGen;%d", L, txnLimit), func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		progress := make(chan uint64, 4)
+		err := keys.genShard(ctx, "/stopped.data", 0, 3*PROGRESS_STEP, progress, func(j uint64) mcl.G1 {
+			if j == PROGRESS_STEP/2 {
+				cancel()
+			}
+			return keys.G
+		})
+		expectCancelled(t, err)
+		for _, name := range []string{"/stopped.data", "/stopped.data.tmp"} {
+			if _, err := os.Stat(folder + name); err == nil {
+				t.Errorf("%s was left behind", name)
+			}
+		}
+
+		stopped := NewVCS(Config{Log: io.Discard})
+		check(stopped.Init(L, t.TempDir(), txnLimit))
+		check(stopped.TrapdoorsSample())
+		expectCancelled(t, stopped.UpkGenDriverContext(cancelled))
+	})
+
+	t.Run(fmt.Sprintf("%d/UpkLoad;%d", L, txnLimit), func(t *testing.T) {
+		loaded := NewVCS(Config{Log: io.Discard})
+		check(loaded.Init(L, folder, txnLimit))
+		check(loaded.LoadManifest())
+		check(loaded.LoadPublicParams(L))
+		expectCancelled(t, loaded.UpkLoadDriverContext(cancelled))
+		if loaded.UPK != nil {
+			t.Errorf("Partially loaded UPK was kept")
+		}
+		check(loaded.UpkLoadDriverContext(context.Background()))
+		if !SliceIsEqual(loaded.GetUpk(5), keys.GetUpk(5)) {
+			t.Errorf("UPK mismatch")
+		}
+	})
+}
@@ -0,0 +1,126 @@
+// Smaller instances from a larger key folder.
+// UPK levels 0..L of an ell = L' tree are exactly the UPK tree of ell = L with the trapdoors s_1, ..., s_L.
+// In the files, these levels are the first 2^(L+1) - 1 points. Similarly, PRK and VRK for ell = L are prefixes of those for ell = L'.
+// Aggregation keys do not depend on ell. Hence, any folder can be loaded with KeyGenLoad for L <= L'.
+// KeyGenDerive writes the prefix out as a standalone folder.
+package vcs
+
+import (
+	"fmt"
+	"io"
+)
+
+// Load the generators and VRK of the folder. Trapdoors are loaded and zeroized for folders generated before pp.data existed.
+func (vcs *VCS) LoadParams(L uint8) error {
+	if _, err := vcs.store.Size(vcs.Names.PP); err == nil {
+		return vcs.LoadPublicParams(L)
+	}
+	// Folders generated before the public parameter file existed.
+	err := vcs.LoadTrapdoor(L)
+	vcs.ZeroizeTrapdoors()
+	return err
+}
+
+// Write the ell = L instance of the key folder src to dst. src can have any ell >= L.
+// Points are copied as they are, hence the trees are never held in memory. The ceremony transcript is not copied.
+func (vcs *VCS) KeyGenDerive(ncores uint8, L uint8, src string, dst string) error {
+	err := vcs.KeyGenDeriveTo(ncores, L, src, NewDirKeyStore(dst))
+	vcs.folderPath = dst
+	return err
+}
+
+// Same as KeyGenDerive, for any destination store. The source is the store of the instance, i.e., Store or the folder src.
+func (vcs *VCS) KeyGenDeriveTo(ncores uint8, L uint8, src string, dst KeyStore) error {
+
+	vcs.setCores(ncores)
+	if err := vcs.Init(L, src, 1); err != nil {
+		return err
+	}
+	if err := vcs.LoadManifest(); err != nil {
+		return err
+	}
+	if err := vcs.LoadParams(L); err != nil {
+		return err
+	}
+	vcs.log(SEP, "Deriving ell =", L, "from ell =", vcs.FolderEll(), SEP)
+
+	upkShards, err := vcs.UpkShards()
+	if err != nil {
+		return err
+	}
+	var prkShards []ManifestEntry
+	if _, err := vcs.store.Size(fmt.Sprintf(vcs.Names.PRK, 0)); err == nil {
+		if prkShards, err = vcs.PrkShards(); err != nil {
+			return err
+		}
+	}
+
+	srcStore := vcs.store
+	vcs.store = dst
+	if err := vcs.SavePublicParams(); err != nil {
+		return err
+	}
+	if err := vcs.SaveVrk(); err != nil {
+		return err
+	}
+
+	numUPK := (uint64(1) << (L + 1)) - 1
+	for i := uint8(0); i < vcs.nfiles; i++ {
+		start, stop := ShardBounds(numUPK, vcs.nfiles, i)
+		if err := vcs.copyPoints(srcStore, upkShards, dst, fmt.Sprintf(vcs.Names.UPK, i), start, stop); err != nil {
+			return err
+		}
+	}
+	if prkShards != nil {
+		for i := uint8(0); i < vcs.nfiles; i++ {
+			start, stop := ShardBounds(vcs.N, vcs.nfiles, i)
+			if err := vcs.copyPoints(srcStore, prkShards, dst, fmt.Sprintf(vcs.Names.PRK, i), start, stop); err != nil {
+				return err
+			}
+		}
+	}
+	for _, name := range []string{vcs.Names.CK, vcs.Names.KZG} {
+		if _, err := srcStore.Size(name); err == nil {
+			if err := CopyKeyFile(srcStore, dst, name); err != nil {
+				return err
+			}
+		}
+	}
+
+	vcs.folderL = L
+	vcs.manifest = nil
+	return vcs.SaveManifest()
+}
+
+// Write the points [start, stop) held by shards of src to fileName of dst.
+func (vcs *VCS) copyPoints(src KeyStore, shards []ManifestEntry, dst KeyStore, fileName string, start uint64, stop uint64) error {
+
+	out, err := dst.Create(fileName)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	size := int64(GetG1ByteSize())
+	for _, shard := range shards {
+		lo := maxUint64(start, shard.Start)
+		hi := minUint64(stop, shard.Stop)
+		if lo >= hi {
+			continue
+		}
+		in, err := src.Open(shard.Name)
+		if err != nil {
+			return corrupt(shard.Name, err)
+		}
+		_, err = io.Copy(out, io.NewSectionReader(in, int64(lo-shard.Start)*size, int64(hi-lo)*size))
+		in.Close()
+		if err != nil {
+			return err
+		}
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	vcs.log("Dumped ", fileName, BoundsPrint(start, stop))
+	return nil
+}
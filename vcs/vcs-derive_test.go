@@ -0,0 +1,81 @@
+package vcs
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/alinush/go-mcl"
+)
+
+// Use an ell = 8 folder as an ell = 5 instance, directly and through a derived folder.
+func TestDerive(t *testing.T) {
+
+	mcl.InitFromString("bls12-381")
+	r := testReader(t)
+
+	bigL := uint8(8)
+	L := uint8(5)
+	N := uint64(1) << L
+	K := 8
+	txnLimit := uint64(K)
+	folder := t.TempDir()
+	derived := folder + "/derived"
+
+	big := VCS{}
+	check(big.KeyGen(16, bigL, folder, txnLimit))
+
+	direct := VCS{}
+	check(direct.KeyGenLoad(16, L, folder, txnLimit))
+
+	vcs := VCS{}
+	check(vcs.KeyGenDerive(16, L, folder, derived))
+	vcs = VCS{}
+	check(vcs.KeyGenLoad(16, L, derived, txnLimit))
+
+	t.Run(fmt.Sprintf("%d/Subtree;%d", L, bigL), func(t *testing.T) {
+		if vcs.FolderEll() != L || direct.FolderEll() != bigL {
+			t.Errorf("Folder ell: %d %d", vcs.FolderEll(), direct.FolderEll())
+		}
+		for l := uint8(0); l <= L; l++ {
+			if !SliceIsEqual(direct.UPK[l], big.UPK[l]) || !SliceIsEqual(vcs.UPK[l], big.UPK[l]) {
+				t.Fatalf("UPK mismatch at level %d", l)
+			}
+		}
+		if err := vcs.ValidateParams(); err != nil {
+			t.Errorf("Derived folder: %v", err)
+		}
+		if err := direct.ValidateParams(); err != nil {
+			t.Errorf("Larger folder: %v", err)
+		}
+	})
+
+	aFr, err := GenerateVectorFrom(N, r)
+	check(err)
+	digest := vcs.Commit(aFr, uint64(L))
+	vcs.OpenAll(aFr)
+
+	indexVec := make([]uint64, K)
+	proofVec := make([][]mcl.G1, K)
+	valueVec := make([]mcl.Fr, K)
+	for k := 0; k < K; k++ {
+		indexVec[k], err = RandomIndex(r, N)
+		check(err)
+		proofVec[k] = vcs.GetProofPath(indexVec[k])
+		valueVec[k] = aFr[indexVec[k]]
+	}
+
+	t.Run(fmt.Sprintf("%d/VerifyMemoized;%d", L, K), func(t *testing.T) {
+		status, _ := direct.VerifyMemoized(digest, indexVec, valueVec, proofVec)
+		if !status {
+			t.Errorf("Proofs of the derived folder failed with the larger folder")
+		}
+	})
+
+	t.Run(fmt.Sprintf("%d/AggregateVerify;%d", L, K), func(t *testing.T) {
+		aggProof, err := vcs.AggProve(indexVec, proofVec)
+		check(err)
+		if ok, err := direct.AggVerify(aggProof, digest, indexVec, valueVec); err != nil || !ok {
+			t.Errorf("Aggregation of the derived folder failed with the larger folder")
+		}
+	})
+}
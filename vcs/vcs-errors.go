@@ -0,0 +1,47 @@
+// Errors of the vcs package.
+// Failures are reported with the sentinels below, e.g., errors.Is(err, ErrKeyFolderCorrupt), and a message with the details.
+// Panics are left for misuse that no input can trigger, e.g., generating keys without trapdoors in SelectUPK.
+package vcs
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrBadEll           = errors.New("Bad ell")                             // ell is 0, >= 32, or larger than the key folder
+	ErrBlockTooLarge    = errors.New("Block is too large")                  // L * TxnLimit > MAX_AGG_SIZE. See MaxTxnLimit
+	ErrBadBlockSize     = errors.New("Vectors do not match the block size") // AggProve and AggVerify take exactly TxnLimit proofs
+	ErrBadProofLength   = errors.New("Bad proof length")                    // Proofs and UPK paths have L points
+	ErrBadIndex         = errors.New("Index out of range")                  // Indices are less than N
+	ErrKeyFolderCorrupt = errors.New("Key folder is corrupt")               // Missing, truncated or inconsistent key files
+	ErrNoTrapdoors      = errors.New("Trapdoors are not available")
+	ErrNotInMemory      = errors.New("Keys are not in memory") // The UPK tree is mapped, see PARAM_TOO_LARGE
+)
+
+// Error of kind, one of the sentinels, with its own message.
+type vcsError struct {
+	kind error
+	msg  string
+}
+
+func (e *vcsError) Error() string {
+	return e.msg
+}
+
+func (e *vcsError) Unwrap() error {
+	return e.kind
+}
+
+func newError(kind error, format string, args ...interface{}) error {
+	return &vcsError{kind, fmt.Sprintf(format, args...)}
+}
+
+// Error reading or decoding a key file. Errors that are already of a kind are kept as is.
+func corrupt(name string, err error) error {
+	var e *vcsError
+	if errors.As(err, &e) {
+		return err
+	}
+	return newError(ErrKeyFolderCorrupt, "%s: %v", name, err)
+}
@@ -0,0 +1,107 @@
+package vcs
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/alinush/go-mcl"
+)
+
+// Bad parameters, malformed proofs and damaged key folders are reported instead of panicking.
+func TestErrors(t *testing.T) {
+
+	mcl.InitFromString("bls12-381")
+
+	L := uint8(6)
+	txnLimit := uint64(4)
+	expectKind := func(t *testing.T, err error, kind error) {
+		if !errors.Is(err, kind) {
+			t.Errorf("Expected %q, got %v", kind, err)
+		}
+	}
+
+	t.Run(fmt.Sprintf("%d/Init;%d", L, txnLimit), func(t *testing.T) {
+		vcs := VCS{}
+		expectKind(t, vcs.Init(0, t.TempDir(), txnLimit), ErrBadEll)
+		expectKind(t, vcs.Init(32, t.TempDir(), txnLimit), ErrBadEll)
+		expectKind(t, vcs.Init(26, t.TempDir(), MAX_AGG_SIZE), ErrBlockTooLarge)
+		check(vcs.Init(26, t.TempDir(), MaxTxnLimit(26)))
+	})
+
+	vcs, err := NewVCSInMemory(16, L, txnLimit)
+	check(err)
+	aFr := GenerateVector(vcs.N)
+	digest := vcs.Commit(aFr, uint64(L))
+	vcs.OpenAll(aFr)
+
+	indexVec := make([]uint64, txnLimit)
+	valueVec := make([]mcl.Fr, txnLimit)
+	proofVec := make([][]mcl.G1, txnLimit)
+	for k := range indexVec {
+		indexVec[k] = uint64(k) * 13 % vcs.N
+		valueVec[k] = aFr[indexVec[k]]
+		proofVec[k] = vcs.GetProofPath(indexVec[k])
+	}
+
+	t.Run(fmt.Sprintf("%d/MalformedProof;%d", L, txnLimit), func(t *testing.T) {
+		if !vcs.Verify(digest, indexVec[1], valueVec[1], proofVec[1]) {
+			t.Fatalf("Valid proof failed to verify")
+		}
+		if vcs.Verify(digest, indexVec[1], valueVec[1], proofVec[1][1:]) {
+			t.Errorf("Short proof was accepted")
+		}
+		if vcs.Verify(digest, vcs.N, valueVec[1], proofVec[1]) {
+			t.Errorf("Index out of range was accepted")
+		}
+		short := append([][]mcl.G1{}, proofVec...)
+		short[2] = short[2][:L-1]
+		if status, _ := vcs.VerifyMemoized(digest, indexVec, valueVec, short); status {
+			t.Errorf("Short proof was accepted by VerifyMemoized")
+		}
+		if status, _ := vcs.VerifyMemoized(digest, indexVec[1:], valueVec, proofVec); status {
+			t.Errorf("Mismatched vectors were accepted by VerifyMemoized")
+		}
+	})
+
+	t.Run(fmt.Sprintf("%d/Aggregate;%d", L, txnLimit), func(t *testing.T) {
+		_, err := vcs.AggProve(indexVec[1:], proofVec[1:])
+		expectKind(t, err, ErrBadBlockSize)
+		short := append([][]mcl.G1{}, proofVec...)
+		short[0] = short[0][1:]
+		_, err = vcs.AggProve(indexVec, short)
+		expectKind(t, err, ErrBadProofLength)
+
+		aggProof, err := vcs.AggProve(indexVec, proofVec)
+		check(err)
+		_, err = vcs.AggVerify(aggProof, digest, indexVec, valueVec[1:])
+		expectKind(t, err, ErrBadBlockSize)
+		aggProof.GipaKzgProof.L = aggProof.GipaKzgProof.L[1:]
+		if status, err := vcs.AggVerify(aggProof, digest, indexVec, valueVec); status || err != nil {
+			t.Errorf("Truncated aggregated proof: got %v, %v", status, err)
+		}
+	})
+
+	folder := t.TempDir()
+	keys := VCS{}
+	check(keys.KeyGen(16, L, folder, txnLimit))
+
+	damage := func(t *testing.T, name string, data []byte) {
+		original, err := os.ReadFile(folder + name)
+		check(err)
+		defer os.WriteFile(folder+name, original, 0644)
+		check(os.WriteFile(folder+name, data, 0644))
+		loaded := VCS{}
+		expectKind(t, loaded.KeyGenLoad(16, L, folder, txnLimit), ErrKeyFolderCorrupt)
+	}
+
+	t.Run(fmt.Sprintf("%d/KeyFolderCorrupt;%d", L, txnLimit), func(t *testing.T) {
+		damage(t, PPNAME, []byte{byte(L)})
+		damage(t, VRKNAME, nil)
+		damage(t, fmt.Sprintf(UPKNAME, 0), []byte{1, 2, 3})
+		loaded := VCS{}
+		expectKind(t, loaded.KeyGenLoad(16, L+1, folder, txnLimit), ErrBadEll)
+		check(loaded.KeyGenLoad(16, L, folder, txnLimit))
+	})
+}
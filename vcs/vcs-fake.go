@@ -1,26 +1,36 @@
 package vcs
 
 import (
-	"math/rand"
-
 	"github.com/alinush/go-mcl"
 )
 
 // Generate the trapdoors
 // Generate the keys for aggregation
 // PRK and UPK is generated only during runtime using GenUpkFake
-func (vcs *VCS) KeyGenFake(ncores uint8, L uint8, folder string, txnLimit uint64) {
+func (vcs *VCS) KeyGenFake(ncores uint8, L uint8, folder string, txnLimit uint64) error {
 
-	NCORES = ncores // Maximum number threads created. Set this to number of available cores.
-	vcs.Init(L, folder, txnLimit)
-	vcs.TrapdoorsGen()
-	vcs.GenAggGipa()
+	vcs.setCores(ncores)
+	if err := vcs.Init(L, folder, txnLimit); err != nil {
+		return err
+	}
+	if err := vcs.TrapdoorsGen(); err != nil {
+		return err
+	}
+	if err := vcs.GenAggGipa(); err != nil {
+		return err
+	}
+	return vcs.SaveManifest()
 }
 
-func (vcs *VCS) KeyGenLoadFake(ncores uint8, L uint8, folder string, txnLimit uint64) {
-	NCORES = ncores
-	vcs.Init(L, folder, txnLimit)
-	vcs.LoadTrapdoor(L)
+func (vcs *VCS) KeyGenLoadFake(ncores uint8, L uint8, folder string, txnLimit uint64) error {
+	vcs.setCores(ncores)
+	if err := vcs.Init(L, folder, txnLimit); err != nil {
+		return err
+	}
+	if err := vcs.LoadManifest(); err != nil {
+		return err
+	}
+	return vcs.LoadTrapdoor(L)
 	// vcs.LoadAggGipa() // No need to load this. We'll explicitly load this during every run.
 }
 
@@ -36,8 +46,8 @@ func (vcs *VCS) GenUpkFake(index uint64) []mcl.G1 {
 }
 
 // Goal is to generate a proof tree using trapdoors
+// Only for benchmarks: panics if the random source fails, see SetRandomSource.
 func (vcs *VCS) GenProofsTreeFake(count uint64) (mcl.G1, []uint64, []mcl.Fr, map[uint64][]mcl.G1, [][]mcl.G1, []map[uint64]mcl.G1) {
-	N := int64(vcs.N)
 	indexVec := make([]uint64, count)
 	fakeQTree := make([]map[uint64]mcl.Fr, vcs.L) // Each slice index is level and each position contains DB of sub-indices.
 	proofTree := make([]map[uint64]mcl.G1, vcs.L) // Each slice index is level and each position contains DB of sub-indices.
@@ -48,7 +58,8 @@ func (vcs *VCS) GenProofsTreeFake(count uint64) (mcl.G1, []uint64, []mcl.Fr, map
 
 	// Genrate random indices
 	for k := uint64(0); k < count; k++ {
-		id := uint64(rand.Int63n(N)) // Pick an index from the saved list of vector positions. Could contain duplicates as well.
+		id, err := RandomIndex(vcs.rand, vcs.N) // Pick an index from the saved list of vector positions. Could contain duplicates as well.
+		check(err)
 		indexVec[k] = id
 	}
 
@@ -57,7 +68,7 @@ func (vcs *VCS) GenProofsTreeFake(count uint64) (mcl.G1, []uint64, []mcl.Fr, map
 		proofTree[l] = make(map[uint64]mcl.G1)
 	}
 
-	f_a.Random()
+	check(RandomFr(vcs.rand, &f_a))
 	mcl.G1Mul(&digest, &vcs.G, &f_a)
 
 	// Set f_a. Populate random quotients and compute the vector element.
@@ -74,7 +85,7 @@ func (vcs *VCS) GenProofsTreeFake(count uint64) (mcl.G1, []uint64, []mcl.Fr, map
 			q, ok := fakeQTree[vcs.L-l-1][id]
 			pi, _ := proofTree[vcs.L-l-1][id]
 			if !ok {
-				q.Random()
+				check(RandomFr(vcs.rand, &q))
 				fakeQTree[vcs.L-l-1][id] = q
 
 				mcl.G1Mul(&pi, &vcs.G, &q)
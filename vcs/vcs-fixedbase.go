@@ -0,0 +1,131 @@
+// Faster keygen: fixed-base scalar multiplication and incremental exponents.
+// Every UPK and PRK point is G raised to some exponent. A table of G^(d * 256^i) for all bytes d turns each G1Mul into 32 additions.
+// Exponents of consecutive nodes differ in a few trapdoors, thus each is derived from the previous one with a couple of FrMul.
+package vcs
+
+import (
+	"github.com/alinush/go-mcl"
+)
+
+// Window of 8 bits, i.e., one byte of the little endian encoding of the exponent.
+type FixedBaseG1 struct {
+	table [][256]mcl.G1 // table[i][d] = base^(d * 256^i)
+}
+
+func NewFixedBaseG1(base *mcl.G1) *FixedBaseG1 {
+
+	windows := GetFrByteSize()
+	fb := FixedBaseG1{make([][256]mcl.G1, windows)}
+	power := *base
+	for i := 0; i < windows; i++ {
+		fb.table[i][0].Clear()
+		for d := 1; d < 256; d++ {
+			mcl.G1Add(&fb.table[i][d], &fb.table[i][d-1], &power)
+		}
+		mcl.G1Add(&power, &fb.table[i][255], &power) // base^(256^(i+1))
+	}
+	return &fb
+}
+
+// out = base^x
+func (fb *FixedBaseG1) Mul(out *mcl.G1, x *mcl.Fr) {
+	var result mcl.G1
+	result.Clear()
+	for i, d := range x.Serialize() {
+		if d != 0 {
+			mcl.G1Add(&result, &result, &fb.table[i][d])
+		}
+	}
+	*out = result
+}
+
+// Ratios of the factors of each trapdoor, used to flip a bit of the index.
+// nil if some s_i is 0 or 1, e.g., at the genesis of the ceremony. Then, exponents are always computed from scratch.
+type trapdoorRatios struct {
+	up   []mcl.Fr // s_i / (1 - s_i)
+	down []mcl.Fr // (1 - s_i) / s_i
+	inv  []mcl.Fr // 1 / s_i
+}
+
+func (vcs *VCS) trapdoorRatios() *trapdoorRatios {
+
+	if !vcs.hasTrapdoors {
+		panic("Trapdoors are not available")
+	}
+	r := trapdoorRatios{make([]mcl.Fr, vcs.L), make([]mcl.Fr, vcs.L), make([]mcl.Fr, vcs.L)}
+	var invSubOne mcl.Fr
+	for i := uint8(0); i < vcs.L; i++ {
+		if vcs.trapdoors[i].IsZero() || vcs.trapdoorsSubOne[i].IsZero() {
+			return nil
+		}
+		mcl.FrInv(&r.inv[i], &vcs.trapdoors[i])
+		mcl.FrInv(&invSubOne, &vcs.trapdoorsSubOne[i])
+		mcl.FrMul(&r.up[i], &vcs.trapdoors[i], &invSubOne)
+		mcl.FrMul(&r.down[i], &vcs.trapdoorsSubOne[i], &r.inv[i])
+	}
+	return &r
+}
+
+// Exponents of the UPK nodes, visited in level order.
+// e(l, k) = e(l-1, k mod 2^(l-1)) * f_{l-1}, where f is (1 - s) or s depending on the bit l - 1 of k.
+// Going from k to k + 1 flips the trailing ones and the following zero, hence e(l, k + 1) is e(l, k) times the ratios of those bits.
+type upkExponents struct {
+	vcs    *VCS
+	ratios *trapdoorRatios
+	l      uint8
+	k      uint64
+	e      mcl.Fr
+	valid  bool
+}
+
+func (it *upkExponents) Next(j uint64) mcl.Fr {
+
+	l, k := IndexInTheLevel(j)
+	if it.ratios == nil || !it.valid || l != it.l || k != it.k+1 {
+		it.e = it.vcs.SelectUPK(l, k)
+	} else {
+		flipped := it.k ^ k
+		for i := uint8(0); flipped>>i != 0; i++ {
+			if flipped&(1<<i) == 0 {
+				continue
+			}
+			if k&(1<<i) != 0 {
+				mcl.FrMul(&it.e, &it.e, &it.ratios.up[i])
+			} else {
+				mcl.FrMul(&it.e, &it.e, &it.ratios.down[i])
+			}
+		}
+	}
+	it.l, it.k, it.valid = l, k, true
+	return it.e
+}
+
+// Exponents of PRK, visited in order. e(i) is the product of s_j for the bits j set in i.
+type prkExponents struct {
+	vcs    *VCS
+	ratios *trapdoorRatios
+	i      uint64
+	e      mcl.Fr
+	valid  bool
+}
+
+func (it *prkExponents) Next(i uint64) mcl.Fr {
+
+	if it.ratios == nil || !it.valid || i != it.i+1 {
+		it.e = it.vcs.SelectPRK(i)
+	} else {
+		flipped := it.i ^ i
+		for b := uint8(0); flipped>>b != 0; b++ {
+			if flipped&(1<<b) == 0 {
+				continue
+			}
+			if i&(1<<b) != 0 {
+				mcl.FrMul(&it.e, &it.e, &it.vcs.trapdoors[b])
+			} else {
+				mcl.FrMul(&it.e, &it.e, &it.ratios.inv[b])
+			}
+		}
+	}
+	it.i, it.valid = i, true
+	return it.e
+}
@@ -0,0 +1,62 @@
+package vcs
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/alinush/go-mcl"
+)
+
+func TestFixedBase(t *testing.T) {
+
+	mcl.InitFromString("bls12-381")
+
+	L := uint8(8)
+	N := uint64(1) << L
+
+	vcs := VCS{Config: Config{NCores: 16}}
+	check(vcs.Init(L, t.TempDir(), 8))
+	vcs.DISCARD_PRK = false
+	check(vcs.TrapdoorsSample())
+	check(vcs.PrkUpkGen())
+
+	t.Run(fmt.Sprintf("%d/Mul;", L), func(t *testing.T) {
+		table := NewFixedBaseG1(&vcs.G)
+		scalars := GenerateVector(64)
+		scalars[0].Clear()
+		scalars[1].SetInt64(1)
+		scalars[2].SetInt64(-1)
+		for i := range scalars {
+			var expected, result mcl.G1
+			mcl.G1Mul(&expected, &vcs.G, &scalars[i])
+			table.Mul(&result, &scalars[i])
+			if !result.IsEqual(&expected) {
+				t.Fatalf("Mul mismatch for scalar %d", i)
+			}
+		}
+	})
+
+	t.Run(fmt.Sprintf("%d/UpkGen;%d", L, N), func(t *testing.T) {
+		var expected mcl.G1
+		for l := uint8(0); l <= L; l++ {
+			for k := range vcs.UPK[l] {
+				exponent := vcs.SelectUPK(l, uint64(k))
+				mcl.G1Mul(&expected, &vcs.G, &exponent)
+				if !vcs.UPK[l][k].IsEqual(&expected) {
+					t.Fatalf("UPK mismatch at level %d, index %d", l, k)
+				}
+			}
+		}
+	})
+
+	t.Run(fmt.Sprintf("%d/PrkGen;%d", L, N), func(t *testing.T) {
+		var expected mcl.G1
+		for i := uint64(0); i < N; i++ {
+			exponent := vcs.SelectPRK(i)
+			mcl.G1Mul(&expected, &vcs.G, &exponent)
+			if !vcs.PRK[i].IsEqual(&expected) {
+				t.Fatalf("PRK mismatch at index %d", i)
+			}
+		}
+	})
+}
@@ -4,14 +4,10 @@ import (
 	"github.com/alinush/go-mcl"
 )
 
-var PRKNAME string
-var VRKNAME string
-var UPKNAME string
-var TRAPDOORNAME string
-var NFILES uint8
-var NCORES uint8
-
 const MAX_AGG_SIZE = 1 << 19
+const LOAD_CHUNK = 1 << 14    // Points per read when loading the keys
+const PROGRESS_STEP = 1 << 12 // Points per progress report during keygen
+const CANCEL_CHECK = 1 << 14  // Points processed between two checks of the context. See vcs-context.go
 const SEP = "\n========================================================================================"
 
 // Allocate space for UPK.
@@ -27,6 +23,9 @@ func (vcs *VCS) MallocUpk() {
 // 101 => (s_3 * s_1)
 // It is a worker to compute g, g^{s_1}, g^{s_2}, g^{s_1 s_2}, g^{s_3}, , g^{s_3 s_1} ...
 func (vcs *VCS) SelectPRK(index uint64) mcl.Fr {
+	if !vcs.hasTrapdoors {
+		panic("Select PRK error: Trapdoors are not available")
+	}
 	var prod mcl.Fr
 	var out mcl.Fr
 	prod.SetInt64(1)
@@ -49,6 +48,9 @@ func (vcs *VCS) SelectUPK(L uint8, index uint64) mcl.Fr {
 	if L > vcs.L {
 		panic("Select UPK error")
 	}
+	if !vcs.hasTrapdoors {
+		panic("Select UPK error: Trapdoors are not available")
+	}
 
 	var prod mcl.Fr
 	var out mcl.Fr
@@ -67,14 +69,18 @@ func (vcs *VCS) SelectUPK(L uint8, index uint64) mcl.Fr {
 
 func (vcs *VCS) VerifyUPK(index uint64, upkProof []mcl.G1) bool {
 
+	if len(upkProof) != int(vcs.L) || index >= vcs.N {
+		return false
+	}
+
 	binary := ToBinary(index, vcs.L)
 	var temp1, temp2, result mcl.G1
 	var r mcl.Fr
 	r.SetInt64(1)
 	if binary[0] == false {
-		temp1 = vcs.UPK[1][0]
+		temp1 = vcs.UpkNode(1, 0)
 	} else {
-		temp1 = vcs.UPK[1][1]
+		temp1 = vcs.UpkNode(1, 1)
 	}
 
 	// Linear Combination is an option
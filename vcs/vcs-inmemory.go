@@ -0,0 +1,110 @@
+// Keys generated in memory, e.g., for tests and applications that embed a VCS.
+// Nothing is written to disk. Files, i.e., the aggregation keys, go to a MemKeyStore unless Store is set.
+// The aggregation keys are sized to L * TxnLimit instead of MAX_AGG_SIZE. Thus, ResizeAgg only works for smaller TxnLimit.
+package vcs
+
+import (
+	"context"
+	"runtime"
+	"sync"
+
+	"github.com/alinush/go-mcl"
+	"github.com/hyperproofs/gipa-go/utils"
+)
+
+// Ready-to-use instance with UPK, VRK and the aggregation keys in memory. ell must be at most PARAM_TOO_LARGE_ELL.
+func NewVCSInMemory(ncores uint8, L uint8, txnLimit uint64) (*VCS, error) {
+	vcs := VCS{}
+	if err := vcs.KeyGenInMemory(ncores, L, txnLimit); err != nil {
+		return nil, err
+	}
+	return &vcs, nil
+}
+
+// Same as KeyGen, but in memory. PRK is generated only with WithPRK.
+// Trapdoors are kept, as with KeyGen. Use ZeroizeTrapdoors to discard them.
+func (vcs *VCS) KeyGenInMemory(ncores uint8, L uint8, txnLimit uint64) error {
+
+	if L > vcs.Config.withDefaults().MemoryEll {
+		return newError(ErrBadEll, "KeyGenInMemory: ell = %d is too large to keep the keys in memory. Use KeyGen", L)
+	}
+	if err := vcs.KeyGenFakeInMemory(ncores, L, txnLimit); err != nil {
+		return err
+	}
+	vcs.upkGenInMemory()
+	if !vcs.DISCARD_PRK {
+		vcs.prkGenInMemory()
+	}
+	return nil
+}
+
+// Same as KeyGenFake followed by KeyGenLoadFake and LoadAggGipa, but in memory.
+// Only the trapdoors and the aggregation keys are generated. See vcs-fake.go
+func (vcs *VCS) KeyGenFakeInMemory(ncores uint8, L uint8, txnLimit uint64) error {
+
+	vcs.setCores(ncores)
+	if vcs.Store == nil {
+		vcs.Store = NewMemKeyStore()
+	}
+	if err := vcs.Init(L, "memory", txnLimit); err != nil {
+		return err
+	}
+	if err := vcs.TrapdoorsSample(); err != nil {
+		return err
+	}
+	return vcs.genAggGipa(context.Background(), utils.NextPowOf2(uint64(L)*txnLimit))
+}
+
+func (vcs *VCS) upkGenInMemory() {
+
+	vcs.MallocUpk()
+	vcs.gTable = NewFixedBaseG1(&vcs.G)
+	vcs.ratios = vcs.trapdoorRatios()
+	numUPK := (uint64(1) << (vcs.L + 1)) - 1
+	genInMemory(vcs.NCores, numUPK, func(start uint64, stop uint64) {
+		exponents := upkExponents{vcs: vcs, ratios: vcs.ratios}
+		for j := start; j < stop; j++ {
+			i, k := IndexInTheLevel(j)
+			exponent := exponents.Next(j)
+			vcs.gTable.Mul(&vcs.UPK[i][k], &exponent)
+		}
+	})
+}
+
+func (vcs *VCS) prkGenInMemory() {
+
+	vcs.PRK = make([]mcl.G1, vcs.N)
+	vcs.gTable = NewFixedBaseG1(&vcs.G)
+	vcs.ratios = vcs.trapdoorRatios()
+	genInMemory(vcs.NCores, vcs.N, func(start uint64, stop uint64) {
+		exponents := prkExponents{vcs: vcs, ratios: vcs.ratios}
+		for i := start; i < stop; i++ {
+			exponent := exponents.Next(i)
+			vcs.gTable.Mul(&vcs.PRK[i], &exponent)
+		}
+	})
+}
+
+// Split [0, total) into one range per core, up to ncores, and call gen on each of them in parallel.
+func genInMemory(ncores uint8, total uint64, gen func(start uint64, stop uint64)) {
+
+	workers := int(ncores)
+	if workers == 0 {
+		workers = runtime.NumCPU()
+	}
+	workers = int(minUint64(uint64(workers), 255))
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		start, stop := ShardBounds(total, uint8(workers), uint8(i))
+		if start == stop {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			gen(start, stop)
+		}()
+	}
+	wg.Wait()
+}
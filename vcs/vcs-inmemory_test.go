@@ -0,0 +1,55 @@
+package vcs
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/alinush/go-mcl"
+)
+
+// With the same seed, the in-memory keys must match those of KeyGen.
+func TestKeyGenInMemory(t *testing.T) {
+
+	mcl.InitFromString("bls12-381")
+
+	L := uint8(8)
+	N := uint64(1) << L
+	txnLimit := uint64(8)
+	seed := []byte("in-memory")
+
+	disk := VCS{Config: Config{WithPRK: true}}
+	disk.SetSeed(seed)
+	check(disk.KeyGen(16, L, t.TempDir(), txnLimit))
+
+	mem := VCS{Config: Config{WithPRK: true}}
+	mem.SetSeed(seed)
+	check(mem.KeyGenInMemory(16, L, txnLimit))
+
+	t.Run(fmt.Sprintf("%d/Keys;%d", L, N), func(t *testing.T) {
+		for i := range disk.VRK {
+			if !disk.VRK[i].IsEqual(&mem.VRK[i]) {
+				t.Fatalf("VRK mismatch at %d", i)
+			}
+		}
+		for l := range disk.UPK {
+			if !SliceIsEqual(disk.UPK[l], mem.UPK[l]) {
+				t.Fatalf("UPK mismatch at level %d", l)
+			}
+		}
+		if !SliceIsEqual(disk.PRK, mem.PRK) {
+			t.Errorf("PRK mismatch")
+		}
+	})
+
+	t.Run(fmt.Sprintf("%d/AggKeys;%d", L, txnLimit), func(t *testing.T) {
+		if mem.MN != disk.MN || len(mem.ck.W) != len(disk.ck.W) {
+			t.Fatalf("Aggregation keys have %d points, expected %d", len(mem.ck.W), len(disk.ck.W))
+		}
+		if !SliceIsEqual(mem.ck.W, disk.ck.W) || !SliceIsEqual(mem.kzg1.PK, disk.kzg1.PK) {
+			t.Errorf("Aggregation keys mismatch")
+		}
+		if _, ok := mem.store.(*MemKeyStore); !ok {
+			t.Errorf("Keys are not kept in memory")
+		}
+	})
+}
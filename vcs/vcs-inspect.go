@@ -0,0 +1,231 @@
+// Inspection of a key folder without loading it.
+// InspectKeys reads the headers and the manifest, and estimates the memory needed to serve the keys.
+// CheckKeys optionally loads the keys and runs the consistency checks. See vcs-validate.go
+package vcs
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"unsafe"
+
+	"github.com/alinush/go-mcl"
+	"github.com/hyperproofs/gipa-go/utils"
+)
+
+type KeyFileInfo struct {
+	Name  string
+	Start uint64 // Range of points for the UPK and PRK shards, as in ManifestEntry
+	Stop  uint64
+	Size  uint64 // In bytes
+}
+
+type KeyFolderInfo struct {
+	L         uint8
+	N         uint64
+	NumShards uint8
+
+	HasManifest     bool
+	HasPublicParams bool
+	HasTrapdoors    bool
+	HasPRK          bool
+	HasAggKeys      bool
+	HasTranscript   bool
+
+	MN uint64 // Largest GIPA instance the aggregation keys support, i.e., L * TxnLimit up to MN
+
+	Files     []KeyFileInfo
+	TotalSize uint64 // In bytes
+
+	// Estimates in bytes for TxnLimit. Full mode holds the UPK tree and the proof tree. Pruned mode only the paths of a block.
+	RAMAggKeys uint64
+	RAMFull    uint64
+	RAMPrk     uint64 // Extra, if PRK is loaded
+	RAMPruned  uint64
+}
+
+// Read the key folder and estimate its memory footprint for blocks of txnLimit updates.
+// Files are not checked. Use CheckKeys for that. The instance is only used for the store, i.e., Store or folder.
+func (vcs *VCS) InspectKeys(folder string, txnLimit uint64) (KeyFolderInfo, error) {
+
+	var info KeyFolderInfo
+	if err := vcs.Init(1, folder, 1); err != nil { // Sets the file names and the store
+		return info, err
+	}
+	store := vcs.store
+	names, err := store.List()
+	if err != nil {
+		return info, err
+	}
+	if len(names) == 0 {
+		return info, fmt.Errorf("%s: No key files", folder)
+	}
+
+	var manifest Manifest
+	if f, err := store.Open(vcs.Names.Manifest); err == nil {
+		manifest, err = ReadManifest(f)
+		f.Close()
+		if err != nil {
+			return info, fmt.Errorf("%s: %v", folder, err)
+		}
+		info.HasManifest = true
+		info.L = manifest.L
+		info.NumShards = manifest.NumShards
+	}
+
+	// ell is also the first field of the public parameters and the trapdoors.
+	for _, name := range []string{vcs.Names.PP, vcs.Names.Trapdoor} {
+		L, err := readHeader(store, name)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return info, fmt.Errorf("%s: %v", folder, err)
+		}
+		if info.L == 0 {
+			info.L = uint8(L)
+		}
+		info.HasPublicParams = info.HasPublicParams || name == vcs.Names.PP
+		info.HasTrapdoors = info.HasTrapdoors || name == vcs.Names.Trapdoor
+	}
+	if info.L == 0 || info.L >= 32 {
+		return info, fmt.Errorf("%s: Unable to tell ell, found %d", folder, info.L)
+	}
+	info.N = uint64(1) << info.L
+
+	byName := make(map[string]ManifestEntry)
+	for _, entry := range manifest.Entries {
+		byName[entry.Name] = entry
+	}
+	shards := uint8(0)
+	for _, name := range names {
+		if _, ok := shardNumber(vcs.Names.UPK, name); ok {
+			shards++
+		}
+	}
+	if info.NumShards == 0 {
+		info.NumShards = shards
+	}
+
+	numUPK := (uint64(1) << (info.L + 1)) - 1
+	for _, name := range names {
+		size, err := store.Size(name)
+		if err != nil {
+			return info, err
+		}
+		file := KeyFileInfo{Name: name, Size: uint64(size)}
+		upk, isUPK := shardNumber(vcs.Names.UPK, name)
+		prk, isPRK := shardNumber(vcs.Names.PRK, name)
+		if entry, ok := byName[name]; ok {
+			file.Start, file.Stop = entry.Start, entry.Stop
+		} else if isUPK && info.NumShards != 0 {
+			file.Start, file.Stop = ShardBounds(numUPK, info.NumShards, upk)
+		} else if isPRK && info.NumShards != 0 {
+			file.Start, file.Stop = ShardBounds(info.N, info.NumShards, prk)
+		}
+		info.HasPRK = info.HasPRK || isPRK
+		info.HasTranscript = info.HasTranscript || name == vcs.Names.Ceremony
+		info.Files = append(info.Files, file)
+		info.TotalSize += file.Size
+	}
+	sort.Slice(info.Files, func(a, b int) bool { return info.Files[a].Name < info.Files[b].Name })
+
+	ckM, errCK := readHeader(store, vcs.Names.CK)
+	degree, errKZG := readHeader(store, vcs.Names.KZG)
+	if errCK == nil && errKZG == nil {
+		info.HasAggKeys = true
+		info.MN = minUint64(ckM, (degree+1)/2)
+	}
+
+	info.estimateRAM(txnLimit)
+	return info, nil
+}
+
+// First 8 bytes of the file, i.e., ell or the number of keys.
+func readHeader(store KeyStore, name string) (uint64, error) {
+	f, err := store.Open(name)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	data := make([]byte, 8)
+	if _, err = io.ReadFull(f, data); err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint64(data), nil
+}
+
+func (info *KeyFolderInfo) estimateRAM(txnLimit uint64) {
+
+	g1 := uint64(unsafe.Sizeof(mcl.G1{}))
+	g2 := uint64(unsafe.Sizeof(mcl.G2{}))
+	L := uint64(info.L)
+
+	mn := utils.NextPowOf2(L * txnLimit) // Same as LoadAggGipa
+	info.RAMAggKeys = mn*(g1+g2) + (2*mn+1)*(g1+g2)
+
+	vrk := 3 * L * g2
+	upk := (2*info.N - 1) * g1
+	proofTree := (info.N - 1) * g1
+	info.RAMFull = upk + proofTree + vrk + info.RAMAggKeys
+	info.RAMPrk = info.N * g1
+	info.RAMPruned = 2*txnLimit*L*g1 + vrk + info.RAMAggKeys // upk_db and the proof tree of a block
+}
+
+// Verify the folder: checksums of the manifest, the consistency of the parameters (ValidateParams),
+// and the UPK paths of spotChecks random indices (VerifyUPK).
+// ValidateParams needs the UPK tree in memory, thus it is skipped for large ell, i.e., PARAM_TOO_LARGE.
+func (vcs *VCS) CheckKeys(ncores uint8, L uint8, folder string, spotChecks int) error {
+
+	vcs.setCores(ncores)
+	if err := vcs.Init(L, folder, 1); err != nil {
+		return err
+	}
+	if err := vcs.LoadManifest(); err != nil {
+		return err
+	}
+	vcs.log(SEP, "Verifying the checksums", SEP)
+	if err := vcs.VerifyChecksums(); err != nil {
+		return err
+	}
+	if err := vcs.LoadParams(L); err != nil {
+		return err
+	}
+	if _, err := vcs.store.Size(fmt.Sprintf(vcs.Names.UPK, 0)); err != nil {
+		vcs.log(SEP, "No UPK to check, e.g., a folder of KeyGenFake", SEP)
+		return nil
+	}
+	_, errAgg := vcs.store.Size(vcs.Names.CK)
+
+	if !vcs.PARAM_TOO_LARGE {
+		vcs.log(SEP, "Validating the parameters", SEP)
+		if err := vcs.UpkLoadDriver(); err != nil {
+			return err
+		}
+		if errAgg == nil {
+			if err := vcs.LoadAggGipa(); err != nil {
+				return err
+			}
+		}
+		if err := vcs.ValidateParams(); err != nil {
+			return fmt.Errorf("%s: %v", folder, err)
+		}
+	} else {
+		vcs.log(SEP, "Skipped ValidateParams, ell is too large", SEP)
+		if err := vcs.UpkMapDriver(); err != nil {
+			return err
+		}
+		defer vcs.UpkUnmap()
+	}
+
+	vcs.log(SEP, "Checking", spotChecks, "random UPK paths", SEP)
+	for i := 0; i < spotChecks; i++ {
+		index, _ := RandomIndex(nil, vcs.N) // Never fails without a reader
+		if !vcs.VerifyUPK(index, vcs.GetUpk(index)) {
+			return fmt.Errorf("%s: UPK of index %d does not verify", folder, index)
+		}
+	}
+	return nil
+}
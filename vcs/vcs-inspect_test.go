@@ -0,0 +1,76 @@
+package vcs
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/alinush/go-mcl"
+	"github.com/hyperproofs/gipa-go/utils"
+)
+
+// Same folder as KeyGen, but the aggregation keys only support blocks of txnLimit instead of MAX_AGG_SIZE.
+func keyGenSmallAgg(vcs *VCS, L uint8, folder string, txnLimit uint64) {
+	vcs.setCores(16)
+	check(vcs.Init(L, folder, txnLimit))
+	check(vcs.TrapdoorsGen())
+	check(vcs.PrkUpkGen())
+	check(vcs.genAggGipa(context.Background(), utils.NextPowOf2(uint64(L)*txnLimit)))
+	check(vcs.SaveManifest())
+}
+
+func TestInspectKeys(t *testing.T) {
+
+	mcl.InitFromString("bls12-381")
+
+	L := uint8(8)
+	folder := t.TempDir()
+	keys := VCS{Config: Config{WithPRK: true}}
+	keyGenSmallAgg(&keys, L, folder, 8)
+
+	t.Run(fmt.Sprintf("%d/Inspect;", L), func(t *testing.T) {
+		vcs := VCS{}
+		info, err := vcs.InspectKeys(folder, 8)
+		check(err)
+		if info.L != L || info.N != keys.N || info.NumShards != keys.nfiles {
+			t.Fatalf("Got ell = %d, N = %d, %d shards", info.L, info.N, info.NumShards)
+		}
+		if !info.HasManifest || !info.HasTrapdoors || !info.HasPRK || !info.HasAggKeys || info.HasTranscript {
+			t.Errorf("Wrong artifacts: %+v", info)
+		}
+		if info.MN != keys.MN {
+			t.Errorf("MN is %d, expected %d", info.MN, keys.MN)
+		}
+		total := uint64(0)
+		for _, file := range info.Files {
+			total += file.Size
+			if file.Name == fmt.Sprintf(UPKNAME, 1) && file.Size != (file.Stop-file.Start)*uint64(GetG1ByteSize()) {
+				t.Errorf("Range of %s does not match its size", file.Name)
+			}
+		}
+		if total != info.TotalSize || info.RAMPruned >= info.RAMFull {
+			t.Errorf("Bad totals: %+v", info)
+		}
+	})
+
+	t.Run(fmt.Sprintf("%d/CheckKeys;", L), func(t *testing.T) {
+		vcs := VCS{}
+		if err := vcs.CheckKeys(16, L, folder, 8); err != nil {
+			t.Fatal(err)
+		}
+
+		// A valid point in the wrong place, with the manifest updated to match.
+		f, err := os.OpenFile(folder+fmt.Sprintf(UPKNAME, 2), os.O_WRONLY, 0)
+		check(err)
+		_, err = f.WriteAt(keys.G.Serialize(), 0)
+		check(err)
+		f.Close()
+		check(keys.SaveManifest())
+
+		bad := VCS{}
+		if err := bad.CheckKeys(16, L, folder, 8); err == nil {
+			t.Errorf("Corrupted UPK was not detected")
+		}
+	})
+}
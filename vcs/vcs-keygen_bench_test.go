@@ -0,0 +1,47 @@
+package vcs
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/alinush/go-mcl"
+)
+
+var ellKeyGen = []uint8{20, 22, 24, 26}
+
+// Per-node cost of UPK generation: SelectUPK and G1Mul (the old path) against incremental exponents and the fixed-base table.
+// Nodes are the first keyGenNodes of the last level, i.e., those with ell variables.
+func BenchmarkUpkGen(b *testing.B) {
+
+	mcl.InitFromString("bls12-381")
+	keyGenNodes := uint64(1) << 12
+
+	for _, L := range ellKeyGen {
+		vcs := VCS{}
+		check(vcs.Init(L, b.TempDir(), 1))
+		check(vcs.TrapdoorsSample())
+		first := (uint64(1) << L) - 1 // Position of UPK[L][0]
+
+		b.Run(fmt.Sprintf("%d/SelectUPK;%d", L, keyGenNodes), func(b *testing.B) {
+			var result mcl.G1
+			for bn := 0; bn < b.N; bn++ {
+				for k := uint64(0); k < keyGenNodes; k++ {
+					exponent := vcs.SelectUPK(L, k)
+					mcl.G1Mul(&result, &vcs.G, &exponent)
+				}
+			}
+		})
+
+		b.Run(fmt.Sprintf("%d/FixedBase;%d", L, keyGenNodes), func(b *testing.B) {
+			var result mcl.G1
+			for bn := 0; bn < b.N; bn++ {
+				table := NewFixedBaseG1(&vcs.G) // Once per shard in UpkGenDriver
+				exponents := upkExponents{vcs: &vcs, ratios: vcs.trapdoorRatios()}
+				for j := first; j < first+keyGenNodes; j++ {
+					exponent := exponents.Next(j)
+					table.Mul(&result, &exponent)
+				}
+			}
+		})
+	}
+}
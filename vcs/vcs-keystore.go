@@ -0,0 +1,301 @@
+// Storage of the key files.
+// All keys are saved and loaded through a KeyStore by file name, e.g., UPKNAME or VRKNAME.
+// DirKeyStore is a folder on disk (the default, see Init). MemKeyStore keeps the files in memory and TarKeyStore reads and writes them as a tar archive.
+package vcs
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+type KeyReader interface {
+	io.Reader
+	io.ReaderAt
+	io.Closer
+}
+
+// Missing files are reported with an error that satisfies os.IsNotExist.
+type KeyStore interface {
+	Create(name string) (io.WriteCloser, error) // Truncates the file if it exists
+	Append(name string) (io.WriteCloser, error)
+	Open(name string) (KeyReader, error)
+	Size(name string) (int64, error)
+	Rename(from string, to string) error
+	Remove(name string) error
+	List() ([]string, error) // Names of all the files
+}
+
+// Optional. Stores that implement this are mapped by UpkMapDriver and PrkMapDriver instead of read with ReadAt.
+// DirKeyStore implements it on unix, see vcs-keystore_unix.go
+type KeyMapper interface {
+	Map(name string, size int) ([]byte, error)
+	Unmap(data []byte) error
+}
+
+// Files of a folder. Names are appended to the folder, e.g., "pkvk-26" + "/upk-00.data".
+type DirKeyStore struct {
+	Dir string
+}
+
+func NewDirKeyStore(dir string) *DirKeyStore {
+	return &DirKeyStore{dir}
+}
+
+func (d *DirKeyStore) Create(name string) (io.WriteCloser, error) {
+	if err := os.MkdirAll(d.Dir, os.ModePerm); err != nil {
+		return nil, err
+	}
+	return os.Create(d.Dir + name)
+}
+
+func (d *DirKeyStore) Append(name string) (io.WriteCloser, error) {
+	if err := os.MkdirAll(d.Dir, os.ModePerm); err != nil {
+		return nil, err
+	}
+	return os.OpenFile(d.Dir+name, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+}
+
+func (d *DirKeyStore) Open(name string) (KeyReader, error) {
+	return os.Open(d.Dir + name)
+}
+
+func (d *DirKeyStore) Size(name string) (int64, error) {
+	info, err := os.Stat(d.Dir + name)
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+func (d *DirKeyStore) Rename(from string, to string) error {
+	return os.Rename(d.Dir+from, d.Dir+to)
+}
+
+func (d *DirKeyStore) Remove(name string) error {
+	return os.Remove(d.Dir + name)
+}
+
+func (d *DirKeyStore) List() ([]string, error) {
+	entries, err := os.ReadDir(d.Dir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			names = append(names, "/"+entry.Name())
+		}
+	}
+	return names, nil
+}
+
+// Files in memory. Safe for concurrent use. A file becomes visible once its writer is closed.
+type MemKeyStore struct {
+	mu    sync.Mutex
+	files map[string][]byte
+}
+
+func NewMemKeyStore() *MemKeyStore {
+	return &MemKeyStore{files: make(map[string][]byte)}
+}
+
+type memWriter struct {
+	bytes.Buffer
+	store  *MemKeyStore
+	name   string
+	closed bool
+}
+
+// Only the first Close writes the file, as with os.File.
+func (w *memWriter) Close() error {
+	if w.closed {
+		return fs.ErrClosed
+	}
+	w.closed = true
+	w.store.mu.Lock()
+	defer w.store.mu.Unlock()
+	w.store.files[w.name] = w.Bytes()
+	return nil
+}
+
+type memReader struct {
+	*bytes.Reader
+}
+
+func (r memReader) Close() error {
+	return nil
+}
+
+func notExist(op string, name string) error {
+	return &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+}
+
+func (m *MemKeyStore) Create(name string) (io.WriteCloser, error) {
+	return &memWriter{store: m, name: name}, nil
+}
+
+func (m *MemKeyStore) Append(name string) (io.WriteCloser, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	w := memWriter{store: m, name: name}
+	w.Write(m.files[name])
+	return &w, nil
+}
+
+func (m *MemKeyStore) Open(name string) (KeyReader, error) {
+	data, ok := m.get(name)
+	if !ok {
+		return nil, notExist("open", name)
+	}
+	return memReader{bytes.NewReader(data)}, nil
+}
+
+func (m *MemKeyStore) Size(name string) (int64, error) {
+	data, ok := m.get(name)
+	if !ok {
+		return 0, notExist("stat", name)
+	}
+	return int64(len(data)), nil
+}
+
+func (m *MemKeyStore) Rename(from string, to string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data, ok := m.files[from]
+	if !ok {
+		return notExist("rename", from)
+	}
+	delete(m.files, from)
+	m.files[to] = data
+	return nil
+}
+
+func (m *MemKeyStore) Remove(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.files[name]; !ok {
+		return notExist("remove", name)
+	}
+	delete(m.files, name)
+	return nil
+}
+
+func (m *MemKeyStore) List() ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	names := make([]string, 0, len(m.files))
+	for name := range m.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (m *MemKeyStore) Map(name string, size int) ([]byte, error) {
+	data, ok := m.get(name)
+	if !ok {
+		return nil, notExist("map", name)
+	}
+	if len(data) < size {
+		return nil, errors.New("MemKeyStore: " + name + " is too short")
+	}
+	return data[:size], nil
+}
+
+func (m *MemKeyStore) Unmap(data []byte) error {
+	return nil
+}
+
+func (m *MemKeyStore) get(name string) ([]byte, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data, ok := m.files[name]
+	return data, ok
+}
+
+// Files of a tar archive. The archive is read into memory, and WriteTo writes all the files out as a new archive.
+type TarKeyStore struct {
+	*MemKeyStore
+}
+
+// Read the archive from r. nil starts with an empty store.
+func NewTarKeyStore(r io.Reader) (*TarKeyStore, error) {
+
+	t := TarKeyStore{NewMemKeyStore()}
+	if r == nil {
+		return &t, nil
+	}
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+		t.files["/"+strings.TrimPrefix(filepath.ToSlash(header.Name), "/")] = data
+	}
+	return &t, nil
+}
+
+func (t *TarKeyStore) WriteTo(w io.Writer) (int64, error) {
+
+	names, _ := t.List()
+	tw := tar.NewWriter(w)
+	total := int64(0)
+	for _, name := range names {
+		data, _ := t.get(name)
+		header := tar.Header{Name: strings.TrimPrefix(name, "/"), Mode: 0644, Size: int64(len(data)), Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(&header); err != nil {
+			return total, err
+		}
+		n, err := tw.Write(data)
+		total += int64(n)
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, tw.Close()
+}
+
+// Flush f to stable storage if the store supports it, e.g., files of DirKeyStore.
+func syncKeyFile(f io.WriteCloser) error {
+	if s, ok := f.(interface{ Sync() error }); ok {
+		return s.Sync()
+	}
+	return nil
+}
+
+// Same as io.Copy of a whole file between stores.
+func CopyKeyFile(src KeyStore, dst KeyStore, name string) error {
+	in, err := src.Open(name)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := dst.Create(name)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,110 @@
+package vcs
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/alinush/go-mcl"
+)
+
+// Keys generated into a tar archive, written out, read back and loaded.
+func TestKeyStore(t *testing.T) {
+
+	mcl.InitFromString("bls12-381")
+	r := testReader(t)
+
+	L := uint8(8)
+	N := uint64(1) << L
+	K := 8
+	txnLimit := uint64(K)
+
+	store, err := NewTarKeyStore(nil)
+	check(err)
+	vcs := VCS{Store: store}
+	check(vcs.KeyGen(16, L, "tar", txnLimit))
+
+	var archive bytes.Buffer
+	_, err = store.WriteTo(&archive)
+	check(err)
+
+	t.Run(fmt.Sprintf("%d/Files;", L), func(t *testing.T) {
+		names, _ := store.List()
+		for _, name := range names {
+			if name == CHECKPOINTNAME || strings.HasSuffix(name, ".tmp") {
+				t.Errorf("Leftover keygen file %s", name)
+			}
+		}
+		if _, err := store.Size("/missing.data"); !os.IsNotExist(err) {
+			t.Errorf("Missing file error should satisfy os.IsNotExist: %v", err)
+		}
+	})
+
+	loaded, err := NewTarKeyStore(bytes.NewReader(archive.Bytes()))
+	check(err)
+	aFr, err := GenerateVectorFrom(N, r)
+	check(err)
+
+	t.Run(fmt.Sprintf("%d/KeyGenLoad;%d", L, N), func(t *testing.T) {
+		v := VCS{Store: loaded}
+		check(v.KeyGenLoad(16, L, "tar", txnLimit))
+		for i := uint64(0); i < N; i++ {
+			if !SliceIsEqual(vcs.GetUpk(i), v.GetUpk(i)) {
+				t.Fatalf("GetUpk mismatch at index %d", i)
+			}
+		}
+
+		digest := v.Commit(aFr, uint64(L))
+		v.OpenAll(aFr)
+		indexVec := make([]uint64, K)
+		proofVec := make([][]mcl.G1, K)
+		valueVec := make([]mcl.Fr, K)
+		for k := 0; k < K; k++ {
+			var err error
+			if indexVec[k], err = RandomIndex(r, N); err != nil {
+				t.Fatal(err)
+			}
+			proofVec[k] = v.GetProofPath(indexVec[k])
+			valueVec[k] = aFr[indexVec[k]]
+		}
+		status, _ := v.VerifyMemoized(digest, indexVec, valueVec, proofVec)
+		if !status {
+			t.Errorf("Proofs failed to verify")
+		}
+		aggProof, err := v.AggProve(indexVec, proofVec)
+		check(err)
+		if ok, err := v.AggVerify(aggProof, digest, indexVec, valueVec); err != nil || !ok {
+			t.Errorf("Aggregated proof failed to verify")
+		}
+	})
+
+	// Stores without Map are read with ReadAt instead of mapped.
+	t.Run(fmt.Sprintf("%d/UpkMapDriver;%d", L, N), func(t *testing.T) {
+		v := VCS{Store: struct{ KeyStore }{loaded}}
+		check(v.Init(L, "tar", txnLimit))
+		check(v.LoadManifest())
+		check(v.LoadPublicParams(L))
+		check(v.UpkMapDriver())
+		defer v.UpkUnmap()
+		for i := uint64(0); i < N; i++ {
+			if !SliceIsEqual(vcs.GetUpk(i), v.GetUpk(i)) {
+				t.Fatalf("GetUpk mismatch at index %d", i)
+			}
+		}
+	})
+
+	t.Run(fmt.Sprintf("%d/Corrupt;", L), func(t *testing.T) {
+		corrupt, err := NewTarKeyStore(bytes.NewReader(archive.Bytes()))
+		check(err)
+		w, _ := corrupt.Create(fmt.Sprintf(UPKNAME, 3))
+		w.Write(make([]byte, 16))
+		w.Close()
+		v := VCS{Store: corrupt}
+		check(v.Init(L, "tar", txnLimit))
+		if err := v.LoadManifest(); err == nil {
+			t.Errorf("Corrupted shard was not detected")
+		}
+	})
+}
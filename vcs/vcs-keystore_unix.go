@@ -0,0 +1,23 @@
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build aix darwin dragonfly freebsd linux netbsd openbsd solaris
+
+// KeyMapper of DirKeyStore. On other platforms, the key files are read with ReadAt, see PointsMmap.
+package vcs
+
+import (
+	"os"
+	"syscall"
+)
+
+func (d *DirKeyStore) Map(name string, size int) ([]byte, error) {
+	f, err := os.Open(d.Dir + name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close() // The mapping stays valid
+	return syscall.Mmap(int(f.Fd()), 0, size, syscall.PROT_READ, syscall.MAP_SHARED)
+}
+
+func (d *DirKeyStore) Unmap(data []byte) error {
+	return syscall.Munmap(data)
+}
@@ -0,0 +1,63 @@
+package vcs
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/alinush/go-mcl"
+)
+
+var ellLoad = []uint8{16, 18, 20} // Keys are generated in a temporary folder for each ell
+
+// Load time of the UPK tree: one point per read (baseline), chunked parallel decoding, and the same without the subgroup checks.
+func BenchmarkKeyLoad(b *testing.B) {
+
+	mcl.InitFromString("bls12-381")
+
+	for _, L := range ellLoad {
+		folder := b.TempDir()
+		vcs := VCS{Config: Config{NCores: 16}}
+		check(vcs.Init(L, folder, 8))
+		check(vcs.TrapdoorsSample())
+		check(vcs.SavePublicParams())
+		check(vcs.SaveVrk())
+		check(vcs.UpkGenDriver())
+		check(vcs.SaveManifest())
+
+		loaded := VCS{}
+		check(loaded.Init(L, folder, 8))
+		check(loaded.LoadManifest())
+		numUPK := (uint64(1) << (L + 1)) - 1
+
+		b.Run(fmt.Sprintf("%d/UpkLoad;%d", L, numUPK), func(b *testing.B) {
+			for bn := 0; bn < b.N; bn++ {
+				loaded.MallocUpk()
+				var wg sync.WaitGroup
+				shards, err := loaded.UpkShards()
+				check(err)
+				for i, shard := range shards {
+					wg.Add(1)
+					go loaded.UpkLoad(shard.Name, uint8(i), shard.Start, shard.Stop, &wg)
+				}
+				wg.Wait()
+			}
+		})
+
+		b.Run(fmt.Sprintf("%d/UpkLoadDriver;%d", L, numUPK), func(b *testing.B) {
+			loaded.TrustedKeys = false
+			for bn := 0; bn < b.N; bn++ {
+				check(loaded.UpkLoadDriver())
+			}
+			b.ReportMetric(float64(loaded.NCores), "cores") // Workers of loadShards, for ReadBenchmarks
+		})
+
+		b.Run(fmt.Sprintf("%d/UpkLoadDriverTrusted;%d", L, numUPK), func(b *testing.B) {
+			loaded.TrustedKeys = true
+			for bn := 0; bn < b.N; bn++ {
+				check(loaded.UpkLoadDriver())
+			}
+			b.ReportMetric(float64(loaded.NCores), "cores")
+		})
+	}
+}
@@ -0,0 +1,359 @@
+// Manifest of a key folder (or any KeyStore).
+// It records the format version, curve, ell, the shard count and, for every file, its range of points, size and checksum.
+// Loaders use it to find the UPK and PRK shards, and reject folders that do not match it.
+// Loading checks the sizes and ranges. Checksums are verified by CheckKeys, or on load with Config.Checksums, as hashing reads every file.
+// Folders without a manifest are loaded with the default layout of 16 shards.
+package vcs
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/alinush/go-mcl"
+)
+
+const MANIFEST_MAGIC = "HYPERVCS"
+const MANIFEST_VERSION = 1
+
+type ManifestEntry struct {
+	Name     string // Relative to the key folder, e.g., /upk-00.data
+	Start    uint64 // Shards hold the points [Start, Stop) of UPK (or PRK) in level order
+	Stop     uint64
+	Size     uint64 // In bytes
+	Checksum [sha256.Size]byte
+}
+
+type Manifest struct {
+	Version   uint32
+	Curve     uint32
+	L         uint8
+	NumShards uint8
+	Entries   []ManifestEntry
+}
+
+// Range of points in the ith shard when total points are split into shards files.
+// Same split as UpkGenDriver and PrkGenDriver.
+func ShardBounds(total uint64, shards uint8, i uint8) (uint64, uint64) {
+	step := (total + uint64(shards) - 1) / uint64(shards)
+	start := minUint64(uint64(i)*step, total)
+	stop := minUint64(start+step, total)
+	return start, stop
+}
+
+func fileChecksum(store KeyStore, fileName string) ([sha256.Size]byte, uint64, error) {
+	var sum [sha256.Size]byte
+	f, err := store.Open(fileName)
+	if err != nil {
+		return sum, 0, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return sum, 0, err
+	}
+	copy(sum[:], h.Sum(nil))
+	return sum, uint64(n), nil
+}
+
+// Files of the key folder in the order they appear in the manifest.
+// Trapdoors are never part of the manifest.
+func (vcs *VCS) manifestEntries() []ManifestEntry {
+
+	var entries []ManifestEntry
+	L := uint64(vcs.L)
+	entries = append(entries, ManifestEntry{Name: vcs.Names.PP, Start: 0, Stop: 1})
+	entries = append(entries, ManifestEntry{Name: vcs.Names.VRK, Start: 0, Stop: L})
+
+	numUPK := (uint64(1) << (vcs.L + 1)) - 1
+	for i := uint8(0); i < vcs.nfiles; i++ {
+		start, stop := ShardBounds(numUPK, vcs.nfiles, i)
+		entries = append(entries, ManifestEntry{Name: fmt.Sprintf(vcs.Names.UPK, i), Start: start, Stop: stop})
+	}
+	for i := uint8(0); i < vcs.nfiles; i++ {
+		start, stop := ShardBounds(vcs.N, vcs.nfiles, i)
+		entries = append(entries, ManifestEntry{Name: fmt.Sprintf(vcs.Names.PRK, i), Start: start, Stop: stop})
+	}
+	entries = append(entries, ManifestEntry{Name: vcs.Names.CK})
+	entries = append(entries, ManifestEntry{Name: vcs.Names.KZG})
+	entries = append(entries, ManifestEntry{Name: vcs.Names.Ceremony})
+	return entries
+}
+
+// Write the manifest of all the key files present in the folder.
+// Call this once all the keys are written.
+func (vcs *VCS) SaveManifest() error {
+
+	m := Manifest{MANIFEST_VERSION, uint32(mcl.BLS12_381), vcs.L, vcs.nfiles, nil}
+	for _, entry := range vcs.manifestEntries() {
+		if _, err := vcs.store.Size(entry.Name); err != nil {
+			continue // Optional files, e.g., PRK
+		}
+		var err error
+		entry.Checksum, entry.Size, err = fileChecksum(vcs.store, entry.Name)
+		if err != nil {
+			return err
+		}
+		m.Entries = append(m.Entries, entry)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(MANIFEST_MAGIC)
+	binary.Write(&buf, binary.LittleEndian, m.Version)
+	binary.Write(&buf, binary.LittleEndian, m.Curve)
+	binary.Write(&buf, binary.LittleEndian, uint64(m.L))
+	binary.Write(&buf, binary.LittleEndian, uint64(m.NumShards))
+	binary.Write(&buf, binary.LittleEndian, uint64(len(m.Entries)))
+	for _, entry := range m.Entries {
+		binary.Write(&buf, binary.LittleEndian, uint64(len(entry.Name)))
+		buf.WriteString(entry.Name)
+		binary.Write(&buf, binary.LittleEndian, entry.Start)
+		binary.Write(&buf, binary.LittleEndian, entry.Stop)
+		binary.Write(&buf, binary.LittleEndian, entry.Size)
+		buf.Write(entry.Checksum[:])
+	}
+
+	f, err := vcs.store.Create(vcs.Names.Manifest)
+	if err != nil {
+		return err
+	}
+	w := keyFileWriter{w: f}
+	w.write(buf.Bytes())
+	if err := w.close(f); err != nil {
+		return err
+	}
+
+	vcs.manifest = &m
+	vcs.log(SEP, "Saved the manifest:", len(m.Entries), "files", SEP)
+	return nil
+}
+
+func ReadManifest(r io.Reader) (Manifest, error) {
+
+	var m Manifest
+	var L, shards, count uint64
+
+	magic := make([]byte, len(MANIFEST_MAGIC))
+	if _, err := io.ReadFull(r, magic); err != nil || string(magic) != MANIFEST_MAGIC {
+		return m, errors.New("Manifest: Not a key folder manifest")
+	}
+	if err := binary.Read(r, binary.LittleEndian, &m.Version); err != nil {
+		return m, err
+	}
+	if m.Version > MANIFEST_VERSION {
+		return m, fmt.Errorf("Manifest: Unsupported version %d. Supports up to %d", m.Version, MANIFEST_VERSION)
+	}
+	for _, x := range []interface{}{&m.Curve, &L, &shards, &count} {
+		if err := binary.Read(r, binary.LittleEndian, x); err != nil {
+			return m, fmt.Errorf("Manifest: Truncated header: %v", err)
+		}
+	}
+	if L == 0 || L >= 32 || shards == 0 || shards > 255 {
+		return m, fmt.Errorf("Manifest: Bad header, ell: %d, shards: %d", L, shards)
+	}
+	m.L = uint8(L)
+	m.NumShards = uint8(shards)
+
+	for j := uint64(0); j < count; j++ {
+		var entry ManifestEntry
+		var nameLen uint64
+		if err := binary.Read(r, binary.LittleEndian, &nameLen); err != nil || nameLen > 256 {
+			return m, fmt.Errorf("Manifest: Bad entry %d", j)
+		}
+		name := make([]byte, nameLen)
+		if _, err := io.ReadFull(r, name); err != nil {
+			return m, fmt.Errorf("Manifest: Truncated entry %d", j)
+		}
+		entry.Name = string(name)
+		for _, x := range []interface{}{&entry.Start, &entry.Stop, &entry.Size} {
+			if err := binary.Read(r, binary.LittleEndian, x); err != nil {
+				return m, fmt.Errorf("Manifest: Truncated entry %d", j)
+			}
+		}
+		if _, err := io.ReadFull(r, entry.Checksum[:]); err != nil {
+			return m, fmt.Errorf("Manifest: Truncated entry %d", j)
+		}
+		m.Entries = append(m.Entries, entry)
+	}
+	return m, nil
+}
+
+// Load the manifest of the folder and check the sizes and ranges of the files against it. Checksums only with Checksums.
+// Sets the shard count for the loaders. Folders without a manifest are left to the legacy loaders.
+// Errors are ErrKeyFolderCorrupt, or ErrBadEll if the folder is for a smaller ell.
+func (vcs *VCS) LoadManifest() error {
+
+	f, err := vcs.store.Open(vcs.Names.Manifest)
+	if os.IsNotExist(err) {
+		vcs.manifest = nil
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	m, err := ReadManifest(f)
+	f.Close()
+	if err != nil {
+		return corrupt(vcs.folderPath, err)
+	}
+
+	if m.Curve != uint32(mcl.BLS12_381) {
+		return newError(ErrKeyFolderCorrupt, "%s: Keys are for curve %d, expected BLS12-381", vcs.folderPath, m.Curve)
+	}
+	if m.L < vcs.L {
+		return newError(ErrBadEll, "%s: Keys are for ell = %d, but ell = %d was requested", vcs.folderPath, m.L, vcs.L)
+	}
+
+	listed := make(map[string]bool)
+	for _, entry := range m.Entries {
+		listed[entry.Name] = true
+		if err := vcs.checkManifestEntry(&entry, vcs.Checksums); err != nil {
+			return err
+		}
+	}
+
+	// Extra shards would silently change the layout with the legacy loaders.
+	files, err := vcs.store.List()
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		if vcs.isShard(file) && !listed[file] {
+			return newError(ErrKeyFolderCorrupt, "%s: %s is not part of the manifest", vcs.folderPath, file[1:])
+		}
+	}
+
+	vcs.manifest = &m
+	vcs.folderL = m.L
+	vcs.nfiles = m.NumShards
+	return nil
+}
+
+// Hash the files of the loaded manifest and compare them to their checksums. Nothing to check for folders without a manifest.
+func (vcs *VCS) VerifyChecksums() error {
+	if vcs.manifest == nil {
+		return nil
+	}
+	for _, entry := range vcs.manifest.Entries {
+		if err := vcs.checkManifestEntry(&entry, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (vcs *VCS) checkManifestEntry(entry *ManifestEntry, checksum bool) error {
+
+	fileName := vcs.folderPath + entry.Name
+	size, err := vcs.store.Size(entry.Name)
+	if err != nil {
+		return newError(ErrKeyFolderCorrupt, "%s: Missing key file: %v", vcs.folderPath, err)
+	}
+	if uint64(size) != entry.Size {
+		return newError(ErrKeyFolderCorrupt, "%s: Size is %d bytes, manifest says %d bytes", fileName, size, entry.Size)
+	}
+	if vcs.isShard(entry.Name) && entry.Size != (entry.Stop-entry.Start)*uint64(GetG1ByteSize()) {
+		return newError(ErrKeyFolderCorrupt, "%s: Size does not match the range %s", fileName, BoundsPrint(entry.Start, entry.Stop))
+	}
+	if !checksum {
+		return nil
+	}
+	sum, _, err := fileChecksum(vcs.store, entry.Name)
+	if err != nil {
+		return corrupt(fileName, err)
+	}
+	if sum != entry.Checksum {
+		return newError(ErrKeyFolderCorrupt, "%s: Checksum mismatch, the file is corrupted", fileName)
+	}
+	return nil
+}
+
+// Names of the UPK and PRK shards, e.g., /upk-03.data with the default names.
+func (vcs *VCS) isShard(name string) bool {
+	for _, format := range []string{vcs.Names.UPK, vcs.Names.PRK} {
+		if _, ok := shardNumber(format, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// i if name is fmt.Sprintf(format, i), e.g., 3 for /upk-03.data and UPKNAME.
+func shardNumber(format string, name string) (uint8, bool) {
+	var i uint8
+	if _, err := fmt.Sscanf(name, format, &i); err == nil && fmt.Sprintf(format, i) == name {
+		return i, true
+	}
+	return 0, false
+}
+
+// Files and ranges of the UPK shards, up to level L of the tree.
+func (vcs *VCS) UpkShards() ([]ManifestEntry, error) {
+	numUPK := (uint64(1) << (vcs.L + 1)) - 1
+	folderUPK := (uint64(1) << (vcs.FolderEll() + 1)) - 1
+	return vcs.shards(vcs.Names.UPK, numUPK, folderUPK)
+}
+
+// Files and ranges of the PRK shards, up to index N.
+func (vcs *VCS) PrkShards() ([]ManifestEntry, error) {
+	return vcs.shards(vcs.Names.PRK, vcs.N, uint64(1)<<vcs.FolderEll())
+}
+
+// Files and ranges of the shards named by format (UPKNAME or PRKNAME) that hold the first total of folderTotal points.
+// Taken from the manifest when present. Otherwise, the default layout is assumed and the file sizes are checked.
+// Ranges are clipped to total. See vcs-derive.go
+func (vcs *VCS) shards(format string, total uint64, folderTotal uint64) ([]ManifestEntry, error) {
+
+	var shards []ManifestEntry
+	byName := make(map[string]ManifestEntry)
+	if vcs.manifest != nil {
+		for _, entry := range vcs.manifest.Entries {
+			byName[entry.Name] = entry
+		}
+	}
+
+	next := uint64(0)
+	for i := uint8(0); i < vcs.nfiles; i++ {
+		name := fmt.Sprintf(format, i)
+		entry, ok := byName[name]
+		if !ok {
+			start, stop := ShardBounds(folderTotal, vcs.nfiles, i)
+			entry = ManifestEntry{Name: name, Start: start, Stop: stop}
+			if vcs.manifest == nil && start < stop && start < total {
+				size, err := vcs.store.Size(name)
+				if err != nil {
+					return nil, corrupt(vcs.folderPath+name, err)
+				}
+				if uint64(size) != (stop-start)*uint64(GetG1ByteSize()) {
+					return nil, newError(ErrKeyFolderCorrupt, "%s: Size is %d bytes, expected %d bytes", vcs.folderPath+name, size, (stop-start)*uint64(GetG1ByteSize()))
+				}
+			}
+		}
+		if entry.Start != next || entry.Stop < entry.Start {
+			return nil, newError(ErrKeyFolderCorrupt, "%s: Shard %s does not continue from %d", vcs.folderPath, name, next)
+		}
+		next = entry.Stop
+		entry.Stop = minUint64(entry.Stop, total)
+		if entry.Start < entry.Stop {
+			shards = append(shards, entry)
+		}
+	}
+	if next != folderTotal {
+		return nil, newError(ErrKeyFolderCorrupt, "%s: Shards hold %d points, expected %d", vcs.folderPath, next, folderTotal)
+	}
+	return shards, nil
+}
+
+// ell of the key folder. It is at least L, and larger when the instance is derived from a larger folder.
+func (vcs *VCS) FolderEll() uint8 {
+	if vcs.folderL == 0 {
+		return vcs.L
+	}
+	return vcs.folderL
+}
@@ -0,0 +1,95 @@
+package vcs
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/alinush/go-mcl"
+)
+
+// Generate keys with a non-default shard count and check that corrupted folders are rejected.
+func TestManifest(t *testing.T) {
+
+	mcl.InitFromString("bls12-381")
+
+	L := uint8(6)
+	txnLimit := uint64(8)
+	folder := t.TempDir()
+
+	vcs := VCS{Config: Config{NumShards: 4}}
+	check(vcs.KeyGen(16, L, folder, txnLimit))
+	upk := vcs.UPK
+
+	t.Run(fmt.Sprintf("%d/LoadShards;%d", L, 4), func(t *testing.T) {
+		loaded := VCS{}
+		check(loaded.KeyGenLoad(16, L, folder, txnLimit))
+		if loaded.nfiles != 4 || loaded.manifest == nil {
+			t.Fatalf("Shard count was not taken from the manifest")
+		}
+		for l := range upk {
+			for k := range upk[l] {
+				if !loaded.UPK[l][k].IsEqual(&upk[l][k]) {
+					t.Fatalf("UPK mismatch at level %d, index %d", l, k)
+				}
+			}
+		}
+	})
+
+	expectError := func(t *testing.T, ell uint8, substr string) {
+		loaded := VCS{Config: Config{Checksums: true}}
+		check(loaded.Init(ell, folder, txnLimit))
+		err := loaded.LoadManifest()
+		if err == nil || !strings.Contains(err.Error(), substr) {
+			t.Errorf("Expected an error with %q, got %v", substr, err)
+		}
+	}
+
+	t.Run(fmt.Sprintf("%d/WrongEll;", L), func(t *testing.T) {
+		expectError(t, L+2, "ell = 8 was requested")
+	})
+
+	shard := folder + fmt.Sprintf(UPKNAME, 1)
+	data, err := os.ReadFile(shard)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run(fmt.Sprintf("%d/Truncated;", L), func(t *testing.T) {
+		os.WriteFile(shard, data[:len(data)-1], 0644)
+		expectError(t, L, "manifest says")
+		os.WriteFile(shard, data, 0644)
+	})
+
+	t.Run(fmt.Sprintf("%d/Corrupted;", L), func(t *testing.T) {
+		corrupted := append([]byte{}, data...)
+		corrupted[7] ^= 1
+		os.WriteFile(shard, corrupted, 0644)
+		loaded := VCS{}
+		check(loaded.Init(L, folder, txnLimit))
+		if err := loaded.LoadManifest(); err != nil {
+			t.Errorf("Checksums were verified without Checksums: %v", err)
+		}
+		if err := loaded.VerifyChecksums(); err == nil || !strings.Contains(err.Error(), "Checksum mismatch") {
+			t.Errorf("Expected a checksum mismatch, got %v", err)
+		}
+		expectError(t, L, "Checksum mismatch")
+		os.WriteFile(shard, data, 0644)
+	})
+
+	t.Run(fmt.Sprintf("%d/ExtraShard;", L), func(t *testing.T) {
+		extra := folder + fmt.Sprintf(UPKNAME, 4)
+		os.WriteFile(extra, data, 0644)
+		expectError(t, L, "not part of the manifest")
+		os.Remove(extra)
+	})
+
+	t.Run(fmt.Sprintf("%d/BadMagic;", L), func(t *testing.T) {
+		manifest, _ := os.ReadFile(folder + MANIFESTNAME)
+		os.WriteFile(folder+MANIFESTNAME, []byte("NOTAKEYS"), 0644)
+		expectError(t, L, "Not a key folder manifest")
+		os.WriteFile(folder+MANIFESTNAME, manifest, 0644)
+		expectError(t, L+1, "ell = 7 was requested")
+	})
+}
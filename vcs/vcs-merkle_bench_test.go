@@ -28,7 +28,7 @@ func BenchmarkVCSAgg(b *testing.B) {
 		// N := uint64(1) << L
 
 		vcs := VCS{}
-		vcs.KeyGenLoadFake(16, L, "../pkvk-30", txns[len(txns)-1])
+		check(vcs.KeyGenLoadFake(16, L, "../pkvk-30", txns[len(txns)-1]))
 
 		// digest, indexVec, valueVec, _, proofs_db := vcs.GenProofsFake(txns[0])
 
@@ -39,14 +39,15 @@ func BenchmarkVCSAgg(b *testing.B) {
 			txn := txns[iTxn]
 			digest, indexVec, valueVec, _, proofVec, _ := vcs.GenProofsTreeFake(txn)
 			vcs.ResizeAgg(txn)
-			vcs.LoadAggGipa()
+			check(vcs.LoadAggGipa())
 
 			var aggProof batch.Proof
 			var aggProofs []batch.Proof
 
 			b.Run(fmt.Sprintf("%d/AggregateProve;%d", L, txn), func(b *testing.B) {
 				for bn := 0; bn < b.N; bn++ {
-					aggProof = vcs.AggProve(indexVec[:txn], proofVec[:txn])
+					aggProof, err := vcs.AggProve(indexVec[:txn], proofVec[:txn])
+					check(err)
 					b.StopTimer()
 					aggProofs = append(aggProofs, aggProof)
 					b.StartTimer()
@@ -58,7 +59,9 @@ func BenchmarkVCSAgg(b *testing.B) {
 
 				for bn := 0; bn < b.N; bn++ {
 					aggProof, aggProofs = aggProofs[0], aggProofs[1:]
-					status = status && vcs.AggVerify(aggProof, digest, indexVec[:txn], valueVec[:txn])
+					ok, err := vcs.AggVerify(aggProof, digest, indexVec[:txn], valueVec[:txn])
+					check(err)
+					status = status && ok
 				}
 				if status == false {
 					b.Errorf("Aggregation failed")
@@ -46,7 +46,7 @@ func benchmarkVCS(L uint8, txn uint64, DoAgg bool, Micro bool, b *testing.B) {
 	var basecost int
 	vcs := VCS{}
 
-	vcs.KeyGenLoadFake(16, L, "../pkvk-30", txn)
+	check(vcs.KeyGenLoadFake(16, L, "../pkvk-30", txn))
 	digest, indexVec, valueVec, upk_db, proofVec, proofTree := vcs.GenProofsTreeFake(txn)
 
 	deltaVec := make([]mcl.Fr, len(indexVec))
@@ -126,7 +126,7 @@ func benchmarkVCS(L uint8, txn uint64, DoAgg bool, Micro bool, b *testing.B) {
 
 	if DoAgg {
 		vcs.ResizeAgg(txn)
-		vcs.LoadAggGipa()
+		check(vcs.LoadAggGipa())
 
 		var aggProof batch.Proof
 		var aggProofs []batch.Proof
@@ -134,7 +134,8 @@ func benchmarkVCS(L uint8, txn uint64, DoAgg bool, Micro bool, b *testing.B) {
 		b.Run(fmt.Sprintf("%d/AggregateProve;%d", L, txn), func(b *testing.B) {
 			b.ResetTimer()
 			for bn := 0; bn < b.N; bn++ {
-				aggProof = vcs.AggProve(indexVec[:txn], proofVec[:txn])
+				aggProof, err := vcs.AggProve(indexVec[:txn], proofVec[:txn])
+				check(err)
 				b.StopTimer()
 				aggProofs = append(aggProofs, aggProof)
 				b.StartTimer()
@@ -147,7 +148,9 @@ func benchmarkVCS(L uint8, txn uint64, DoAgg bool, Micro bool, b *testing.B) {
 				b.StopTimer()
 				aggProof, aggProofs = aggProofs[0], aggProofs[1:]
 				b.StartTimer()
-				status = status && vcs.AggVerify(aggProof, digest, indexVec[:txn], valueVec[:txn])
+				ok, err := vcs.AggVerify(aggProof, digest, indexVec[:txn], valueVec[:txn])
+				check(err)
+				status = status && ok
 				if status == false {
 					b.Errorf("Aggregation failed")
 				}
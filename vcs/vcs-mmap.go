@@ -0,0 +1,189 @@
+// Memory-mapped UPK tree and PRK.
+// For large ell (PARAM_TOO_LARGE), the UPK tree does not fit in memory as [][]mcl.G1.
+// Instead, the upk-*.data files are mapped read-only (or read with ReadAt where mmap is not available) and the nodes are decoded on demand.
+// Use UpkNode and GetUpk to read the tree. They work with both backends. Similarly, see PrkNode in vcs-prk.go
+// Commit needs a whole level. It is decoded from the mapped files for the call, see upkLevel.
+package vcs
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/alinush/go-mcl"
+)
+
+// Points of a set of shards, e.g., UPK or PRK.
+// Stores that are not a KeyMapper, e.g., DirKeyStore on platforms without mmap, are read point by point with ReadAt instead.
+type PointsMmap struct {
+	shards  []ManifestEntry // Sorted by Start
+	data    [][]byte        // data[i] is the mapping of shards[i]
+	readers []KeyReader     // readers[i] holds shards[i] if the store is not a KeyMapper
+	mapper  KeyMapper       // nil if the shards are read with ReadAt
+}
+
+// Shards come from UpkShards or PrkShards, which check that they cover the points without gaps. Each file must
+// still hold its range when mapped, thus every point can be read. Whether it decodes is only known when read, see Point.
+func (vcs *VCS) mapShards(shards []ManifestEntry) (*PointsMmap, error) {
+
+	for _, shard := range shards {
+		size, err := vcs.store.Size(shard.Name)
+		if err != nil {
+			return nil, corrupt(vcs.folderPath+shard.Name, err)
+		}
+		if expected := (shard.Stop - shard.Start) * uint64(GetG1ByteSize()); uint64(size) < expected {
+			return nil, newError(ErrKeyFolderCorrupt, "%s: %d bytes, expected %d for %s", vcs.folderPath+shard.Name, size, expected, BoundsPrint(shard.Start, shard.Stop))
+		}
+	}
+
+	m := PointsMmap{}
+	m.shards = shards
+	m.data = make([][]byte, len(m.shards))
+
+	m.mapper, _ = vcs.store.(KeyMapper)
+	if m.mapper == nil {
+		m.readers = make([]KeyReader, len(m.shards))
+	}
+	for i, shard := range m.shards {
+		var err error
+		size := int((shard.Stop - shard.Start) * uint64(GetG1ByteSize()))
+		if m.mapper != nil {
+			m.data[i], err = m.mapper.Map(shard.Name, size)
+		} else {
+			m.readers[i], err = vcs.store.Open(shard.Name)
+		}
+		if err != nil {
+			m.Unmap()
+			return nil, corrupt(vcs.folderPath+shard.Name, err)
+		}
+		vcs.log("Mapped ", vcs.folderPath+shard.Name, BoundsPrint(shard.Start, shard.Stop))
+	}
+	return &m, nil
+}
+
+// Returns the first error, all the shards are unmapped or closed regardless.
+func (m *PointsMmap) Unmap() error {
+	var firstErr error
+	for i := range m.data {
+		if m.mapper != nil && m.data[i] != nil {
+			if err := m.mapper.Unmap(m.data[i]); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	for i := range m.readers {
+		if m.readers[i] != nil {
+			if err := m.readers[i].Close(); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	m.data = nil
+	m.readers = nil
+	return firstErr
+}
+
+// Map the UPK shards of the folder. The layout is taken from the manifest, if there is one.
+func (vcs *VCS) UpkMapDriver() error {
+	shards, err := vcs.UpkShards()
+	if err != nil {
+		return err
+	}
+	vcs.upkMmap, err = vcs.mapShards(shards)
+	return err
+}
+
+// Unmap the UPK files. UpkNode must not be used afterwards, unless the tree is loaded again.
+func (vcs *VCS) UpkUnmap() error {
+	if vcs.upkMmap == nil {
+		return nil
+	}
+	err := vcs.upkMmap.Unmap()
+	vcs.upkMmap = nil
+	return err
+}
+
+// Decode the point at position j. For UPK, this is in level order, i.e., UPK[l][k] with j = 2^l - 1 + k.
+// Returns ErrBadIndex if j is past the shards, and ErrKeyFolderCorrupt if the point does not decode.
+// The files are only checked against the manifest when loaded with Checksums, see LoadManifest.
+func (m *PointsMmap) Point(j uint64) (mcl.G1, error) {
+
+	var result mcl.G1
+	s := sort.Search(len(m.shards), func(i int) bool {
+		return m.shards[i].Stop > j
+	})
+	if s == len(m.shards) || j < m.shards[s].Start {
+		return result, newError(ErrBadIndex, "PointsMmap: Point %d is out of range", j)
+	}
+
+	offset := (j - m.shards[s].Start) * uint64(GetG1ByteSize())
+	data := make([]byte, GetG1ByteSize())
+	if m.readers != nil {
+		if _, err := m.readers[s].ReadAt(data, int64(offset)); err != nil {
+			return result, corrupt(m.shards[s].Name, err)
+		}
+	} else {
+		data = m.data[s][offset : offset+uint64(GetG1ByteSize())]
+	}
+	if err := result.Deserialize(data); err != nil {
+		return result, corrupt(m.shards[s].Name, fmt.Errorf("Point %d: %v", j, err))
+	}
+	return result, nil
+}
+
+// Same as Point, for callers without an error path, e.g., GetUpk. Panics with the error of Point.
+func (m *PointsMmap) At(j uint64) mcl.G1 {
+	result, err := m.Point(j)
+	check(err)
+	return result
+}
+
+// dst[k] = Point(first + k), decoded on ncores goroutines. Returns the first error.
+func (m *PointsMmap) decodeRange(ncores uint8, first uint64, dst []mcl.G1) error {
+
+	var mu sync.Mutex
+	var firstErr error
+	genInMemory(ncores, uint64(len(dst)), func(start uint64, stop uint64) {
+		for k := start; k < stop; k++ {
+			var err error
+			if dst[k], err = m.Point(first + k); err != nil {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				mu.Unlock()
+				return
+			}
+		}
+	})
+	return firstErr
+}
+
+// UPK[l][k] from memory, or from the mapped files if the tree is not in memory.
+// Panics if the mapped point does not decode. See upkNode for the error.
+func (vcs *VCS) UpkNode(l uint8, k uint64) mcl.G1 {
+	result, err := vcs.upkNode(l, k)
+	check(err)
+	return result
+}
+
+func (vcs *VCS) upkNode(l uint8, k uint64) (mcl.G1, error) {
+	if vcs.upkMmap != nil && vcs.UPK == nil {
+		return vcs.upkMmap.Point((uint64(1) << l) - 1 + k)
+	}
+	return vcs.UPK[l][k], nil
+}
+
+// Level l of the UPK tree. With the mapped tree, the level is decoded on NCores goroutines into a copy
+// that is dropped once the caller is done. Thus, it takes as much memory as the level loaded.
+func (vcs *VCS) upkLevel(l uint8) ([]mcl.G1, error) {
+
+	if vcs.UPK != nil || vcs.upkMmap == nil {
+		return vcs.UPK[l], nil
+	}
+	level := make([]mcl.G1, 1<<l)
+	if err := vcs.upkMmap.decodeRange(vcs.NCores, (uint64(1)<<l)-1, level); err != nil {
+		return nil, err
+	}
+	return level, nil
+}
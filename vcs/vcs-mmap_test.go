@@ -0,0 +1,156 @@
+package vcs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/alinush/go-mcl"
+	"github.com/hyperproofs/gipa-go/utils"
+)
+
+// The mapped UPK tree must match the in-memory one.
+func TestUpkMmap(t *testing.T) {
+
+	mcl.InitFromString("bls12-381")
+
+	L := uint8(10)
+	N := uint64(1) << L
+	folder := t.TempDir()
+
+	vcs := VCS{Config: Config{NCores: 16}}
+	check(vcs.Init(L, folder, 8))
+	check(vcs.TrapdoorsSample())
+	check(vcs.SavePublicParams())
+	check(vcs.SaveVrk())
+	check(vcs.UpkGenDriver())
+	check(vcs.genAggGipa(context.Background(), utils.NextPowOf2(uint64(L)*8))) // Small aggregation keys for KeyGenLoad
+	check(vcs.SaveManifest())
+
+	mapped := VCS{}
+	check(mapped.Init(L, folder, 8))
+	check(mapped.LoadManifest())
+	check(mapped.LoadPublicParams(L))
+	check(mapped.UpkMapDriver())
+	defer mapped.UpkUnmap()
+
+	t.Run(fmt.Sprintf("%d/GetUpk;%d", L, N), func(t *testing.T) {
+		for i := uint64(0); i < N; i++ {
+			if !SliceIsEqual(vcs.GetUpk(i), mapped.GetUpk(i)) {
+				t.Fatalf("GetUpk mismatch at index %d", i)
+			}
+			if i%97 == 0 && !mapped.VerifyUPK(i, mapped.GetUpk(i)) {
+				t.Fatalf("VerifyUPK failed at index %d", i)
+			}
+		}
+	})
+
+	t.Run(fmt.Sprintf("%d/UpdateComVec;%d", L, 16), func(t *testing.T) {
+		var digest mcl.G1
+		index := make([]uint64, 16)
+		delta := GenerateVector(16)
+		for i := range index {
+			index[i] = uint64(i) * 61 % N
+		}
+		a, errA := vcs.UpdateComVec(digest, index, delta)
+		b, errB := mapped.UpdateComVec(digest, index, delta)
+		if errA != nil || errB != nil {
+			t.Fatal(errA, errB)
+		}
+		if !a.IsEqual(&b) {
+			t.Errorf("UpdateComVec mismatch")
+		}
+	})
+
+	t.Run(fmt.Sprintf("%d/CommitOpenAll;%d", L, N), func(t *testing.T) {
+		large := VCS{Config: Config{MemoryEll: L - 1}} // Forces PARAM_TOO_LARGE
+		check(large.KeyGenLoad(16, L, folder, 8))
+		defer large.UpkUnmap()
+		if !large.PARAM_TOO_LARGE || large.UPK != nil {
+			t.Fatalf("UPK tree is not mapped")
+		}
+		if _, err := large.ProverKey(); !errors.Is(err, ErrNotInMemory) {
+			t.Errorf("ProverKey: Expected ErrNotInMemory, got %v", err)
+		}
+
+		aFr := GenerateVector(N)
+		a := vcs.Commit(aFr, uint64(L))
+		b := large.Commit(aFr, uint64(L))
+		if !a.IsEqual(&b) {
+			t.Errorf("Commit mismatch")
+		}
+
+		vcs.OpenAll(aFr)
+		large.OpenAll(aFr)
+		for l := range vcs.ProofTree {
+			if !SliceIsEqual(vcs.ProofTree[l], large.ProofTree[l]) {
+				t.Fatalf("Proof tree mismatch at level %d", l)
+			}
+		}
+	})
+
+	t.Run(fmt.Sprintf("%d/Corrupt;", L), func(t *testing.T) {
+		shards, err := mapped.UpkShards()
+		check(err)
+		last := shards[len(shards)-1] // Its first point is in level L
+		data, err := os.ReadFile(folder + last.Name)
+		check(err)
+		defer os.WriteFile(folder+last.Name, data, 0644)
+
+		bad := append([]byte{}, data...)
+		for i := 0; i < GetG1ByteSize(); i++ {
+			bad[i] = 0xFF
+		}
+		check(os.WriteFile(folder+last.Name, bad, 0644))
+
+		// UPK[1][0], which is on the path of index 0 but not its leaf
+		first := shards[0]
+		firstData, err := os.ReadFile(folder + first.Name)
+		check(err)
+		defer os.WriteFile(folder+first.Name, firstData, 0644)
+		badFirst := append([]byte{}, firstData...)
+		copy(badFirst[GetG1ByteSize():], bad[:GetG1ByteSize()])
+		check(os.WriteFile(folder+first.Name, badFirst, 0644))
+
+		corrupted := VCS{}
+		check(corrupted.Init(L, folder, 8))
+		check(corrupted.LoadManifest())
+		check(corrupted.LoadPublicParams(L))
+		check(corrupted.UpkMapDriver())
+		defer corrupted.UpkUnmap()
+
+		if _, err := corrupted.upkMmap.Point(last.Start); !errors.Is(err, ErrKeyFolderCorrupt) {
+			t.Errorf("Expected ErrKeyFolderCorrupt, got %v", err)
+		}
+		if _, err := corrupted.upkMmap.Point(last.Stop); !errors.Is(err, ErrBadIndex) {
+			t.Errorf("Expected ErrBadIndex, got %v", err)
+		}
+		batch := UpdateBatch{Indices: []uint64{last.Start - (N - 1)}, Deltas: GenerateVector(1)}
+		if _, err := (Digest{}).Update(&corrupted, batch); !errors.Is(err, ErrKeyFolderCorrupt) {
+			t.Errorf("Digest.Update: Expected ErrKeyFolderCorrupt, got %v", err)
+		}
+		proof := ProofPath{Index: 1, L: L, Nodes: make([]mcl.G1, L)}
+		if _, err := proof.Update(&corrupted, UpdateBatch{Indices: []uint64{0}, Deltas: GenerateVector(1)}); !errors.Is(err, ErrKeyFolderCorrupt) {
+			t.Errorf("ProofPath.Update: Expected ErrKeyFolderCorrupt, got %v", err)
+		}
+		server := NewProofServer(&corrupted, mcl.G1{})
+		if _, err := server.ApplyBlock([]uint64{0}, GenerateVector(1)); !errors.Is(err, ErrKeyFolderCorrupt) || server.Snapshot().Version != 0 {
+			t.Errorf("ApplyBlock: Expected ErrKeyFolderCorrupt, got %v", err)
+		}
+		if _, err := corrupted.CommitContext(context.Background(), GenerateVector(N), uint64(L)); !errors.Is(err, ErrKeyFolderCorrupt) {
+			t.Errorf("CommitContext: Expected ErrKeyFolderCorrupt, got %v", err)
+		}
+
+		// Truncated after the manifest was checked
+		truncated := VCS{}
+		check(truncated.Init(L, folder, 8))
+		check(truncated.LoadManifest())
+		check(truncated.LoadPublicParams(L))
+		check(os.WriteFile(folder+last.Name, data[:len(data)-GetG1ByteSize()], 0644))
+		if err := truncated.UpkMapDriver(); !errors.Is(err, ErrKeyFolderCorrupt) {
+			t.Errorf("UpkMapDriver: Expected ErrKeyFolderCorrupt, got %v", err)
+		}
+	})
+}
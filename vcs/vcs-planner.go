@@ -0,0 +1,322 @@
+// Capacity planning for ell and the block size, before any key is generated.
+// Sizes follow the file formats and the in-memory layout, see vcs-save-load.go and vcs-inspect.go.
+// Times are a cost per unit of work times the work of the operation, e.g., MN for AggregateProve.
+// There are no default costs. ReadBenchmarks takes them from the ns/op of the repo's benchmarks,
+// i.e., lines like "BenchmarkPrunedVCSMicro/26/AggregateProve;1024-16 4 123 ns/op",
+// plain or from go test -json, and the lines printed by main-bench.go. Operations without results are not timed.
+// Benchmarks that run on several cores, e.g., BenchmarkKeyLoad, report the number of workers as a "cores" metric.
+package vcs
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unsafe"
+
+	"github.com/alinush/go-mcl"
+	"github.com/hyperproofs/gipa-go/utils"
+)
+
+// Above this ell, the keys are not held in memory. See Init.
+const PARAM_TOO_LARGE_ELL = 24
+
+type NodeMode uint8
+
+const (
+	FullNode     NodeMode = iota // UPK tree and proof tree of all the indices
+	PrunedNode                   // Proofs of a block, UPK paths come with the block
+	VerifierNode                 // Digest only, checks the proofs and the aggregated proofs
+)
+
+func (mode NodeMode) String() string {
+	switch mode {
+	case FullNode:
+		return "full"
+	case PrunedNode:
+		return "pruned"
+	case VerifierNode:
+		return "verifier"
+	}
+	return fmt.Sprintf("NodeMode(%d)", uint8(mode))
+}
+
+func ParseNodeMode(s string) (NodeMode, error) {
+	for _, mode := range []NodeMode{FullNode, PrunedNode, VerifierNode} {
+		if s == mode.String() {
+			return mode, nil
+		}
+	}
+	return FullNode, fmt.Errorf("Unknown node mode %q, expected full, pruned or verifier", s)
+}
+
+// Largest block size Init accepts for ell.
+func MaxTxnLimit(L uint8) uint64 {
+	return MAX_AGG_SIZE / uint64(L)
+}
+
+type planOp struct {
+	name          string
+	bench         string // Name of the operation in the benchmark output
+	benchParallel bool   // The benchmark runs on the cores of its "cores" metric, or GOMAXPROCS without it. Otherwise on one core
+	parallel      bool   // Split across the cores
+	sizeIsWork    bool   // The benchmark reports the number of points instead of the block size
+	work          func(L, txn uint64) float64
+}
+
+// Work is the number of points of the multi-exponentiations, Miller loops or GIPA elements.
+var planOps = []planOp{
+	{"KeyGen", "FixedBase", false, true, true, func(L, txn uint64) float64 { return float64(uint64(2)<<L - 1) }},
+	{"KeyLoad", "UpkLoadDriver", true, true, true, func(L, txn uint64) float64 { return float64(uint64(2)<<L - 1) }},
+	{"Commit", "Commit", false, false, false, func(L, txn uint64) float64 { return float64(uint64(1) << L) }},
+	{"OpenAll", "OpenAll", false, false, false, func(L, txn uint64) float64 { return float64(L<<L) / 2 }},
+	{"UpdateComVec", "UpdateComVec", false, false, false, func(L, txn uint64) float64 { return float64(txn) }},
+	{"UpdateProofTreeBulk", "UpdateProofTreeBulk", false, false, false, func(L, txn uint64) float64 { return float64(txn * L) }},
+	{"VerifyMemoized", "VerifyMemoized", false, false, false, func(L, txn uint64) float64 { return float64(txn * L) }},
+	{"AggregateProve", "AggregateProve", false, false, false, func(L, txn uint64) float64 { return float64(utils.NextPowOf2(L * txn)) }},
+	{"AggregateVerify", "AggregateVerify", false, false, false, func(L, txn uint64) float64 { return float64(utils.NextPowOf2(L * txn)) }},
+}
+
+var modeOps = map[NodeMode][]string{
+	FullNode:     {"KeyGen", "KeyLoad", "Commit", "OpenAll", "UpdateComVec", "UpdateProofTreeBulk", "VerifyMemoized", "AggregateProve", "AggregateVerify"},
+	PrunedNode:   {"UpdateComVec", "UpdateProofTreeBulk", "VerifyMemoized", "AggregateProve", "AggregateVerify"},
+	VerifierNode: {"UpdateComVec", "VerifyMemoized", "AggregateVerify"},
+}
+
+// Cost per unit of work of each operation, in nanoseconds on one core.
+type Calibration struct {
+	Cost       map[string]float64
+	Calibrated map[string]bool // Set by ReadBenchmarks. Other operations are not timed
+}
+
+// Empty calibration, fill it with ReadBenchmarks.
+func NewCalibration() Calibration {
+	return Calibration{Cost: make(map[string]float64), Calibrated: make(map[string]bool)}
+}
+
+// Benchmark/<ell>/<Operation>;<txn or points>[-GOMAXPROCS] [iterations] <ns> ns/op [<cores> cores]
+var benchLine = regexp.MustCompile(`^Benchmark\S*?/(\d+)/(\w+);(\d+)(?:-(\d+))?\s+(?:\d+\s+)?([0-9.]+) ns/op`)
+var benchCores = regexp.MustCompile(`\s([0-9.]+) cores\b`)
+
+// Set the costs from benchmark output. Accepts go test -bench, go test -bench -json, and main-bench.go output.
+// Samples of the same operation are averaged. Returns the number of samples used.
+func (calib *Calibration) ReadBenchmarks(r io.Reader) (int, error) {
+
+	var text strings.Builder
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 1<<16), 1<<24)
+	for scanner.Scan() {
+		line := scanner.Text()
+		// go test -json may split a result across events, hence the output is joined first.
+		var event struct{ Output string }
+		if strings.HasPrefix(line, "{") && json.Unmarshal([]byte(line), &event) == nil {
+			text.WriteString(event.Output)
+		} else {
+			text.WriteString(line + "\n")
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+
+	byBench := make(map[string]planOp)
+	for _, op := range planOps {
+		byBench[op.bench] = op
+	}
+	sum := make(map[string]float64)
+	count := make(map[string]int)
+	for _, line := range strings.Split(text.String(), "\n") {
+		m := benchLine.FindStringSubmatch(strings.TrimSpace(line))
+		if m == nil {
+			continue
+		}
+		op, ok := byBench[m[2]]
+		if !ok {
+			continue
+		}
+		L, err1 := strconv.ParseUint(m[1], 10, 8)
+		size, err2 := strconv.ParseUint(m[3], 10, 64)
+		ns, err3 := strconv.ParseFloat(m[5], 64)
+		if err1 != nil || err2 != nil || err3 != nil || L == 0 || L >= 32 {
+			continue
+		}
+		procs := uint64(1) // Go omits the suffix when GOMAXPROCS is 1
+		if m[4] != "" {
+			procs, _ = strconv.ParseUint(m[4], 10, 64)
+		}
+		work := op.work(L, size)
+		if op.sizeIsWork {
+			work = float64(size)
+		}
+		if work == 0 {
+			continue
+		}
+		cores := 1.0
+		if op.benchParallel {
+			cores = float64(maxUint64(procs, 1))
+			if c := benchCores.FindStringSubmatch(line); c != nil {
+				if reported, err := strconv.ParseFloat(c[1], 64); err == nil && reported >= 1 {
+					cores = reported
+				}
+			}
+		}
+		sum[op.name] += ns * cores / work
+		count[op.name]++
+	}
+
+	samples := 0
+	for name, n := range count {
+		calib.Cost[name] = sum[name] / float64(n)
+		calib.Calibrated[name] = true
+		samples += n
+	}
+	if samples == 0 {
+		return 0, errors.New("No benchmark results found")
+	}
+	return samples, nil
+}
+
+type OpEstimate struct {
+	Name       string
+	Time       time.Duration // Zero unless Calibrated
+	Calibrated bool
+}
+
+type CapacityPlan struct {
+	L        uint8
+	N        uint64
+	TxnLimit uint64
+	NCores   uint8
+	Mode     NodeMode
+
+	MN            uint64 // Size of the GIPA instance, as in LoadAggGipa
+	MaxTxnLimit   uint64 // Largest block Init accepts for ell
+	ParamTooLarge bool   // UPK and PRK are streamed to files and mapped, not held in memory
+
+	// On disk, in bytes. KeySize is what the mode needs: the public parameters, VRK and the aggregation keys, plus UPK for full nodes.
+	UpkSize     uint64
+	PrkSize     uint64 // Only with WithPRK
+	AggKeysSize uint64
+	KeySize     uint64
+
+	// In memory, in bytes
+	ProofTreeRAM uint64 // The proof tree for full nodes, the proofs of a block for pruned nodes
+	RAM          uint64
+
+	// Sizes of the serialized objects, in bytes
+	ProofSize    uint64 // Proof of one index
+	AggProofSize uint64 // Aggregated proof of a block
+	BlockUpkSize uint64 // Bundle of UPK paths a pruned node needs per block of random indices. See vcs-upk-bundle.go
+
+	Ops []OpEstimate
+}
+
+// Predict the sizes and the times for a node in mode with blocks of txnLimit updates.
+// memoryEll is Config.MemoryEll, the largest ell whose keys are held in memory. 0 defaults to PARAM_TOO_LARGE_ELL, as in Init.
+// calib may be nil, then only the sizes are predicted. The plan is filled even when the parameters are rejected by Init.
+func Plan(L uint8, txnLimit uint64, ncores uint8, memoryEll uint8, mode NodeMode, calib *Calibration) (CapacityPlan, error) {
+
+	plan := CapacityPlan{L: L, TxnLimit: txnLimit, NCores: ncores, Mode: mode}
+	if L == 0 || L >= 32 {
+		return plan, fmt.Errorf("Plan: Bad ell %d", L)
+	}
+	if txnLimit == 0 || ncores == 0 {
+		return plan, errors.New("Plan: Block size and cores must be positive")
+	}
+	if _, ok := modeOps[mode]; !ok {
+		return plan, fmt.Errorf("Plan: Unknown mode %v", mode)
+	}
+	if calib == nil {
+		empty := NewCalibration()
+		calib = &empty
+	}
+
+	g1 := uint64(GetG1ByteSize())
+	g2 := uint64(GetG2ByteSize())
+	gt := uint64(GetGTByteSize())
+	ell := uint64(L)
+	plan.N = uint64(1) << L
+	plan.MN = utils.NextPowOf2(ell * txnLimit)
+	plan.MaxTxnLimit = MaxTxnLimit(L)
+	plan.ParamTooLarge = L > Config{MemoryEll: memoryEll}.withDefaults().MemoryEll
+
+	// Files of KeyGen. Aggregation keys are always generated for MAX_AGG_SIZE, see GenAggGipa.
+	plan.UpkSize = (2*plan.N - 1) * g1
+	plan.PrkSize = plan.N * g1
+	plan.AggKeysSize = aggKeysSize(MAX_AGG_SIZE)
+	plan.KeySize = 8 + g1 + g2 + 3*ell*g2 + plan.AggKeysSize
+	if mode == FullNode {
+		plan.KeySize += plan.UpkSize
+	}
+
+	info := KeyFolderInfo{L: L, N: plan.N}
+	info.estimateRAM(txnLimit)
+	inMemoryG1 := uint64(unsafe.Sizeof(mcl.G1{}))
+	switch mode {
+	case FullNode:
+		plan.ProofTreeRAM = (plan.N - 1) * inMemoryG1
+		plan.RAM = info.RAMFull
+		if plan.ParamTooLarge {
+			plan.RAM -= (2*plan.N - 1) * inMemoryG1 // UPK is mapped
+		}
+	case PrunedNode:
+		plan.ProofTreeRAM = txnLimit * ell * inMemoryG1
+		plan.RAM = info.RAMPruned
+	case VerifierNode:
+		plan.RAM = 3*ell*uint64(unsafe.Sizeof(mcl.G2{})) + info.RAMAggKeys
+	}
+
+	// batch.Proof: T, and the GIPA-KZG proof with log MN left and right commitments of 3 GT each, then A, B, W, V, Pi1 and Pi2.
+	rounds := uint64(math.Log2(float64(plan.MN)))
+	plan.ProofSize = ell * g1
+	plan.AggProofSize = gt + 6*rounds*gt + 3*(g1+g2)
+	plan.BlockUpkSize = uint64(len(UPK_BUNDLE_MAGIC)) + 4 + 16 + 8*txnLimit + expectedUpkNodes(L, txnLimit)*g1
+
+	for _, name := range modeOps[mode] {
+		for _, op := range planOps {
+			if op.name != name {
+				continue
+			}
+			if !calib.Calibrated[name] {
+				plan.Ops = append(plan.Ops, OpEstimate{Name: name})
+				continue
+			}
+			ns := calib.Cost[name] * op.work(ell, txnLimit)
+			if op.parallel {
+				ns /= float64(ncores)
+			}
+			plan.Ops = append(plan.Ops, OpEstimate{Name: name, Time: time.Duration(ns), Calibrated: true})
+		}
+	}
+
+	if ell*txnLimit > MAX_AGG_SIZE {
+		return plan, fmt.Errorf("Plan: Block of %d updates needs %d proofs aggregated, above MAX_AGG_SIZE = %d. Use at most %d updates", txnLimit, ell*txnLimit, MAX_AGG_SIZE, plan.MaxTxnLimit)
+	}
+	return plan, nil
+}
+
+// Bytes of CKNAME and KZGNAME for aggregation keys of mn proofs.
+func aggKeysSize(mn uint64) uint64 {
+	g1 := uint64(GetG1ByteSize())
+	g2 := uint64(GetG2ByteSize())
+	ck := 8 + mn*(g1+g2)
+	kzg := 8 + 2*(g1+g2) + (2*mn-1)*(g1+g2)
+	return ck + kzg
+}
+
+// Expected number of distinct UPK nodes in the paths of txn random indices.
+// Level l has 2^l nodes, each hit with probability 1 - (1 - 2^-l)^txn.
+func expectedUpkNodes(L uint8, txn uint64) uint64 {
+	nodes := 0.0
+	for l := uint8(1); l <= L; l++ {
+		width := math.Ldexp(1, int(l))
+		nodes += width * -math.Expm1(float64(txn)*math.Log1p(-1/width))
+	}
+	return uint64(math.Ceil(nodes))
+}
@@ -0,0 +1,139 @@
+package vcs
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alinush/go-mcl"
+)
+
+func TestPlan(t *testing.T) {
+
+	mcl.InitFromString("bls12-381")
+
+	L := uint8(8)
+	txnLimit := uint64(16)
+
+	t.Run(fmt.Sprintf("%d/Sizes;%d", L, txnLimit), func(t *testing.T) {
+		folder := t.TempDir()
+		keys := VCS{Config: Config{WithPRK: true}}
+		keyGenSmallAgg(&keys, L, folder, txnLimit)
+		info, err := keys.InspectKeys(folder, txnLimit)
+		check(err)
+
+		plan, err := Plan(L, txnLimit, 16, 0, FullNode, nil)
+		check(err)
+		sizes := make(map[string]uint64)
+		for _, file := range info.Files {
+			if strings.HasPrefix(file.Name, "/upk-") {
+				sizes["upk"] += file.Size
+			} else if strings.HasPrefix(file.Name, "/prk-") {
+				sizes["prk"] += file.Size
+			} else {
+				sizes[file.Name] = file.Size
+			}
+		}
+		if plan.UpkSize != sizes["upk"] || plan.PrkSize != sizes["prk"] {
+			t.Errorf("UPK %d and PRK %d bytes, expected %d and %d", plan.UpkSize, plan.PrkSize, sizes["upk"], sizes["prk"])
+		}
+		// The folder has aggregation keys for keys.MN proofs, the plan assumes MAX_AGG_SIZE as KeyGen.
+		if aggKeysSize(keys.MN) != sizes[CKNAME]+sizes[KZGNAME] || plan.AggKeysSize != aggKeysSize(MAX_AGG_SIZE) {
+			t.Errorf("Aggregation keys are %d bytes, expected %d", aggKeysSize(keys.MN), sizes[CKNAME]+sizes[KZGNAME])
+		}
+		if plan.KeySize != plan.UpkSize+plan.AggKeysSize+sizes[PPNAME]+sizes[VRKNAME] {
+			t.Errorf("Key size %d does not add up", plan.KeySize)
+		}
+		if plan.RAM != info.RAMFull || plan.ProofSize != uint64(L)*uint64(GetG1ByteSize()) {
+			t.Errorf("Bad estimates: %+v", plan)
+		}
+
+		pruned, err := Plan(L, txnLimit, 16, 0, PrunedNode, nil)
+		check(err)
+		verifier, err := Plan(L, txnLimit, 16, 0, VerifierNode, nil)
+		check(err)
+		if !(verifier.RAM < pruned.RAM && pruned.RAM < plan.RAM) || verifier.KeySize != pruned.KeySize {
+			t.Errorf("Memory should shrink from full to verifier nodes: %d %d %d", plan.RAM, pruned.RAM, verifier.RAM)
+		}
+		if len(verifier.Ops) >= len(pruned.Ops) || len(pruned.Ops) >= len(plan.Ops) {
+			t.Errorf("Unexpected operations per mode")
+		}
+		for _, op := range plan.Ops {
+			if op.Calibrated || op.Time != 0 {
+				t.Errorf("%s is timed without benchmark results", op.Name)
+			}
+		}
+		if expectedUpkNodes(L, 1) != uint64(L) || expectedUpkNodes(L, 1<<20) != (uint64(2)<<L)-2 {
+			t.Errorf("Bad count of UPK nodes")
+		}
+	})
+
+	t.Run(fmt.Sprintf("%d/Limits;%d", L, txnLimit), func(t *testing.T) {
+		plan, err := Plan(26, MAX_AGG_SIZE, 16, 0, FullNode, nil)
+		if err == nil || plan.MaxTxnLimit != MAX_AGG_SIZE/26 || !plan.ParamTooLarge {
+			t.Errorf("Block above MAX_AGG_SIZE was accepted: %+v", plan)
+		}
+		if _, err := Plan(26, plan.MaxTxnLimit, 16, 0, FullNode, nil); err != nil {
+			t.Error(err)
+		}
+		// Same threshold as Init with Config.MemoryEll
+		full, _ := Plan(L, txnLimit, 16, 0, FullNode, nil)
+		small, _ := Plan(L, txnLimit, 16, L-1, FullNode, nil)
+		vcs := NewVCS(Config{MemoryEll: L - 1, Log: io.Discard})
+		check(vcs.Init(L, t.TempDir(), txnLimit))
+		if full.ParamTooLarge || !small.ParamTooLarge || !vcs.PARAM_TOO_LARGE || small.RAM >= full.RAM {
+			t.Errorf("MemoryEll = %d was not applied for ell = %d", L-1, L)
+		}
+		if _, err := ParseNodeMode("archive"); err == nil {
+			t.Errorf("Unknown mode was accepted")
+		}
+		if mode, err := ParseNodeMode("pruned"); err != nil || mode != PrunedNode {
+			t.Errorf("Got %v, %v", mode, err)
+		}
+	})
+
+	t.Run(fmt.Sprintf("%d/Calibrate;%d", L, txnLimit), func(t *testing.T) {
+		// Plain output, main-bench.go output, and a result split across go test -json events.
+		bench := strings.Join([]string{
+			"BenchmarkPrunedVCSMicro/10/UpdateComVec;1024-16         \t       4\t   2048000 ns/op\t  100 B/op",
+			"BenchmarkVCS/20/Commit;1024                                 1048576 ns/op",
+			`{"Action":"output","Test":"BenchmarkPrunedVCSMicro/10/AggregateProve;1024","Output":"BenchmarkPrunedVCSMicro/10/AggregateProve;1024-16         \t"}`,
+			`{"Action":"output","Test":"BenchmarkPrunedVCSMicro/10/AggregateProve;1024","Output":"       4\t  16384000 ns/op\n"}`,
+			"BenchmarkKeyLoad/16/UpkLoadDriver;131071-8   \t  2\t 131071000 ns/op\t         4.000 cores",
+			"BenchmarkPrunedVCSMicro/10/Unknown;1024-16   \t  4\t 1 ns/op",
+		}, "\n")
+
+		calib := NewCalibration()
+		samples, err := calib.ReadBenchmarks(strings.NewReader(bench))
+		check(err)
+		expected := map[string]float64{"UpdateComVec": 2000, "Commit": 1, "AggregateProve": 1000, "KeyLoad": 4000}
+		if samples != len(expected) {
+			t.Errorf("Used %d samples, expected %d", samples, len(expected))
+		}
+		for name, ns := range expected {
+			if calib.Cost[name] != ns || !calib.Calibrated[name] {
+				t.Errorf("%s costs %f ns, expected %f", name, calib.Cost[name], ns)
+			}
+		}
+
+		plan, err := Plan(10, 1024, 8, 0, FullNode, &calib)
+		check(err)
+		for _, op := range plan.Ops {
+			if op.Name == "AggregateProve" && op.Time != 16384*time.Microsecond {
+				t.Errorf("AggregateProve takes %v, expected the benchmark", op.Time)
+			}
+			if op.Name == "KeyLoad" && op.Time != 1023500*time.Nanosecond {
+				t.Errorf("KeyLoad takes %v on 8 cores", op.Time)
+			}
+			if op.Name == "OpenAll" && op.Calibrated {
+				t.Errorf("OpenAll is not in the benchmarks")
+			}
+		}
+
+		if _, err := calib.ReadBenchmarks(strings.NewReader("ok  vcs  1.0s")); err == nil {
+			t.Errorf("Output without benchmarks was accepted")
+		}
+	})
+}
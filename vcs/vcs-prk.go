@@ -0,0 +1,113 @@
+// PRK and the monomial basis.
+// PRK[i] = g^{prod of s_j for the bits j set in i}, i.e., the monomials of a multilinear polynomial evaluated at the trapdoors.
+// A vector of coefficients c commits to f(x) = sum_i c_i x^i, which is the same commitment as Commit on the evaluations of f.
+// Thus, openings in the monomial basis are the usual proofs and verify with VerifyMemoized and AggVerify.
+package vcs
+
+import (
+	"github.com/alinush/go-mcl"
+)
+
+// Map the PRK shards of the folder. Used instead of PrkLoadDriver when PARAM_TOO_LARGE.
+func (vcs *VCS) PrkMapDriver() error {
+	shards, err := vcs.PrkShards()
+	if err != nil {
+		return err
+	}
+	vcs.prkMmap, err = vcs.mapShards(shards)
+	return err
+}
+
+// PRK[i] from memory, or from the mapped files if PRK is not in memory. Panics if the mapped point does not decode, see PointsMmap.Point
+func (vcs *VCS) PrkNode(i uint64) mcl.G1 {
+	if vcs.prkMmap != nil && vcs.PRK == nil {
+		return vcs.prkMmap.At(i)
+	}
+	return vcs.PRK[i]
+}
+
+// PRK[start:stop]. Decoded from the mapped files if PRK is not in memory.
+func (vcs *VCS) PrkRange(start uint64, stop uint64) []mcl.G1 {
+	if vcs.prkMmap != nil && vcs.PRK == nil {
+		prk := make([]mcl.G1, stop-start)
+		for i := range prk {
+			prk[i] = vcs.prkMmap.At(start + uint64(i))
+		}
+		return prk
+	}
+	return vcs.PRK[start:stop]
+}
+
+// Commit to the multilinear polynomial with coefficients c using PRK[0:len(c)].
+// PRK is read in chunks of LOAD_CHUNK points, hence this works with PrkMapDriver for any ell.
+func (vcs *VCS) CommitMonomial(c []mcl.Fr) mcl.G1 {
+
+	var digest, temp mcl.G1
+	digest.Clear()
+	n := uint64(len(c))
+	for start := uint64(0); start < n; start += LOAD_CHUNK {
+		stop := minUint64(start+LOAD_CHUNK, n)
+		mcl.G1MulVec(&temp, vcs.PrkRange(start, stop), c[start:stop])
+		mcl.G1Add(&digest, &digest, &temp)
+	}
+	return digest
+}
+
+// Same as OpenAll, but the vector is given as coefficients.
+// f = f_0(x') + x_{l-1} g(x'), where f_0 and g are the lower and upper halves of c.
+// Quotient for x_{l-1} is g. The subtrees are f_0 (x_{l-1} = 0) and f_0 + g (x_{l-1} = 1).
+func (vcs *VCS) OpenAllMonomial(c []mcl.Fr) {
+
+	vcs.treeMu.Lock()
+	defer vcs.treeMu.Unlock()
+	vcs.ProofTree = make([][]mcl.G1, vcs.L)
+	for i := uint8(0); i < vcs.L; i++ {
+		vcs.ProofTree[i] = make([]mcl.G1, 1<<i)
+	}
+	vcs.OpenAllMonomialRec(c, 0, vcs.L)
+}
+
+func (vcs *VCS) OpenAllMonomialRec(c []mcl.Fr, index uint64, L uint8) {
+
+	if len(c) <= 1 {
+		return
+	}
+
+	mid := len(c) / 2
+	vcs.ProofTree[vcs.L-L][index] = vcs.CommitMonomial(c[mid:])
+
+	right := make([]mcl.Fr, mid)
+	for i := 0; i < mid; i++ {
+		mcl.FrAdd(&right[i], &c[i], &c[i+mid])
+	}
+	vcs.OpenAllMonomialRec(c[:mid], 2*index, L-1)
+	vcs.OpenAllMonomialRec(right, 2*index+1, L-1)
+}
+
+// Evaluations of the multilinear polynomial on the hypercube, i.e., a_k = sum of c_i over the i whose bits are a subset of those of k.
+func MonomialToEvals(c []mcl.Fr) []mcl.Fr {
+	a := make([]mcl.Fr, len(c))
+	copy(a, c)
+	for h := 1; h < len(a); h *= 2 {
+		for k := range a {
+			if k&h != 0 {
+				mcl.FrAdd(&a[k], &a[k], &a[k^h])
+			}
+		}
+	}
+	return a
+}
+
+// Inverse of MonomialToEvals.
+func EvalsToMonomial(a []mcl.Fr) []mcl.Fr {
+	c := make([]mcl.Fr, len(a))
+	copy(c, a)
+	for h := 1; h < len(c); h *= 2 {
+		for k := range c {
+			if k&h != 0 {
+				mcl.FrSub(&c[k], &c[k], &c[k^h])
+			}
+		}
+	}
+	return c
+}
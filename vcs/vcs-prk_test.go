@@ -0,0 +1,87 @@
+package vcs
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/alinush/go-mcl"
+)
+
+// Commit and open in the monomial basis, with PRK in memory and mapped from the files.
+func TestPrkMonomial(t *testing.T) {
+
+	mcl.InitFromString("bls12-381")
+	r := testReader(t)
+
+	L := uint8(8)
+	N := uint64(1) << L
+	K := 8
+	txnLimit := uint64(K)
+	folder := t.TempDir()
+
+	vcs := VCS{Config: Config{WithPRK: true}}
+	check(vcs.KeyGen(16, L, folder, txnLimit))
+
+	c, err := GenerateVectorFrom(N, r)
+	check(err)
+	aFr := MonomialToEvals(c)
+	digest := vcs.Commit(aFr, uint64(L))
+
+	t.Run(fmt.Sprintf("%d/Basis;%d", L, N), func(t *testing.T) {
+		back := EvalsToMonomial(aFr)
+		for i := range c {
+			if !back[i].IsEqual(&c[i]) {
+				t.Fatalf("EvalsToMonomial mismatch at %d", i)
+			}
+		}
+		com := vcs.CommitMonomial(c)
+		if !com.IsEqual(&digest) {
+			t.Errorf("CommitMonomial differs from Commit")
+		}
+	})
+
+	t.Run(fmt.Sprintf("%d/OpenAllMonomial;%d", L, N), func(t *testing.T) {
+		vcs.OpenAll(aFr)
+		expected := vcs.ProofTree
+		vcs.OpenAllMonomial(c)
+		for l := range expected {
+			if !SliceIsEqual(expected[l], vcs.ProofTree[l]) {
+				t.Fatalf("Proof tree mismatch at level %d", l)
+			}
+		}
+
+		indexVec := make([]uint64, K)
+		proofVec := make([][]mcl.G1, K)
+		valueVec := make([]mcl.Fr, K)
+		for k := 0; k < K; k++ {
+			var err error
+			if indexVec[k], err = RandomIndex(r, N); err != nil {
+				t.Fatal(err)
+			}
+			proofVec[k] = vcs.GetProofPath(indexVec[k])
+			valueVec[k] = aFr[indexVec[k]]
+		}
+		status, _ := vcs.VerifyMemoized(digest, indexVec, valueVec, proofVec)
+		if !status {
+			t.Errorf("Monomial proofs failed to verify")
+		}
+	})
+
+	t.Run(fmt.Sprintf("%d/PrkMapDriver;%d", L, N), func(t *testing.T) {
+		mapped := VCS{Config: Config{WithPRK: true}}
+		check(mapped.Init(L, folder, txnLimit))
+		check(mapped.LoadManifest())
+		check(mapped.LoadParams(L))
+		check(mapped.PrkMapDriver())
+		for i := uint64(0); i < N; i++ {
+			p := mapped.PrkNode(i)
+			if !p.IsEqual(&vcs.PRK[i]) {
+				t.Fatalf("PRK mismatch at %d", i)
+			}
+		}
+		com := mapped.CommitMonomial(c)
+		if !com.IsEqual(&digest) {
+			t.Errorf("CommitMonomial with mapped PRK differs from Commit")
+		}
+	})
+}
@@ -0,0 +1,108 @@
+// Proof serving node that answers queries while it applies blocks.
+// Every proof is read from one version of the proof tree and returned with the digest of that version.
+// A block is applied in two steps: The changes of the nodes and the new digest are computed without any lock,
+// and then added to the tree under the write lock of the VCS. Readers wait only for the second step.
+package vcs
+
+import (
+	"sync"
+
+	"github.com/alinush/go-mcl"
+)
+
+// Digest of the vector after Version blocks. Proofs returned with a Snapshot verify against its Digest.
+type Snapshot struct {
+	Digest  mcl.G1
+	Version uint64
+}
+
+type ProofServer struct {
+	vcs     *VCS
+	blockMu sync.Mutex // One block is applied at a time
+	current Snapshot   // Guarded by vcs.treeMu, as it changes with the tree
+}
+
+// Serve the proofs of vcs.ProofTree, which must be the tree of digest, e.g., after OpenAll.
+// Once served, the tree must be updated only through ApplyBlock. UpdateProofTree does not change the digest.
+func NewProofServer(vcs *VCS, digest mcl.G1) *ProofServer {
+	return &ProofServer{vcs: vcs, current: Snapshot{Digest: digest}}
+}
+
+// Commit to a and build its proof tree. This takes as long as OpenAll.
+func NewProofServerFromVector(vcs *VCS, a []mcl.Fr) *ProofServer {
+	digest := vcs.Commit(a, uint64(vcs.L))
+	vcs.OpenAll(a)
+	return NewProofServer(vcs, digest)
+}
+
+func (s *ProofServer) Snapshot() Snapshot {
+	s.vcs.treeMu.RLock()
+	defer s.vcs.treeMu.RUnlock()
+	return s.current
+}
+
+// Proof of index and the digest it belongs to. Returns ErrBadIndex if index >= N.
+func (s *ProofServer) GetProofPath(index uint64) ([]mcl.G1, Snapshot, error) {
+
+	proofs, snapshot, err := s.GetProofPaths([]uint64{index})
+	if err != nil {
+		return nil, snapshot, err
+	}
+	return proofs[0], snapshot, nil
+}
+
+// Proofs of all the indices, read from the same version. Thus, they can be checked together with VerifyMemoized or AggProve.
+func (s *ProofServer) GetProofPaths(indexVec []uint64) ([][]mcl.G1, Snapshot, error) {
+
+	vcs := s.vcs
+	for _, index := range indexVec {
+		if index >= vcs.N {
+			return nil, Snapshot{}, newError(ErrBadIndex, "GetProofPaths: Index %d is out of range for ell = %d", index, vcs.L)
+		}
+	}
+
+	vcs.treeMu.RLock()
+	defer vcs.treeMu.RUnlock()
+	proofVec := make([][]mcl.G1, len(indexVec))
+	for t, index := range indexVec {
+		proofVec[t] = vcs.proofPath(index)
+	}
+	return proofVec, s.current, nil
+}
+
+// Apply the updates of a block to the digest and the proof tree, and return the new version.
+// Returns ErrBadBlockSize if the vectors differ in length or exceed TxnLimit, and ErrBadIndex for an index >= N.
+// With the mapped UPK tree, a point that does not decode is reported as ErrKeyFolderCorrupt. Nothing is applied on error.
+func (s *ProofServer) ApplyBlock(updateindexVec []uint64, deltaVec []mcl.Fr) (Snapshot, error) {
+
+	vcs := s.vcs
+	if len(updateindexVec) != len(deltaVec) || uint64(len(updateindexVec)) > vcs.TxnLimit {
+		return Snapshot{}, newError(ErrBadBlockSize, "ApplyBlock: Got %d indices and %d deltas for a block of at most %d", len(updateindexVec), len(deltaVec), vcs.TxnLimit)
+	}
+	for _, index := range updateindexVec {
+		if index >= vcs.N {
+			return Snapshot{}, newError(ErrBadIndex, "ApplyBlock: Index %d is out of range for ell = %d", index, vcs.L)
+		}
+	}
+
+	s.blockMu.Lock()
+	defer s.blockMu.Unlock()
+
+	// Only this goroutine changes current, thus it can be read without the tree lock.
+	next := Snapshot{Version: s.current.Version + 1}
+	digest, err := Digest(s.current.Digest).Update(vcs, UpdateBatch{Indices: updateindexVec, Deltas: deltaVec})
+	if err != nil {
+		return Snapshot{}, err
+	}
+	next.Digest = mcl.G1(digest)
+	changes, err := vcs.proofTreeChanges(updateindexVec, deltaVec)
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	vcs.treeMu.Lock()
+	defer vcs.treeMu.Unlock()
+	vcs.applyProofTreeChanges(changes)
+	s.current = next
+	return next, nil
+}
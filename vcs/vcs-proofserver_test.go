@@ -0,0 +1,104 @@
+package vcs
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/alinush/go-mcl"
+)
+
+// Readers verify every proof against the digest it was returned with while blocks are applied.
+func TestProofServer(t *testing.T) {
+
+	mcl.InitFromString("bls12-381")
+
+	L := uint8(6)
+	txnLimit := uint64(8)
+	numBlocks := 4
+	vcs, err := NewVCSInMemory(16, L, txnLimit)
+	check(err)
+	aFr := GenerateVector(vcs.N)
+	server := NewProofServerFromVector(vcs, aFr)
+
+	// Vector after each version, to check the values read along with the proofs.
+	versions := make([][]mcl.Fr, numBlocks+1)
+	versions[0] = append([]mcl.Fr{}, aFr...)
+	blocks := make([][]uint64, numBlocks)
+	deltas := make([][]mcl.Fr, numBlocks)
+	for b := 0; b < numBlocks; b++ {
+		blocks[b] = make([]uint64, txnLimit)
+		deltas[b] = GenerateVector(txnLimit)
+		versions[b+1] = append([]mcl.Fr{}, versions[b]...)
+		for k := range blocks[b] {
+			blocks[b][k] = (uint64(b)*7 + uint64(k)*11) % vcs.N
+			mcl.FrAdd(&versions[b+1][blocks[b][k]], &versions[b+1][blocks[b][k]], &deltas[b][k])
+		}
+	}
+
+	t.Run(fmt.Sprintf("%d/Snapshot;%d", L, txnLimit), func(t *testing.T) {
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		seen := make(map[uint64]bool)
+		for r := 0; r < 4; r++ {
+			wg.Add(1)
+			go func(r int) {
+				defer wg.Done()
+				for q := uint64(0); q < 6; q++ {
+					indexVec := []uint64{(uint64(r) + q*5) % vcs.N, (uint64(r)*3 + q) % vcs.N}
+					proofVec, snapshot, err := server.GetProofPaths(indexVec)
+					check(err)
+					values := versions[snapshot.Version]
+					for k, index := range indexVec {
+						if !vcs.Verify(snapshot.Digest, index, values[index], proofVec[k]) {
+							t.Errorf("Proof of %d does not belong to version %d", index, snapshot.Version)
+						}
+					}
+					mu.Lock()
+					seen[snapshot.Version] = true
+					mu.Unlock()
+				}
+			}(r)
+		}
+		for b := range blocks {
+			snapshot, err := server.ApplyBlock(blocks[b], deltas[b])
+			check(err)
+			if snapshot.Version != uint64(b+1) {
+				t.Errorf("Expected version %d, got %d", b+1, snapshot.Version)
+			}
+		}
+		wg.Wait()
+		if len(seen) == 0 {
+			t.Fatalf("No proof was served")
+		}
+	})
+
+	t.Run(fmt.Sprintf("%d/Latest;%d", L, txnLimit), func(t *testing.T) {
+		snapshot := server.Snapshot()
+		expected := vcs.Commit(versions[numBlocks], uint64(L))
+		if snapshot.Version != uint64(numBlocks) || !snapshot.Digest.IsEqual(&expected) {
+			t.Fatalf("Digest does not match the vector after %d blocks", numBlocks)
+		}
+		proof, proofSnapshot, err := server.GetProofPath(5)
+		check(err)
+		if proofSnapshot != snapshot || !vcs.Verify(snapshot.Digest, 5, versions[numBlocks][5], proof) {
+			t.Errorf("Proof of the latest version failed to verify")
+		}
+	})
+
+	t.Run(fmt.Sprintf("%d/Errors;%d", L, txnLimit), func(t *testing.T) {
+		if _, _, err := server.GetProofPath(vcs.N); !errors.Is(err, ErrBadIndex) {
+			t.Errorf("Expected %q, got %v", ErrBadIndex, err)
+		}
+		if _, err := server.ApplyBlock(blocks[0], deltas[0][1:]); !errors.Is(err, ErrBadBlockSize) {
+			t.Errorf("Expected %q, got %v", ErrBadBlockSize, err)
+		}
+		if _, err := server.ApplyBlock([]uint64{vcs.N}, deltas[0][:1]); !errors.Is(err, ErrBadIndex) {
+			t.Errorf("Expected %q, got %v", ErrBadIndex, err)
+		}
+		if server.Snapshot().Version != uint64(numBlocks) {
+			t.Errorf("A rejected block was applied")
+		}
+	})
+}
@@ -13,14 +13,13 @@ import (
 // Also check if the aggregation works.
 func TestVCSPruned(t *testing.T) {
 
-	folderPath := fmt.Sprintf("../pkvk-30")
 	L := uint8(30)
 	txnscount := uint64(512)
 	var status bool
 
 	vcs := VCS{}
-	// Besure to have the trapdoors and key generated.
-	vcs.KeyGenLoadFake(16, L, folderPath, 1<<12)
+	// Only the trapdoors and the aggregation keys are needed. See vcs-inmemory.go
+	check(vcs.KeyGenFakeInMemory(16, L, txnscount))
 	digest, indexVec, valueVec, upk_db, proofVec, proofTree := vcs.GenProofsTreeFake(txnscount)
 
 	for i := range valueVec {
@@ -78,18 +77,23 @@ func TestVCSPruned(t *testing.T) {
 
 	// Just to be doubly sure, we are making sure that aggregation code is also fine with fake proofs.
 	vcs.ResizeAgg(txnscount)
-	vcs.LoadAggGipa()
+	check(vcs.LoadAggGipa())
 
 	var aggProof batch.Proof
 	var aggProofs []batch.Proof
 
-	aggProof = vcs.AggProve(indexVec[:txnscount], proofVec[:txnscount])
+	aggProof, err := vcs.AggProve(indexVec[:txnscount], proofVec[:txnscount])
+	check(err)
 	aggProofs = append(aggProofs, aggProof)
 
 	t.Run(fmt.Sprintf("%d/AggregateVerify;%d", L, txnscount), func(t *testing.T) {
 
 		aggProof, aggProofs = aggProofs[0], aggProofs[1:]
-		status = status && vcs.AggVerify(aggProof, digest, indexVec[:txnscount], valueVec[:txnscount])
+		ok, err := vcs.AggVerify(aggProof, digest, indexVec[:txnscount], valueVec[:txnscount])
+		if err != nil {
+			t.Fatal(err)
+		}
+		status = status && ok
 
 		if status == false {
 			t.Errorf("Aggregation failed")
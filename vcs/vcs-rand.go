@@ -0,0 +1,122 @@
+// Source of randomness for trapdoors, fake proofs and vectors.
+// By default, mcl's CSPRNG is used. A seeded source makes the key folders and the test fixtures reproducible.
+// Randomness used by the verifiers (e.g., VerifyUPK) and by ceremony contributions always comes from the CSPRNG.
+package vcs
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"io"
+	"math/rand"
+
+	"github.com/alinush/go-mcl"
+)
+
+// Deterministic stream of bytes: SHA-256(seed || 0) || SHA-256(seed || 1) || ...
+type seededReader struct {
+	seed    []byte
+	counter uint64
+	buf     []byte
+}
+
+func NewSeededReader(seed []byte) io.Reader {
+	r := seededReader{}
+	r.seed = make([]byte, len(seed))
+	copy(r.seed, seed)
+	return &r
+}
+
+func (r *seededReader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		if len(r.buf) == 0 {
+			block := make([]byte, len(r.seed)+8)
+			copy(block, r.seed)
+			binary.LittleEndian.PutUint64(block[len(r.seed):], r.counter)
+			digest := sha256.Sum256(block)
+			r.buf = digest[:]
+			r.counter++
+		}
+		k := copy(p[n:], r.buf)
+		r.buf = r.buf[k:]
+		n += k
+	}
+	return n, nil
+}
+
+// Use r to sample the trapdoors, fake proofs and vectors. Set it before KeyGen.
+// nil restores mcl's CSPRNG.
+func (vcs *VCS) SetRandomSource(r io.Reader) {
+	vcs.rand = r
+}
+
+// Two instances with the same seed generate bit-identical keys.
+func (vcs *VCS) SetSeed(seed []byte) {
+	vcs.SetRandomSource(NewSeededReader(seed))
+}
+
+// Sample x using r. nil falls back to mcl's CSPRNG. Returns the error of r, e.g., io.ErrUnexpectedEOF if it runs short.
+func RandomFr(r io.Reader, x *mcl.Fr) error {
+	if r == nil {
+		x.Random()
+		return nil
+	}
+	buf := make([]byte, 2*GetFrByteSize()) // Twice the size of Fr, so the bias of the reduction is negligible.
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return err
+	}
+	return x.SetLittleEndianMod(buf)
+}
+
+// Same as mcl's G1.Random, but the bytes are read from r.
+func RandomG1(r io.Reader, x *mcl.G1) error {
+	if r == nil {
+		x.Random()
+		return nil
+	}
+	buf := make([]byte, GetFrByteSize())
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return err
+	}
+	return x.HashAndMapTo(buf)
+}
+
+// Same as mcl's G2.Random, but the bytes are read from r.
+func RandomG2(r io.Reader, x *mcl.G2) error {
+	if r == nil {
+		x.Random()
+		return nil
+	}
+	buf := make([]byte, GetFrByteSize())
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return err
+	}
+	return x.HashAndMapTo(buf)
+}
+
+// Uniform in [0, n). n is assumed to be much smaller than 2^64, e.g., the size of the vector.
+// nil falls back to math/rand.
+func RandomIndex(r io.Reader, n uint64) (uint64, error) {
+	if r == nil {
+		return uint64(rand.Int63n(int64(n))), nil
+	}
+	buf := make([]byte, 8)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint64(buf) % n, nil
+}
+
+// Same as GenerateVector, but the elements are read from r.
+func GenerateVectorFrom(N uint64, r io.Reader) ([]mcl.Fr, error) {
+	if r == nil {
+		return GenerateVector(N), nil
+	}
+	aFr := make([]mcl.Fr, N)
+	for i := range aFr {
+		if err := RandomFr(r, &aFr[i]); err != nil {
+			return nil, err
+		}
+	}
+	return aFr, nil
+}
@@ -0,0 +1,92 @@
+package vcs
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/alinush/go-mcl"
+)
+
+// Same seed must give bit-identical key folders and fake proofs.
+func TestSeededKeyGen(t *testing.T) {
+
+	mcl.InitFromString("bls12-381")
+
+	L := uint8(6)
+	txnLimit := uint64(8)
+	seed := []byte("hyperproofs-go test seed")
+	folders := []string{t.TempDir(), t.TempDir()}
+
+	instances := make([]VCS, len(folders))
+	for i := range folders {
+		instances[i].SetSeed(seed)
+		check(instances[i].KeyGen(16, L, folders[i], txnLimit))
+	}
+
+	t.Run(fmt.Sprintf("%d/KeyFolder;", L), func(t *testing.T) {
+		files, err := filepath.Glob(folders[0] + "/*.data")
+		if err != nil || len(files) == 0 {
+			t.Fatalf("No key files found: %v", err)
+		}
+		for _, file := range files {
+			a, err := ioutil.ReadFile(file)
+			check(err)
+			b, err := ioutil.ReadFile(folders[1] + "/" + filepath.Base(file))
+			check(err)
+			if !bytes.Equal(a, b) {
+				t.Errorf("%s differs between the runs", filepath.Base(file))
+			}
+		}
+	})
+
+	t.Run(fmt.Sprintf("%d/GenProofsTreeFake;", L), func(t *testing.T) {
+		digestA, indexA, valueA, _, _, _ := instances[0].GenProofsTreeFake(txnLimit)
+		digestB, indexB, valueB, _, _, _ := instances[1].GenProofsTreeFake(txnLimit)
+		if !digestA.IsEqual(&digestB) {
+			t.Errorf("Digests differ between the runs")
+		}
+		for k := range indexA {
+			if indexA[k] != indexB[k] || !valueA[k].IsEqual(&valueB[k]) {
+				t.Errorf("Fake proofs differ at %d", k)
+			}
+		}
+	})
+
+	t.Run("GenerateVectorFrom", func(t *testing.T) {
+		a, errA := GenerateVectorFrom(16, NewSeededReader(seed))
+		b, errB := GenerateVectorFrom(16, NewSeededReader(seed))
+		c, errC := GenerateVectorFrom(16, NewSeededReader([]byte("another seed")))
+		if errA != nil || errB != nil || errC != nil {
+			t.Fatal(errA, errB, errC)
+		}
+		for i := range a {
+			if !a[i].IsEqual(&b[i]) {
+				t.Errorf("Vectors differ at %d", i)
+			}
+		}
+		if a[0].IsEqual(&c[0]) {
+			t.Errorf("Different seeds gave the same vector")
+		}
+	})
+
+	t.Run(fmt.Sprintf("%d/ShortSource;", L), func(t *testing.T) {
+		vcs := VCS{}
+		vcs.SetRandomSource(io.LimitReader(NewSeededReader(seed), 8))
+		if err := vcs.KeyGenInMemory(16, L, txnLimit); !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Errorf("Expected io.ErrUnexpectedEOF, got %v", err)
+		}
+		if _, err := GenerateVectorFrom(16, io.LimitReader(NewSeededReader(seed), 8)); !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Errorf("Expected io.ErrUnexpectedEOF, got %v", err)
+		}
+	})
+}
+
+// Source of the fixtures of a test, seeded with its name. Thus, a failing test can be rerun with the same values.
+func testReader(t testing.TB) io.Reader {
+	return NewSeededReader([]byte(t.Name()))
+}
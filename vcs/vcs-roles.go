@@ -0,0 +1,415 @@
+// Keys of the three roles, so that each loads only what it uses.
+// ProverKey: UPK tree and the aggregation prover keys. Commits, opens, keeps the proof tree up to date and aggregates.
+// UpdateKey: UPK paths of a set of indices. Updates digests and the proofs of those indices, as in vcs-pruned.go.
+// VerifierKey: G, H, VRK, VRKSubOneRev and, optionally, the aggregation verifier keys. Without them, it is a few kilobytes.
+// Layout of each: magic, version (u32), ell (u64), TxnLimit (u64), then the points as described at Write. Integers are little endian.
+package vcs
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/alinush/go-mcl"
+	"github.com/hyperproofs/gipa-go/cm"
+	"github.com/hyperproofs/gipa-go/utils"
+	"github.com/hyperproofs/kzg-go/kzg"
+)
+
+const PROVER_KEY_MAGIC = "HYPERPRV"
+const UPDATE_KEY_MAGIC = "HYPERUPD"
+const VERIFIER_KEY_MAGIC = "HYPERVER"
+const ROLE_KEY_VERSION = 1
+
+type ProverKey struct {
+	L            uint8
+	TxnLimit     uint64
+	G            mcl.G1
+	H            mcl.G2
+	VRK          []mcl.G2 // AggProve takes VRK and VRKSubOneRev for the B vector
+	VRKSubOneRev []mcl.G2
+	UPK          [][]mcl.G1 // Same as VCS.UPK
+	CK           cm.Ck      // MN keys, MN being the power of 2 nearest to L * TxnLimit
+	KZG1         kzg.KZG1Settings
+	KZG2         kzg.KZG2Settings
+}
+
+type UpdateKey struct {
+	L     uint8
+	G     mcl.G1
+	Paths map[uint64][]mcl.G1 // UPK path of each index, as returned by GetUpk. Same as upk_db in vcs-pruned.go
+}
+
+type VerifierKey struct {
+	L            uint8
+	TxnLimit     uint64 // 0 if aggregated proofs are not verified
+	G            mcl.G1
+	H            mcl.G2
+	VRK          []mcl.G2
+	VRKSubOneRev []mcl.G2
+	W            []mcl.G1 // W of the GIPA commitment key, MN points. Empty when TxnLimit is 0
+	KZG1VK       []mcl.G2 // Two points each
+	KZG2VK       []mcl.G1
+}
+
+// Keys of the prover. The aggregation keys must be loaded, e.g., with KeyGenLoad.
+// Returns ErrNotInMemory when the UPK tree is mapped instead of loaded, i.e., PARAM_TOO_LARGE. A copy would not fit in memory either.
+func (vcs *VCS) ProverKey() (*ProverKey, error) {
+
+	if vcs.UPK == nil {
+		return nil, newError(ErrNotInMemory, "ProverKey: The UPK tree for ell = %d is not in memory", vcs.L)
+	}
+	pk := ProverKey{L: vcs.L, TxnLimit: vcs.TxnLimit, G: vcs.G, H: vcs.H,
+		VRK: vcs.VRK, VRKSubOneRev: vcs.VRKSubOneRev, UPK: vcs.UPK, CK: vcs.ck, KZG1: vcs.kzg1, KZG2: vcs.kzg2}
+	return &pk, nil
+}
+
+// Keys to update the digest and the proofs when any of indices changes.
+func (vcs *VCS) UpdateKey(indices []uint64) *UpdateKey {
+	uk := UpdateKey{L: vcs.L, G: vcs.G, Paths: make(map[uint64][]mcl.G1, len(indices))}
+	for _, index := range indices {
+		uk.Paths[index] = vcs.GetUpk(index)
+	}
+	return &uk
+}
+
+// Keys of the verifier. With aggregation, the aggregation keys must be loaded and W takes most of the space.
+func (vcs *VCS) VerifierKey(aggregation bool) *VerifierKey {
+	vk := VerifierKey{L: vcs.L, G: vcs.G, H: vcs.H, VRK: vcs.VRK, VRKSubOneRev: vcs.VRKSubOneRev}
+	if aggregation {
+		vk.TxnLimit = vcs.TxnLimit
+		vk.W = vcs.ck.W
+		vk.KZG1VK = vcs.kzg1.VK
+		vk.KZG2VK = vcs.kzg2.VK
+	}
+	return &vk
+}
+
+// Instance that only holds the keys of the prover. Nothing is read from or written to disk.
+// Returns ErrBadEll or ErrBlockTooLarge for a bad header, as Init.
+func NewProver(pk *ProverKey) (*VCS, error) {
+	vcs, err := newRoleVCS(pk.L, pk.TxnLimit, pk.G, pk.H, pk.VRK, pk.VRKSubOneRev)
+	if err != nil {
+		return nil, err
+	}
+	vcs.UPK = pk.UPK
+	vcs.ck = pk.CK
+	vcs.kzg1 = pk.KZG1
+	vcs.kzg2 = pk.KZG2
+	return vcs, nil
+}
+
+// Instance that only holds the keys of the verifier, i.e., for Verify, VerifyMemoized, AggVerify and VerifyUPKBatch.
+func NewVerifier(vk *VerifierKey) (*VCS, error) {
+	vcs, err := newRoleVCS(vk.L, vk.TxnLimit, vk.G, vk.H, vk.VRK, vk.VRKSubOneRev)
+	if err != nil {
+		return nil, err
+	}
+	if vk.TxnLimit == 0 {
+		return vcs, nil
+	}
+	// The aggregated proofs only read the first KZG prover key, i.e., the generator. See newAggVerifier.
+	vcs.ck = cm.Ck{M: vcs.MN, W: vk.W}
+	vcs.kzg1 = kzg.KZG1Settings{PK: []mcl.G1{vk.G}, VK: vk.KZG1VK}
+	vcs.kzg2 = kzg.KZG2Settings{PK: []mcl.G2{vk.H}, VK: vk.KZG2VK}
+	return vcs, nil
+}
+
+func newRoleVCS(L uint8, txnLimit uint64, G mcl.G1, H mcl.G2, VRK []mcl.G2, VRKSubOneRev []mcl.G2) (*VCS, error) {
+
+	vcs := NewVCS(Config{})
+	vcs.Store = NewMemKeyStore()
+	if err := vcs.Init(L, "", txnLimit); err != nil {
+		return nil, err
+	}
+	if len(VRK) != int(L) || len(VRKSubOneRev) != int(L) {
+		return nil, newError(ErrBadEll, "Keys: Expected %d VRK points, got %d and %d", L, len(VRK), len(VRKSubOneRev))
+	}
+	vcs.G = G
+	vcs.H = H
+	copy(vcs.VRK, VRK)
+	copy(vcs.VRKSubOneRev, VRKSubOneRev)
+	for i := range vcs.VRK {
+		mcl.G2Sub(&vcs.VRKSubOne[i], &vcs.H, &vcs.VRK[i])
+	}
+	if txnLimit != 0 {
+		vcs.setAggSizes()
+	}
+	return vcs, nil
+}
+
+// digest after adding delta[t] to the value at updateindex[t]. Returns ErrBadIndex if a path is missing.
+func (uk *UpdateKey) UpdateComVec(digest mcl.G1, updateindex []uint64, delta []mcl.Fr) (mcl.G1, error) {
+
+	upks := make([]mcl.G1, len(updateindex))
+	for i, index := range updateindex {
+		upk, ok := uk.Paths[index]
+		if !ok {
+			return digest, newError(ErrBadIndex, "UpdateKey: No UPK path for index %d", index)
+		}
+		upks[i] = upk[uk.L-1]
+	}
+	var result, temp mcl.G1
+	mcl.G1MulVec(&temp, upks, delta)
+	mcl.G1Add(&result, &digest, &temp)
+	return result, nil
+}
+
+// Same as VCS.UpdateProof. Only the path of updateindex is needed.
+func (uk *UpdateKey) UpdateProof(proof []mcl.G1, localindex uint64, updateindex uint64, delta mcl.Fr) ([]mcl.G1, error) {
+
+	upk, ok := uk.Paths[updateindex]
+	if !ok {
+		return nil, newError(ErrBadIndex, "UpdateKey: No UPK path for index %d", updateindex)
+	}
+	if len(proof) != int(uk.L) {
+		return nil, newError(ErrBadProofLength, "UpdateKey: Proof has %d points, expected %d", len(proof), uk.L)
+	}
+	return updateProof(&uk.G, upk, uk.L, proof, localindex, updateindex, delta), nil
+}
+
+// Check the paths against the verifier key, e.g., after ReadUpdateKey from an untrusted source.
+func (uk *UpdateKey) Verify(vk *VerifierKey) bool {
+
+	verifier, err := NewVerifier(&VerifierKey{L: vk.L, G: vk.G, H: vk.H, VRK: vk.VRK, VRKSubOneRev: vk.VRKSubOneRev})
+	if err != nil || uk.L != vk.L || !uk.G.IsEqual(&vk.G) {
+		return false
+	}
+	indices, paths := make([]uint64, 0, len(uk.Paths)), make([][]mcl.G1, 0, len(uk.Paths))
+	for index, path := range uk.Paths {
+		indices = append(indices, index)
+		paths = append(paths, path)
+	}
+	status, _ := verifier.VerifyUPKBatch(indices, paths)
+	return status
+}
+
+func writeRoleHeader(w *keyFileWriter, magic string, L uint8, txnLimit uint64) {
+	header := make([]byte, len(magic)+20)
+	copy(header, magic)
+	binary.LittleEndian.PutUint32(header[len(magic):], ROLE_KEY_VERSION)
+	binary.LittleEndian.PutUint64(header[len(magic)+4:], uint64(L))
+	binary.LittleEndian.PutUint64(header[len(magic)+12:], txnLimit)
+	w.write(header)
+}
+
+// ell and TxnLimit of the key. Returns ErrBadEll or ErrBlockTooLarge, as Init, and ErrKeyFolderCorrupt for a bad header.
+func readRoleHeader(r *keyFileReader, magic string) (uint8, uint64, error) {
+
+	header := r.read(len(magic) + 20)
+	if r.err != nil || string(header[:len(magic)]) != magic {
+		return 0, 0, newError(ErrKeyFolderCorrupt, "%s: Bad header", magic)
+	}
+	if version := binary.LittleEndian.Uint32(header[len(magic):]); version > ROLE_KEY_VERSION {
+		return 0, 0, newError(ErrKeyFolderCorrupt, "%s: Unsupported version %d. Supports up to %d", magic, version, ROLE_KEY_VERSION)
+	}
+	L := binary.LittleEndian.Uint64(header[len(magic)+4:])
+	txnLimit := binary.LittleEndian.Uint64(header[len(magic)+12:])
+	if L == 0 || L >= 32 {
+		return 0, 0, newError(ErrBadEll, "%s: Either ell is 0 or >= 32, got %d", magic, L)
+	}
+	if txnLimit > MaxTxnLimit(uint8(L)) {
+		return 0, 0, newError(ErrBlockTooLarge, "%s: At most %d updates for ell = %d, got %d", magic, MaxTxnLimit(uint8(L)), L, txnLimit)
+	}
+	return uint8(L), txnLimit, nil
+}
+
+// Points of the power of 2 nearest to L * txnLimit.
+func aggSize(L uint8, txnLimit uint64) uint64 {
+	return utils.NextPowOf2(uint64(L) * txnLimit)
+}
+
+// After the header: G, H, then VRK[i] and VRKSubOneRev[i] for each i, the UPK tree in the order of the key files,
+// CK as in CKNAME, and the KZG keys as in KZGNAME, each without its size.
+func (pk *ProverKey) Write(w io.Writer) error {
+
+	bw := bufio.NewWriter(w)
+	kw := keyFileWriter{w: bw}
+	writeRoleHeader(&kw, PROVER_KEY_MAGIC, pk.L, pk.TxnLimit)
+	kw.write(pk.G.Serialize())
+	kw.write(pk.H.Serialize())
+	for i := range pk.VRK {
+		kw.write(pk.VRK[i].Serialize())
+		kw.write(pk.VRKSubOneRev[i].Serialize())
+	}
+	for l := range pk.UPK {
+		for k := range pk.UPK[l] {
+			kw.write(pk.UPK[l][k].Serialize())
+		}
+	}
+	for i := range pk.CK.W {
+		kw.write(pk.CK.W[i].Serialize())
+		kw.write(pk.CK.V[i].Serialize())
+	}
+	for i := range pk.KZG1.VK {
+		kw.write(pk.KZG1.VK[i].Serialize())
+		kw.write(pk.KZG2.VK[i].Serialize())
+	}
+	for i := range pk.KZG1.PK {
+		kw.write(pk.KZG1.PK[i].Serialize())
+		kw.write(pk.KZG2.PK[i].Serialize())
+	}
+	if kw.err != nil {
+		return kw.err
+	}
+	return bw.Flush()
+}
+
+func ReadProverKey(r io.Reader) (*ProverKey, error) {
+
+	kr := keyFileReader{r: bufio.NewReader(r)}
+	L, txnLimit, err := readRoleHeader(&kr, PROVER_KEY_MAGIC)
+	if err != nil {
+		return nil, err
+	}
+	g1, g2 := GetG1ByteSize(), GetG2ByteSize()
+	pk := ProverKey{L: L, TxnLimit: txnLimit, VRK: make([]mcl.G2, L), VRKSubOneRev: make([]mcl.G2, L)}
+	kr.deserialize(&pk.G, g1)
+	kr.deserialize(&pk.H, g2)
+	for i := range pk.VRK {
+		kr.deserialize(&pk.VRK[i], g2)
+		kr.deserialize(&pk.VRKSubOneRev[i], g2)
+	}
+	// A level is allocated once the levels above it are read, thus at most as many points as read so far.
+	// A forged ell fails at the end of the input instead of allocating the whole tree.
+	pk.UPK = make([][]mcl.G1, L+1)
+	for l := 0; l < len(pk.UPK) && kr.err == nil; l++ {
+		pk.UPK[l] = make([]mcl.G1, 1<<l)
+		for k := range pk.UPK[l] {
+			kr.deserialize(&pk.UPK[l][k], g1)
+		}
+	}
+	if kr.err != nil {
+		return nil, corrupt(PROVER_KEY_MAGIC, kr.err)
+	}
+
+	// Bounded by MAX_AGG_SIZE, see readRoleHeader
+
+	mn := aggSize(L, txnLimit)
+	pk.CK = cm.Ck{M: mn, V: make([]mcl.G2, mn), W: make([]mcl.G1, mn)}
+	for i := uint64(0); i < mn; i++ {
+		kr.deserialize(&pk.CK.W[i], g1)
+		kr.deserialize(&pk.CK.V[i], g2)
+	}
+	pk.KZG1 = kzg.KZG1Settings{PK: make([]mcl.G1, 2*mn-1), VK: make([]mcl.G2, 2)}
+	pk.KZG2 = kzg.KZG2Settings{PK: make([]mcl.G2, 2*mn-1), VK: make([]mcl.G1, 2)}
+	for i := range pk.KZG1.VK {
+		kr.deserialize(&pk.KZG1.VK[i], g2)
+		kr.deserialize(&pk.KZG2.VK[i], g1)
+	}
+	for i := range pk.KZG1.PK {
+		kr.deserialize(&pk.KZG1.PK[i], g1)
+		kr.deserialize(&pk.KZG2.PK[i], g2)
+	}
+	if kr.err != nil {
+		return nil, corrupt(PROVER_KEY_MAGIC, kr.err)
+	}
+	return &pk, nil
+}
+
+// After the header, whose TxnLimit is 0: G, then the paths as a UPK bundle. See vcs-upk-bundle.go
+func (uk *UpdateKey) Write(w io.Writer) error {
+
+	bw := bufio.NewWriter(w)
+	kw := keyFileWriter{w: bw}
+	writeRoleHeader(&kw, UPDATE_KEY_MAGIC, uk.L, 0)
+	kw.write(uk.G.Serialize())
+	if kw.err != nil {
+		return kw.err
+	}
+	indices := make([]uint64, 0, len(uk.Paths))
+	for index := range uk.Paths {
+		indices = append(indices, index)
+	}
+	if err := WriteUpkBundle(bw, uk.L, indices, uk.Paths); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// The paths are decoded, but not checked. See UpdateKey.Verify.
+func ReadUpdateKey(r io.Reader) (*UpdateKey, error) {
+
+	br := bufio.NewReader(r)
+	kr := keyFileReader{r: br}
+	L, _, err := readRoleHeader(&kr, UPDATE_KEY_MAGIC)
+	if err != nil {
+		return nil, err
+	}
+	uk := UpdateKey{L: L}
+	kr.deserialize(&uk.G, GetG1ByteSize())
+	if kr.err != nil {
+		return nil, corrupt(UPDATE_KEY_MAGIC, kr.err)
+	}
+	indices, nodes, values, err := readUpkBundle(br, L)
+	if err != nil {
+		return nil, corrupt(UPDATE_KEY_MAGIC, err)
+	}
+	uk.Paths = upkDb(L, indices, nodes, values)
+	return &uk, nil
+}
+
+// After the header: G, H, then VRK[i] and VRKSubOneRev[i] for each i.
+// Unless TxnLimit is 0, then W, and KZG1VK[i] and KZG2VK[i] for i = 0, 1.
+func (vk *VerifierKey) Write(w io.Writer) error {
+
+	if vk.TxnLimit != 0 && (uint64(len(vk.W)) != aggSize(vk.L, vk.TxnLimit) || len(vk.KZG1VK) != 2 || len(vk.KZG2VK) != 2) {
+		return fmt.Errorf("VerifierKey: Aggregation keys do not match %d updates for ell = %d", vk.TxnLimit, vk.L)
+	}
+	bw := bufio.NewWriter(w)
+	kw := keyFileWriter{w: bw}
+	writeRoleHeader(&kw, VERIFIER_KEY_MAGIC, vk.L, vk.TxnLimit)
+	kw.write(vk.G.Serialize())
+	kw.write(vk.H.Serialize())
+	for i := range vk.VRK {
+		kw.write(vk.VRK[i].Serialize())
+		kw.write(vk.VRKSubOneRev[i].Serialize())
+	}
+	if vk.TxnLimit != 0 {
+		for i := range vk.W {
+			kw.write(vk.W[i].Serialize())
+		}
+		for i := range vk.KZG1VK {
+			kw.write(vk.KZG1VK[i].Serialize())
+			kw.write(vk.KZG2VK[i].Serialize())
+		}
+	}
+	if kw.err != nil {
+		return kw.err
+	}
+	return bw.Flush()
+}
+
+func ReadVerifierKey(r io.Reader) (*VerifierKey, error) {
+
+	kr := keyFileReader{r: bufio.NewReader(r)}
+	L, txnLimit, err := readRoleHeader(&kr, VERIFIER_KEY_MAGIC)
+	if err != nil {
+		return nil, err
+	}
+	g1, g2 := GetG1ByteSize(), GetG2ByteSize()
+	vk := VerifierKey{L: L, TxnLimit: txnLimit, VRK: make([]mcl.G2, L), VRKSubOneRev: make([]mcl.G2, L)}
+	kr.deserialize(&vk.G, g1)
+	kr.deserialize(&vk.H, g2)
+	for i := range vk.VRK {
+		kr.deserialize(&vk.VRK[i], g2)
+		kr.deserialize(&vk.VRKSubOneRev[i], g2)
+	}
+	if txnLimit != 0 {
+		vk.W = make([]mcl.G1, aggSize(L, txnLimit))
+		for i := range vk.W {
+			kr.deserialize(&vk.W[i], g1)
+		}
+		vk.KZG1VK = make([]mcl.G2, 2)
+		vk.KZG2VK = make([]mcl.G1, 2)
+		for i := range vk.KZG1VK {
+			kr.deserialize(&vk.KZG1VK[i], g2)
+			kr.deserialize(&vk.KZG2VK[i], g1)
+		}
+	}
+	if kr.err != nil {
+		return nil, corrupt(VERIFIER_KEY_MAGIC, kr.err)
+	}
+	return &vk, nil
+}
@@ -0,0 +1,145 @@
+package vcs
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/alinush/go-mcl"
+)
+
+// Each key goes through its serialization, and the instance built from it agrees with the full one.
+func TestRoleKeys(t *testing.T) {
+
+	mcl.InitFromString("bls12-381")
+
+	L := uint8(6)
+	txnLimit := uint64(4)
+	vcs, err := NewVCSInMemory(16, L, txnLimit)
+	check(err)
+	aFr := GenerateVector(vcs.N)
+	vcs.OpenAll(aFr)
+	digest := vcs.Commit(aFr, uint64(L))
+
+	indexVec := make([]uint64, txnLimit)
+	valueVec := make([]mcl.Fr, txnLimit)
+	proofVec := make([][]mcl.G1, txnLimit)
+	for k := range indexVec {
+		indexVec[k] = uint64(k) * 13 % vcs.N
+		valueVec[k] = aFr[indexVec[k]]
+		proofVec[k] = vcs.GetProofPath(indexVec[k])
+	}
+
+	t.Run(fmt.Sprintf("%d/VerifierKey;%d", L, txnLimit), func(t *testing.T) {
+		var buf bytes.Buffer
+		check(vcs.VerifierKey(false).Write(&buf))
+		if size := buf.Len(); size > 8*1024 {
+			t.Errorf("Verifier key without aggregation takes %d bytes", size)
+		}
+		var aggBuf bytes.Buffer
+		check(vcs.VerifierKey(true).Write(&aggBuf))
+
+		vk, err := ReadVerifierKey(&buf)
+		check(err)
+		if vk.TxnLimit != 0 || !vk.H.IsEqual(&vcs.H) || !vk.VRK[L-1].IsEqual(&vcs.VRK[L-1]) {
+			t.Fatalf("Verifier key mismatch")
+		}
+		verifier, err := NewVerifier(vk)
+		check(err)
+		if status, _ := verifier.VerifyMemoized(digest, indexVec, valueVec, proofVec); !status {
+			t.Errorf("Proofs failed to verify")
+		}
+		if verifier.Verify(digest, indexVec[0]^1, valueVec[0], proofVec[0]) {
+			t.Errorf("Proof verified for the wrong index")
+		}
+
+		vk, err = ReadVerifierKey(&aggBuf)
+		check(err)
+		verifier, err = NewVerifier(vk)
+		check(err)
+		aggProof, err := vcs.AggProve(indexVec, proofVec)
+		check(err)
+		if status, err := verifier.AggVerify(aggProof, digest, indexVec, valueVec); !status || err != nil {
+			t.Errorf("Aggregated proof failed to verify: %v", err)
+		}
+	})
+
+	t.Run(fmt.Sprintf("%d/ProverKey;%d", L, txnLimit), func(t *testing.T) {
+		var buf bytes.Buffer
+		pk, err := vcs.ProverKey()
+		check(err)
+		check(pk.Write(&buf))
+		pk, err = ReadProverKey(&buf)
+		check(err)
+		prover, err := NewProver(pk)
+		check(err)
+
+		expected := prover.Commit(aFr, uint64(L))
+		if !digest.IsEqual(&expected) {
+			t.Errorf("Digest mismatch")
+		}
+		prover.OpenAll(aFr)
+		if !SliceIsEqual(prover.GetProofPath(indexVec[1]), proofVec[1]) {
+			t.Errorf("Proof mismatch")
+		}
+		aggProof, err := prover.AggProve(indexVec, proofVec)
+		check(err)
+		if status, err := vcs.AggVerify(aggProof, digest, indexVec, valueVec); !status || err != nil {
+			t.Errorf("Aggregated proof failed to verify: %v", err)
+		}
+	})
+
+	t.Run(fmt.Sprintf("%d/UpdateKey;%d", L, txnLimit), func(t *testing.T) {
+		var buf bytes.Buffer
+		check(vcs.UpdateKey(indexVec).Write(&buf))
+		uk, err := ReadUpdateKey(&buf)
+		check(err)
+		if !uk.Verify(vcs.VerifierKey(false)) {
+			t.Fatalf("Update key failed to verify")
+		}
+
+		deltaVec := GenerateVector(txnLimit)
+		updated, err := uk.UpdateComVec(digest, indexVec, deltaVec)
+		check(err)
+		expected, err := vcs.UpdateComVec(digest, indexVec, deltaVec)
+		check(err)
+		if !updated.IsEqual(&expected) {
+			t.Errorf("Digest mismatch")
+		}
+		proof, err := uk.UpdateProof(proofVec[0], indexVec[0], indexVec[1], deltaVec[1])
+		check(err)
+		expectedProof, err := vcs.UpdateProof(proofVec[0], indexVec[0], indexVec[1], deltaVec[1])
+		check(err)
+		if !SliceIsEqual(proof, expectedProof) {
+			t.Errorf("Proof mismatch")
+		}
+		if _, err := uk.UpdateComVec(digest, []uint64{indexVec[0] ^ 1}, deltaVec[:1]); !errors.Is(err, ErrBadIndex) {
+			t.Errorf("Expected ErrBadIndex, got %v", err)
+		}
+
+		uk.Paths[indexVec[0]][L-1] = uk.G
+		if uk.Verify(vcs.VerifierKey(false)) {
+			t.Errorf("Tampered update key verified")
+		}
+	})
+
+	t.Run(fmt.Sprintf("%d/BadHeader;%d", L, txnLimit), func(t *testing.T) {
+		var buf bytes.Buffer
+		check(vcs.VerifierKey(false).Write(&buf))
+		if _, err := ReadProverKey(bytes.NewReader(buf.Bytes())); !errors.Is(err, ErrKeyFolderCorrupt) {
+			t.Errorf("Expected ErrKeyFolderCorrupt, got %v", err)
+		}
+		if _, err := ReadVerifierKey(bytes.NewReader(buf.Bytes()[:buf.Len()-1])); !errors.Is(err, ErrKeyFolderCorrupt) {
+			t.Errorf("Expected ErrKeyFolderCorrupt, got %v", err)
+		}
+
+		// ell = 31 in the header, but no points
+		var forged bytes.Buffer
+		kw := keyFileWriter{w: &forged}
+		writeRoleHeader(&kw, PROVER_KEY_MAGIC, 31, 1)
+		if _, err := ReadProverKey(&forged); !errors.Is(err, ErrKeyFolderCorrupt) {
+			t.Errorf("Expected ErrKeyFolderCorrupt, got %v", err)
+		}
+	})
+}
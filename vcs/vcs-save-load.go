@@ -1,289 +1,602 @@
 package vcs
 
 import (
+	"bufio"
+	"context"
 	"encoding/binary"
 	"fmt"
+	"io"
 	"math"
-	"os"
-	"path/filepath"
+	"runtime"
 	"sync"
 
 	"github.com/alinush/go-mcl"
+	"github.com/hyperproofs/gipa-go/cm"
+	"github.com/hyperproofs/kzg-go/kzg"
 )
 
-func (vcs *VCS) SaveTrapdoor() {
+// Writes the fields of a key file in order. The first error is kept, as in bufio.Writer.
+type keyFileWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (k *keyFileWriter) write(data []byte) {
+	if k.err == nil {
+		_, k.err = k.w.Write(data)
+	}
+}
+
+// Close f, unless writing failed, and report the first error.
+func (k *keyFileWriter) close(f io.Closer) error {
+	err := f.Close()
+	if k.err != nil {
+		return k.err
+	}
+	return err
+}
+
+// Reads the fields of a key file in order. The first error, including a point that does not decode, is kept.
+type keyFileReader struct {
+	r   io.Reader
+	err error
+}
 
-	fmt.Println(SEP, "Saving data to:", vcs.folderPath, SEP)
+func (k *keyFileReader) read(n int) []byte {
+	data := make([]byte, n)
+	if k.err == nil {
+		_, k.err = io.ReadFull(k.r, data)
+	}
+	return data
+}
+
+func (k *keyFileReader) deserialize(p interface{ Deserialize([]byte) error }, n int) {
+	data := k.read(n)
+	if k.err == nil {
+		k.err = p.Deserialize(data)
+	}
+}
 
-	os.MkdirAll(vcs.folderPath, os.ModePerm)
-	f, err := os.Create(vcs.folderPath + TRAPDOORNAME)
-	check(err)
+func (vcs *VCS) SaveTrapdoor() error {
+
+	vcs.log(SEP, "Saving data to:", vcs.folderPath, SEP)
+
+	f, err := vcs.store.Create(vcs.Names.Trapdoor)
+	if err != nil {
+		return err
+	}
+	w := keyFileWriter{w: f}
 
 	// Report the size.
 	LBytes := make([]byte, 8) // Enough space for 64 bits of interger
 	binary.LittleEndian.PutUint64(LBytes, uint64(vcs.L))
-	_, err = f.Write(LBytes)
-	check(err)
+	w.write(LBytes)
 
 	// Write KZG stuff first, as it not related to VCS or size of the VCS.
-	_, err = f.Write(vcs.alpha.Serialize())
-	check(err)
-	_, err = f.Write(vcs.beta.Serialize())
-	check(err)
+	w.write(vcs.alpha.Serialize())
+	w.write(vcs.beta.Serialize())
 
 	// Write the Generator to the file
-	_, err = f.Write(vcs.G.Serialize())
-	check(err)
-	_, err = f.Write(vcs.H.Serialize())
-	check(err)
+	w.write(vcs.G.Serialize())
+	w.write(vcs.H.Serialize())
 
 	// Write the trapdoors to the file.
 	for i := range vcs.trapdoors {
-		_, err = f.Write(vcs.trapdoors[i].Serialize())
-		check(err)
-		_, err = f.Write(vcs.trapdoorsSubOne[i].Serialize())
-		check(err)
-		_, err = f.Write(vcs.trapdoorsSubOneRev[i].Serialize())
-		check(err)
+		w.write(vcs.trapdoors[i].Serialize())
+		w.write(vcs.trapdoorsSubOne[i].Serialize())
+		w.write(vcs.trapdoorsSubOneRev[i].Serialize())
 	}
 
-	f.Close()
-	fmt.Println(SEP, "Saved trapdoors", SEP)
+	if err := w.close(f); err != nil {
+		return err
+	}
+	vcs.log(SEP, "Saved trapdoors", SEP)
 
-	// Create a new file for VRK and write it.
-	f, err = os.Create(vcs.folderPath + VRKNAME)
-	check(err)
+	return vcs.SaveVrk()
+}
+
+// Save VRK, VRKSubOne and VRKSubOneRev.
+func (vcs *VCS) SaveVrk() error {
+
+	f, err := vcs.store.Create(vcs.Names.VRK)
+	if err != nil {
+		return err
+	}
+	w := keyFileWriter{w: f}
 
 	for i := range vcs.VRK {
-		_, err = f.Write(vcs.VRK[i].Serialize())
-		check(err)
-		_, err = f.Write(vcs.VRKSubOne[i].Serialize())
-		check(err)
-		_, err = f.Write(vcs.VRKSubOneRev[i].Serialize())
-		check(err)
+		w.write(vcs.VRK[i].Serialize())
+		w.write(vcs.VRKSubOne[i].Serialize())
+		w.write(vcs.VRKSubOneRev[i].Serialize())
+	}
+	if err := w.close(f); err != nil {
+		return err
+	}
+	vcs.log(SEP, "Saved VRK", SEP)
+	return nil
+}
+
+// Save the public parameters (ell and the generators).
+// Unlike SaveTrapdoor, this file can be handed out to anyone.
+func (vcs *VCS) SavePublicParams() error {
+
+	f, err := vcs.store.Create(vcs.Names.PP)
+	if err != nil {
+		return err
 	}
-	f.Close()
-	fmt.Println(SEP, "Saved VRK", SEP)
+	w := keyFileWriter{w: f}
 
+	LBytes := make([]byte, 8)
+	binary.LittleEndian.PutUint64(LBytes, uint64(vcs.L))
+	w.write(LBytes)
+
+	w.write(vcs.G.Serialize())
+	w.write(vcs.H.Serialize())
+
+	if err := w.close(f); err != nil {
+		return err
+	}
+	vcs.log(SEP, "Saved public parameters", SEP)
+	return nil
+}
+
+// ell of the folder, i.e., the header of the trapdoors and the public parameters. It must be at least L.
+func (vcs *VCS) readEll(r *keyFileReader, fileName string, L uint8) error {
+
+	reportedEll := binary.LittleEndian.Uint64(r.read(8))
+	if r.err != nil {
+		return corrupt(vcs.folderPath+fileName, r.err)
+	}
+	if reportedEll < uint64(L) || reportedEll >= 32 {
+		// Assumes SaveTrapdoor is honest
+		return newError(ErrBadEll, "%s: There is not enough to read! Found: %d, Wants: %d", vcs.folderPath+fileName, reportedEll, L)
+	}
+	vcs.folderL = uint8(reportedEll)
+	return nil
 }
 
-func (vcs *VCS) LoadTrapdoor(L uint8) {
+func (vcs *VCS) LoadTrapdoor(L uint8) error {
 
-	f, err := os.Open(vcs.folderPath + TRAPDOORNAME)
-	check(err)
+	f, err := vcs.store.Open(vcs.Names.Trapdoor)
+	if err != nil {
+		return corrupt(vcs.folderPath+vcs.Names.Trapdoor, err)
+	}
+	defer f.Close()
 
 	// fileinfo, err := f.Stat()
 	// check(err)
 	// filesize := fileinfo.Size() // In Bytes
 	// estimatedEll := (filesize - int64(GetG1ByteSize()+GetG2ByteSize())) / int64(GetFrByteSize()) / 2
 
-	var data []byte
-
-	data = make([]byte, 8)
-	_, err = f.Read(data)
-	check(err)
-	reportedEll := uint8(binary.LittleEndian.Uint64(data))
-
-	if reportedEll < L {
-		// Assumes SaveTrapdoor is honest
-		panic(fmt.Sprintf("There is not enough to read! Found: %d, Wants: %d", reportedEll, L))
+	r := keyFileReader{r: bufio.NewReader(f)}
+	if err := vcs.readEll(&r, vcs.Names.Trapdoor, L); err != nil {
+		return err
 	}
 
 	// Load KZG related stuff
-	data = make([]byte, GetFrByteSize())
-	_, err = f.Read(data)
-	check(err)
-	vcs.alpha.Deserialize(data)
-
-	data = make([]byte, GetFrByteSize())
-	_, err = f.Read(data)
-	check(err)
-	vcs.beta.Deserialize(data)
+	r.deserialize(&vcs.alpha, GetFrByteSize())
+	r.deserialize(&vcs.beta, GetFrByteSize())
 
 	// Load the VCS related stuff
-	data = make([]byte, GetG1ByteSize())
-	_, err = f.Read(data)
-	check(err)
-	vcs.G.Deserialize(data)
-
-	data = make([]byte, GetG2ByteSize())
-	_, err = f.Read(data)
-	check(err)
-	vcs.H.Deserialize(data)
+	r.deserialize(&vcs.G, GetG1ByteSize())
+	r.deserialize(&vcs.H, GetG2ByteSize())
 
 	vcs.L = uint8(L)
 
-	fmt.Println("Loading trapdoors:", L)
+	vcs.log("Loading trapdoors:", L)
 	for i := uint8(0); i < L; i++ {
+		r.deserialize(&vcs.trapdoors[i], GetFrByteSize())
+		r.deserialize(&vcs.trapdoorsSubOne[i], GetFrByteSize())
+		r.deserialize(&vcs.trapdoorsSubOneRev[i], GetFrByteSize())
+	}
+	if r.err != nil {
+		return corrupt(vcs.folderPath+vcs.Names.Trapdoor, r.err)
+	}
+	vcs.hasTrapdoors = true
 
-		data = make([]byte, GetFrByteSize())
-		_, err = f.Read(data)
-		check(err)
-		vcs.trapdoors[i].Deserialize(data)
+	return vcs.LoadVrk(L)
+}
 
-		data = make([]byte, GetFrByteSize())
-		_, err = f.Read(data)
-		check(err)
-		vcs.trapdoorsSubOne[i].Deserialize(data)
+// Load the generators from the public parameter file and then the VRKs.
+// Trapdoors are left untouched, hence only the public API of VCS can be used.
+func (vcs *VCS) LoadPublicParams(L uint8) error {
 
-		data = make([]byte, GetFrByteSize())
-		_, err = f.Read(data)
-		check(err)
-		vcs.trapdoorsSubOneRev[i].Deserialize(data)
+	f, err := vcs.store.Open(vcs.Names.PP)
+	if err != nil {
+		return corrupt(vcs.folderPath+vcs.Names.PP, err)
 	}
+	defer f.Close()
 
-	f.Close()
+	r := keyFileReader{r: bufio.NewReader(f)}
+	if err := vcs.readEll(&r, vcs.Names.PP, L); err != nil {
+		return err
+	}
+	r.deserialize(&vcs.G, GetG1ByteSize())
+	r.deserialize(&vcs.H, GetG2ByteSize())
+	if r.err != nil {
+		return corrupt(vcs.folderPath+vcs.Names.PP, r.err)
+	}
 
-	// Load VRKs
-	f, err = os.Open(vcs.folderPath + VRKNAME)
-	check(err)
+	vcs.L = uint8(L)
+	return vcs.LoadVrk(L)
+}
 
-	data = make([]byte, GetG2ByteSize())
-	for i := uint8(0); i < L; i++ {
-		_, err = f.Read(data)
-		check(err)
-		vcs.VRK[i].Deserialize(data)
+func (vcs *VCS) LoadVrk(L uint8) error {
 
-		_, err = f.Read(data)
-		check(err)
-		vcs.VRKSubOne[i].Deserialize(data)
+	f, err := vcs.store.Open(vcs.Names.VRK)
+	if err != nil {
+		return corrupt(vcs.folderPath+vcs.Names.VRK, err)
+	}
+	defer f.Close()
 
-		_, err = f.Read(data)
-		check(err)
-		vcs.VRKSubOneRev[i].Deserialize(data)
+	r := keyFileReader{r: bufio.NewReader(f)}
+	for i := uint8(0); i < L; i++ {
+		r.deserialize(&vcs.VRK[i], GetG2ByteSize())
+		r.deserialize(&vcs.VRKSubOne[i], GetG2ByteSize())
+		r.deserialize(&vcs.VRKSubOneRev[i], GetG2ByteSize())
 	}
-	f.Close()
+	if r.err != nil {
+		return corrupt(vcs.folderPath+vcs.Names.VRK, r.err)
+	}
+	return nil
 }
 
-func (vcs *VCS) UpkLoad(fileName string, index uint8, start uint64, stop uint64, wg *sync.WaitGroup) {
-	f, err := os.Open(fileName)
-	check(err)
-
-	data := make([]byte, GetG1ByteSize())
+// Reads one point at a time. UpkLoadDriver uses loadShards instead, this is kept as the baseline for BenchmarkKeyLoad.
+// fileName is the name of the shard in the key store.
+func (vcs *VCS) UpkLoad(fileName string, index uint8, start uint64, stop uint64, wg *sync.WaitGroup) error {
+	defer wg.Done()
+	f, err := vcs.store.Open(fileName)
+	if err != nil {
+		return corrupt(vcs.folderPath+fileName, err)
+	}
+	defer f.Close()
 
-	var result mcl.G1
-	for j := start; j < stop; j++ {
+	r := keyFileReader{r: f}
+	for j := start; j < stop && r.err == nil; j++ {
 		i, k := IndexInTheLevel(j)
-		_, err = f.Read(data)
-		check(err)
-		result.Deserialize(data)
-
-		vcs.UPK[i][k] = result
-
+		r.deserialize(&vcs.UPK[i][k], GetG1ByteSize())
 	}
-	fmt.Println("Read ", fileName, BoundsPrint(start, stop))
-	defer f.Close()
-	defer wg.Done()
+	if r.err != nil {
+		return corrupt(vcs.folderPath+fileName, r.err)
+	}
+	vcs.log("Read ", fileName, BoundsPrint(start, stop))
+	return nil
+}
+
+// Shards are located with the manifest of the folder. See Shards.
+func (vcs *VCS) UpkLoadDriver() error {
+	return vcs.UpkLoadDriverContext(context.Background())
 }
 
-func (vcs *VCS) UpkLoadDriver() {
+// Same as UpkLoadDriver, but stops once ctx is done and returns ctx.Err(). The partially loaded tree is dropped on error.
+func (vcs *VCS) UpkLoadDriverContext(ctx context.Context) error {
+
+	shards, err := vcs.UpkShards()
+	if err != nil {
+		return err
+	}
 
 	// Allocate space for UPK
 	vcs.MallocUpk()
 
-	var wg sync.WaitGroup
-	var step, start, stop uint64
-	var total, totalBytes int64
-	var i uint8
+	err = vcs.loadShards(ctx, shards, func(j uint64, data []byte) error {
+		i, k := IndexInTheLevel(j)
+		return vcs.UPK[i][k].Deserialize(data)
+	})
+	if err != nil {
+		vcs.UPK = nil
+	}
+	return err
+}
 
-	var files []string
-	var err error
+func (vcs *VCS) UpkSave(fileName string, start uint64, stop uint64, wg *sync.WaitGroup) error {
+	defer wg.Done()
+	f, err := vcs.store.Create(fileName)
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(f)
+	w := keyFileWriter{w: bw}
 
-	files, err = filepath.Glob(vcs.folderPath + "/upk*")
-	check(err)
-	totalBytes = int64(0)
-	for i := range files {
-		totalBytes += fileSize(files[i])
+	for j := start; j < stop; j++ {
+		i, k := IndexInTheLevel(j)
+		w.write(vcs.UPK[i][k].Serialize())
 	}
-	total = totalBytes / int64(GetG1ByteSize())
-	step = uint64(math.Ceil(float64(total) / float64(NFILES)))
+	if w.err == nil {
+		w.err = bw.Flush()
+	}
+	if err := w.close(f); err != nil {
+		return err
+	}
+	vcs.log("Dumped ", fileName, BoundsPrint(start, stop))
+	return nil
+}
 
-	numUPK := (uint64(1) << (vcs.L + 1)) - 1
-	start = uint64(0)
-	stop = step
-	stop = minUint64(stop, numUPK)
+// Flush the in-memory UPK tree to the key store.
+// Files are laid out exactly as in UpkGenDriver so that UpkLoadDriver can read them back.
+func (vcs *VCS) UpkSaveDriver() error {
+
+	var wg sync.WaitGroup
+	errs := make([]error, vcs.nfiles)
+	numUPK := (uint64(1) << (vcs.L + 1)) - 1 // Number of nodes in the UPK tree
+	step := uint64(math.Ceil(float64(numUPK) / float64(vcs.nfiles)))
+	start := uint64(0)
+	stop := step
 
-	i = uint8(0)
-	for start < numUPK {
+	for i := uint8(0); i < vcs.nfiles; i++ {
 		wg.Add(1)
-		fileName := vcs.folderPath + fmt.Sprintf(UPKNAME, i)
-		go vcs.UpkLoad(fileName, i, start, stop, &wg)
-		// fmt.Println(i)
-		// fmt.Println("Reading chuck range:", i, BoundsPrint(start, stop))
+		fileName := fmt.Sprintf(vcs.Names.UPK, i)
+		go func(i uint8, start uint64, stop uint64) {
+			errs[i] = vcs.UpkSave(fileName, start, stop, &wg)
+		}(i, start, stop)
+
 		start += step
 		stop += step
 		stop = minUint64(stop, numUPK)
-		i++
+
+		if (i+1)%vcs.NCores == 0 {
+			wg.Wait()
+		}
 	}
 	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-func (vcs *VCS) PrkLoad(fileName string, index uint8, start uint64, stop uint64, wg *sync.WaitGroup) {
-
-	f, err := os.Open(fileName)
-	check(err)
-
-	var data []byte
-	data = make([]byte, GetG1ByteSize())
-
-	var result mcl.G1
-	for i := start; i < stop; i++ {
-		_, err = f.Read(data)
-		check(err)
-		result.Deserialize(data)
-		vcs.PRK[i] = result
+// Reads one point at a time. See UpkLoad.
+func (vcs *VCS) PrkLoad(fileName string, index uint8, start uint64, stop uint64, wg *sync.WaitGroup) error {
+	defer wg.Done()
+	f, err := vcs.store.Open(fileName)
+	if err != nil {
+		return corrupt(vcs.folderPath+fileName, err)
 	}
-
-	fmt.Println("Read ", fileName, BoundsPrint(start, stop))
 	defer f.Close()
-	defer wg.Done()
+
+	r := keyFileReader{r: f}
+	for i := start; i < stop && r.err == nil; i++ {
+		r.deserialize(&vcs.PRK[i], GetG1ByteSize())
+	}
+	if r.err != nil {
+		return corrupt(vcs.folderPath+fileName, r.err)
+	}
+	vcs.log("Read ", fileName, BoundsPrint(start, stop))
+	return nil
 }
 
-func (vcs *VCS) PrkLoadDriver() {
+func (vcs *VCS) PrkLoadDriver() error {
+	shards, err := vcs.PrkShards()
+	if err != nil {
+		return err
+	}
 	// Allocate space for PRK
 	vcs.PRK = make([]mcl.G1, vcs.N)
-	var wg sync.WaitGroup
-	var step, start, stop uint64
-	var total, totalBytes int64
-	var i uint8
+	return vcs.loadShards(context.Background(), shards, func(j uint64, data []byte) error {
+		return vcs.PRK[j].Deserialize(data)
+	})
+}
 
-	var files []string
-	var err error
+// Points of a shard that are read with a single ReadAt.
+type loadChunk struct {
+	name  string
+	file  io.ReaderAt
+	first uint64 // Position of the first point of the file
+	start uint64
+	stop  uint64
+}
 
-	files, err = filepath.Glob(vcs.folderPath + "/prk*")
-	check(err)
-	totalBytes = int64(0)
-	for i := range files {
-		totalBytes += fileSize(files[i])
+// Held for writing while the subgroup checks of mcl are off, see loadShards.
+var verifyOrderMu sync.RWMutex
+
+// Read the shards in chunks of LOAD_CHUNK points and decode them on vcs.NCores workers.
+// decode(j, data) is called once for the point at position j, from any of the workers.
+// With TrustedKeys, mcl skips the subgroup checks. This setting of mcl is global to the process: it is turned off under
+// verifyOrderMu and restored before returning. Other loads wait meanwhile, but G1 points deserialized elsewhere in the
+// process, e.g., by the caller, are not checked either until then.
+// Returns the first error, or ctx.Err() once ctx is done. The remaining chunks are skipped after an error.
+func (vcs *VCS) loadShards(ctx context.Context, shards []ManifestEntry, decode func(j uint64, data []byte) error) error {
+
+	if vcs.TrustedKeys {
+		verifyOrderMu.Lock()
+		defer verifyOrderMu.Unlock()
+		mcl.VerifyOrderG1(false)
+		defer mcl.VerifyOrderG1(true)
+	} else {
+		verifyOrderMu.RLock()
+		defer verifyOrderMu.RUnlock()
 	}
-	total = totalBytes / int64(GetG1ByteSize())
-	step = uint64(math.Ceil(float64(total) / float64(NFILES)))
 
-	upperBound := (uint64(1) << vcs.L)
+	var mu sync.Mutex
+	var firstErr error
+	fail := func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	failed := func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		if firstErr == nil {
+			firstErr = ctx.Err()
+		}
+		return firstErr != nil
+	}
 
-	i = uint8(0)
-	start = uint64(0)
-	stop = minUint64(step, upperBound)
-	for start < upperBound {
+	chunks := make(chan loadChunk, vcs.NCores)
+	var wg sync.WaitGroup
+	workers := int(vcs.NCores)
+	if workers == 0 {
+		workers = runtime.NumCPU()
+	}
+	for w := 0; w < workers; w++ {
 		wg.Add(1)
-		fileName := vcs.folderPath + fmt.Sprintf(PRKNAME, i)
-		go vcs.PrkLoad(fileName, i, start, stop, &wg)
+		go func() {
+			defer wg.Done()
+			size := uint64(GetG1ByteSize())
+			buf := make([]byte, LOAD_CHUNK*size)
+			for c := range chunks {
+				if failed() {
+					continue // Drain the channel
+				}
+				data := buf[:(c.stop-c.start)*size]
+				if _, err := c.file.ReadAt(data, int64((c.start-c.first)*size)); err != nil {
+					fail(corrupt(vcs.folderPath+c.name, err))
+					continue
+				}
+				for j := c.start; j < c.stop; j++ {
+					if err := decode(j, data[(j-c.start)*size:(j-c.start+1)*size]); err != nil {
+						fail(corrupt(vcs.folderPath+c.name, fmt.Errorf("Point %d: %v", j, err)))
+						break
+					}
+				}
+			}
+		}()
+	}
 
-		// fmt.Println(i, fmt.Sprintf("%05d %05d", start, stop))
-		start += step
-		stop += step
-		stop = minUint64(stop, upperBound)
-		i++
+	for _, shard := range shards {
+		if failed() {
+			break
+		}
+		f, err := vcs.store.Open(shard.Name)
+		if err != nil {
+			fail(corrupt(vcs.folderPath+shard.Name, err))
+			break
+		}
+		defer f.Close()
+		for start := shard.Start; start < shard.Stop; start += LOAD_CHUNK {
+			chunks <- loadChunk{shard.Name, f, shard.Start, start, minUint64(start+LOAD_CHUNK, shard.Stop)}
+		}
+		vcs.log("Reading ", vcs.folderPath+shard.Name, BoundsPrint(shard.Start, shard.Stop))
 	}
+	close(chunks)
 	wg.Wait()
+	return firstErr
 }
 
-func (vcs *VCS) PrkUpkLoad() {
-	fmt.Println(SEP)
-	vcs.UpkLoadDriver()
-	fmt.Println(SEP)
+func (vcs *VCS) PrkUpkLoad() error {
+	vcs.log(SEP)
+	if err := vcs.UpkLoadDriver(); err != nil {
+		return err
+	}
+	vcs.log(SEP)
 	if !vcs.DISCARD_PRK {
-		vcs.PrkLoadDriver()
-		fmt.Println(SEP)
+		if err := vcs.PrkLoadDriver(); err != nil {
+			return err
+		}
+		vcs.log(SEP)
+	}
+	return nil
+}
+
+// Save the GIPA commitment key and the KZG keys to CKNAME and KZGNAME.
+// Same format as cm.IPPSaveCmKzg, which only writes to a folder.
+func (vcs *VCS) SaveAggKeys(ck *cm.Ck, kzg1 *kzg.KZG1Settings, kzg2 *kzg.KZG2Settings) error {
+
+	degree := uint64(len(kzg1.PK))
+	if degree != 2*ck.M-1 {
+		return fmt.Errorf("SaveAggKeys: CK and KZG size mismatch, %d and %d", ck.M, degree)
+	}
+
+	f, err := vcs.store.Create(vcs.Names.CK)
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(f)
+	w := keyFileWriter{w: bw}
+	w.err = binary.Write(bw, binary.LittleEndian, ck.M)
+	for i := uint64(0); i < ck.M; i++ {
+		w.write(ck.W[i].Serialize())
+		w.write(ck.V[i].Serialize())
+	}
+	if w.err == nil {
+		w.err = bw.Flush()
+	}
+	if err := w.close(f); err != nil {
+		return err
+	}
+	vcs.log("Dumped ", vcs.folderPath+vcs.Names.CK)
+
+	f, err = vcs.store.Create(vcs.Names.KZG)
+	if err != nil {
+		return err
+	}
+	bw = bufio.NewWriter(f)
+	w = keyFileWriter{w: bw}
+	w.err = binary.Write(bw, binary.LittleEndian, degree)
+	for i := range kzg1.VK {
+		w.write(kzg1.VK[i].Serialize())
+		w.write(kzg2.VK[i].Serialize())
+	}
+	for i := uint64(0); i < degree; i++ {
+		w.write(kzg1.PK[i].Serialize())
+		w.write(kzg2.PK[i].Serialize())
+	}
+	if w.err == nil {
+		w.err = bw.Flush()
+	}
+	if err := w.close(f); err != nil {
+		return err
+	}
+	vcs.log("Dumped ", vcs.folderPath+vcs.Names.KZG)
+	return nil
+}
+
+// Load the first M keys of CKNAME and the first 2M - 1 of KZGNAME. Same as cm.IPPCMLoadCmKzg.
+func (vcs *VCS) LoadAggKeys(M uint64) (cm.Ck, kzg.KZG1Settings, kzg.KZG2Settings, error) {
+
+	var ck cm.Ck
+	var kzg1 kzg.KZG1Settings
+	var kzg2 kzg.KZG2Settings
+	g1 := GetG1ByteSize()
+	g2 := GetG2ByteSize()
+
+	f, err := vcs.store.Open(vcs.Names.CK)
+	if err != nil {
+		return ck, kzg1, kzg2, corrupt(vcs.folderPath+vcs.Names.CK, err)
+	}
+	defer f.Close()
+	r := keyFileReader{r: bufio.NewReader(f)}
+	m := binary.LittleEndian.Uint64(r.read(8))
+	if r.err == nil && M > m {
+		return ck, kzg1, kzg2, newError(ErrBlockTooLarge, "%s: CK Load Error: There is not enough to read. Found: %d, Wants: %d", vcs.folderPath+vcs.Names.CK, m, M)
+	}
+	ck = cm.Ck{M: M, V: make([]mcl.G2, M), W: make([]mcl.G1, M)}
+	for i := uint64(0); i < M && r.err == nil; i++ {
+		r.deserialize(&ck.W[i], g1)
+		r.deserialize(&ck.V[i], g2)
+	}
+	if r.err != nil {
+		return ck, kzg1, kzg2, corrupt(vcs.folderPath+vcs.Names.CK, r.err)
+	}
+
+	f, err = vcs.store.Open(vcs.Names.KZG)
+	if err != nil {
+		return ck, kzg1, kzg2, corrupt(vcs.folderPath+vcs.Names.KZG, err)
+	}
+	defer f.Close()
+	r = keyFileReader{r: bufio.NewReader(f)}
+	m = binary.LittleEndian.Uint64(r.read(8))
+	kzgM := 2*M - 1
+	if r.err == nil && m < kzgM {
+		return ck, kzg1, kzg2, newError(ErrBlockTooLarge, "%s: CK KZG Load Error: There is not enough to read. Found: %d, Wants: %d", vcs.folderPath+vcs.Names.KZG, m, kzgM)
+	}
+	kzg1 = kzg.KZG1Settings{PK: make([]mcl.G1, kzgM), VK: make([]mcl.G2, 2)}
+	kzg2 = kzg.KZG2Settings{PK: make([]mcl.G2, kzgM), VK: make([]mcl.G1, 2)}
+	for i := 0; i < 2; i++ {
+		r.deserialize(&kzg1.VK[i], g2)
+		r.deserialize(&kzg2.VK[i], g1)
+	}
+	for i := uint64(0); i < kzgM && r.err == nil; i++ {
+		r.deserialize(&kzg1.PK[i], g1)
+		r.deserialize(&kzg2.PK[i], g2)
+	}
+	if r.err != nil {
+		return ck, kzg1, kzg2, corrupt(vcs.folderPath+vcs.Names.KZG, r.err)
 	}
+	return ck, kzg1, kzg2, nil
 }
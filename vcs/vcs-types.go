@@ -0,0 +1,462 @@
+// Typed values of the scheme. Digest, ProofPath, UpdateBatch and AggregatedProof carry what is needed to use them,
+// e.g., a proof its index and ell, and have a canonical byte encoding (MarshalBinary) and a text encoding, its hex (MarshalText).
+// Verify, UpdateCom, UpdateComVec, UpdateProof and AggVerify are thin wrappers of their methods, kept for compatibility.
+// Byte encodings, integers are little endian:
+// Digest: the point. ProofPath: ell (u8), index (u64), then the L points in the order of GetProofPath, i.e., level L-1 first.
+// UpdateBatch: number of updates (u64), then index (u64) and delta for each. AggregatedProof: number of indices (u64),
+// the indices (u64 each), number of GIPA rounds (u8), then T, L and R of each round, A, B, W, V, Pi1 and Pi2.
+package vcs
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math/bits"
+
+	"github.com/alinush/go-mcl"
+	"github.com/hyperproofs/gipa-go/batch"
+	"github.com/hyperproofs/gipa-go/cm"
+)
+
+type Digest mcl.G1
+
+type ProofPath struct {
+	Index uint64
+	L     uint8
+	Nodes []mcl.G1 // Same as GetProofPath
+}
+
+// Add Deltas[t] to the value at Indices[t].
+type UpdateBatch struct {
+	Indices []uint64
+	Deltas  []mcl.Fr
+}
+
+// Proof of the values at Indices, in that order.
+type AggregatedProof struct {
+	Indices []uint64
+	Proof   batch.Proof
+}
+
+// Proof of index. Returns ErrBadIndex if index >= N.
+func (vcs *VCS) Proof(index uint64) (ProofPath, error) {
+	if index >= vcs.N {
+		return ProofPath{}, newError(ErrBadIndex, "Proof: Index %d is out of range", index)
+	}
+	return ProofPath{Index: index, L: vcs.L, Nodes: vcs.GetProofPath(index)}, nil
+}
+
+// Aggregate exactly TxnLimit proofs. See AggProve.
+func (vcs *VCS) Aggregate(proofs []ProofPath) (AggregatedProof, error) {
+
+	indexVec := make([]uint64, len(proofs))
+	proofVec := make([][]mcl.G1, len(proofs))
+	for t := range proofs {
+		if proofs[t].L != vcs.L {
+			return AggregatedProof{}, newError(ErrBadProofLength, "Aggregate: Proof %d is for ell = %d", t, proofs[t].L)
+		}
+		indexVec[t] = proofs[t].Index
+		proofVec[t] = proofs[t].Nodes
+	}
+	proof, err := vcs.AggProve(indexVec, proofVec)
+	if err != nil {
+		return AggregatedProof{}, err
+	}
+	return AggregatedProof{Indices: indexVec, Proof: proof}, nil
+}
+
+func (d *Digest) g1() *mcl.G1 {
+	return (*mcl.G1)(d)
+}
+
+// Digest after the updates of b. Returns ErrBadBlockSize if the vectors of b differ in length, and ErrBadIndex for an index >= N.
+// With the mapped UPK tree, a point that does not decode is reported as ErrKeyFolderCorrupt.
+func (d Digest) Update(vcs *VCS, b UpdateBatch) (Digest, error) {
+
+	if err := b.check(vcs.N); err != nil {
+		return d, err
+	}
+	var result, temp mcl.G1
+	upks := make([]mcl.G1, len(b.Indices))
+	for i := range b.Indices {
+		var err error
+		if upks[i], err = vcs.upkNode(vcs.L, b.Indices[i]); err != nil {
+			return d, err
+		}
+	}
+	mcl.G1MulVec(&temp, upks, b.Deltas)
+	mcl.G1Add(&result, d.g1(), &temp)
+	return Digest(result), nil
+}
+
+func (d Digest) Equal(other Digest) bool {
+	return d.g1().IsEqual(other.g1())
+}
+
+func (d Digest) MarshalBinary() ([]byte, error) {
+	return d.g1().Serialize(), nil
+}
+
+func (d *Digest) UnmarshalBinary(data []byte) error {
+	if len(data) != GetG1ByteSize() {
+		return fmt.Errorf("Digest: Expected %d bytes, got %d", GetG1ByteSize(), len(data))
+	}
+	return d.g1().Deserialize(data)
+}
+
+func (d Digest) MarshalText() ([]byte, error) {
+	return marshalText(d)
+}
+
+func (d *Digest) UnmarshalText(text []byte) error {
+	return unmarshalText(d, text)
+}
+
+func (d Digest) String() string {
+	text, _ := d.MarshalText()
+	return string(text)
+}
+
+// False for a proof of another ell, or an index out of range.
+func (p ProofPath) Verify(vcs *VCS, digest Digest, value mcl.Fr) bool {
+
+	if p.L != vcs.L || len(p.Nodes) != int(vcs.L) || p.Index >= vcs.N {
+		return false
+	}
+
+	// temp variables
+	var rhs mcl.GT
+	var g mcl.G1
+	ps := make([]mcl.G1, vcs.L+1)
+	qs := make([]mcl.G2, vcs.L+1)
+	binary := ToBinary(p.Index, vcs.L)
+	for i := uint8(0); i < vcs.L; i++ {
+		if binary[i] {
+			qs[i] = vcs.VRKSubOneRev[i]
+		} else {
+			qs[i] = vcs.VRK[i]
+		}
+		ps[i] = p.Nodes[i]
+	}
+
+	// Move e(digest/g^{a_i}, h) to other side. Thus it will be e(g^{a_i}/digest, h)
+	mcl.G1Mul(&g, &vcs.G, &value)
+	mcl.G1Sub(&g, &g, digest.g1())
+	ps[vcs.L] = g
+	qs[vcs.L] = vcs.H
+
+	mcl.MillerLoopVec(&rhs, ps, qs)
+	mcl.FinalExp(&rhs, &rhs)
+	return rhs.IsOne()
+}
+
+// Proof after the updates of b. Same errors as Digest.Update, and ErrBadProofLength for a proof of another ell.
+func (p ProofPath) Update(vcs *VCS, b UpdateBatch) (ProofPath, error) {
+
+	if p.L != vcs.L || len(p.Nodes) != int(vcs.L) {
+		return p, newError(ErrBadProofLength, "ProofPath: Proof is for ell = %d, expected ell = %d", p.L, vcs.L)
+	}
+	if err := b.check(vcs.N); err != nil {
+		return p, err
+	}
+	nodes := p.Nodes
+	for i := range b.Indices {
+		upk, err := vcs.getUpk(b.Indices[i])
+		if err != nil {
+			return p, err
+		}
+		nodes = updateProof(&vcs.G, upk, vcs.L, nodes, p.Index, b.Indices[i], b.Deltas[i])
+	}
+	return ProofPath{Index: p.Index, L: p.L, Nodes: nodes}, nil
+}
+
+func (p ProofPath) Equal(other ProofPath) bool {
+	return p.Index == other.Index && p.L == other.L && SliceIsEqual(p.Nodes, other.Nodes)
+}
+
+func (p ProofPath) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte(p.L)
+	binary.Write(&buf, binary.LittleEndian, p.Index)
+	for i := range p.Nodes {
+		buf.Write(p.Nodes[i].Serialize())
+	}
+	return buf.Bytes(), nil
+}
+
+// Returns ErrBadEll, ErrBadIndex or ErrBadProofLength if data is not a proof.
+func (p *ProofPath) UnmarshalBinary(data []byte) error {
+
+	if len(data) < 9 {
+		return newError(ErrBadProofLength, "ProofPath: Truncated header")
+	}
+	L := data[0]
+	index := binary.LittleEndian.Uint64(data[1:9])
+	if L == 0 || L >= 32 {
+		return newError(ErrBadEll, "ProofPath: Either ell is 0 or >= 32, got %d", L)
+	}
+	if index >= uint64(1)<<L {
+		return newError(ErrBadIndex, "ProofPath: Index %d is out of range for ell = %d", index, L)
+	}
+	g1 := GetG1ByteSize()
+	if len(data) != 9+int(L)*g1 {
+		return newError(ErrBadProofLength, "ProofPath: Expected %d bytes, got %d", 9+int(L)*g1, len(data))
+	}
+	nodes := make([]mcl.G1, L)
+	for i := range nodes {
+		if err := nodes[i].Deserialize(data[9+i*g1 : 9+(i+1)*g1]); err != nil {
+			return err
+		}
+	}
+	*p = ProofPath{Index: index, L: L, Nodes: nodes}
+	return nil
+}
+
+func (p ProofPath) MarshalText() ([]byte, error) {
+	return marshalText(p)
+}
+
+func (p *ProofPath) UnmarshalText(text []byte) error {
+	return unmarshalText(p, text)
+}
+
+func (b UpdateBatch) Len() int {
+	return len(b.Indices)
+}
+
+func (b UpdateBatch) check(N uint64) error {
+	if len(b.Indices) != len(b.Deltas) {
+		return newError(ErrBadBlockSize, "UpdateBatch: Got %d indices and %d deltas", len(b.Indices), len(b.Deltas))
+	}
+	for _, index := range b.Indices {
+		if index >= N {
+			return newError(ErrBadIndex, "UpdateBatch: Index %d is out of range", index)
+		}
+	}
+	return nil
+}
+
+func (b UpdateBatch) Equal(other UpdateBatch) bool {
+	if len(b.Indices) != len(other.Indices) || len(b.Deltas) != len(other.Deltas) {
+		return false
+	}
+	for i := range b.Indices {
+		if b.Indices[i] != other.Indices[i] {
+			return false
+		}
+	}
+	for i := range b.Deltas {
+		if !b.Deltas[i].IsEqual(&other.Deltas[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Returns ErrBadBlockSize if the vectors differ in length.
+func (b UpdateBatch) MarshalBinary() ([]byte, error) {
+	if len(b.Indices) != len(b.Deltas) {
+		return nil, newError(ErrBadBlockSize, "UpdateBatch: Got %d indices and %d deltas", len(b.Indices), len(b.Deltas))
+	}
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, uint64(len(b.Indices)))
+	for i := range b.Indices {
+		binary.Write(&buf, binary.LittleEndian, b.Indices[i])
+		buf.Write(b.Deltas[i].Serialize())
+	}
+	return buf.Bytes(), nil
+}
+
+func (b *UpdateBatch) UnmarshalBinary(data []byte) error {
+
+	r := bytes.NewReader(data)
+	var count uint64
+	if err := binary.Read(r, binary.LittleEndian, &count); err != nil {
+		return newError(ErrBadBlockSize, "UpdateBatch: Truncated header")
+	}
+	fr := GetFrByteSize()
+	if uint64(r.Len())%uint64(8+fr) != 0 || count != uint64(r.Len())/uint64(8+fr) {
+		return newError(ErrBadBlockSize, "UpdateBatch: %d updates do not match %d bytes", count, len(data))
+	}
+	indices := make([]uint64, count)
+	deltas := make([]mcl.Fr, count)
+	kr := keyFileReader{r: r}
+	for i := range indices {
+		indices[i] = binary.LittleEndian.Uint64(kr.read(8))
+		kr.deserialize(&deltas[i], fr)
+	}
+	if kr.err != nil {
+		return kr.err
+	}
+	*b = UpdateBatch{Indices: indices, Deltas: deltas}
+	return nil
+}
+
+func (b UpdateBatch) MarshalText() ([]byte, error) {
+	return marshalText(b)
+}
+
+func (b *UpdateBatch) UnmarshalText(text []byte) error {
+	return unmarshalText(b, text)
+}
+
+// Returns ErrBadBlockSize if the values do not match the indices, or there are not TxnLimit of them.
+// A malformed proof, e.g., with the wrong number of GIPA rounds, does not verify.
+func (p AggregatedProof) Verify(vcs *VCS, digest Digest, values []mcl.Fr) (bool, error) {
+
+	txnLimit := int(vcs.TxnLimit)
+	L := int(vcs.L)
+	indexVec := p.Indices
+	proof := p.Proof
+
+	if len(indexVec) != txnLimit || len(values) != txnLimit {
+		return false, newError(ErrBadBlockSize, "AggVerify: Got %d indices and %d values, expected %d", len(indexVec), len(values), txnLimit)
+	}
+	rounds := bits.TrailingZeros64(vcs.MN) // MN is a power of 2
+	if len(proof.GipaKzgProof.L) != rounds || len(proof.GipaKzgProof.R) != rounds {
+		return false, nil
+	}
+	for t := range indexVec {
+		if indexVec[t] >= vcs.N {
+			return false, nil
+		}
+	}
+
+	P := make([]mcl.G1, txnLimit)
+	Q := make([]mcl.G2, txnLimit)
+	var g mcl.G1 // temp variables
+
+	for t := range values {
+		mcl.G1Mul(&g, &vcs.G, &values[t])
+		mcl.G1Sub(&g, digest.g1(), &g)
+		P[t] = g
+		Q[t] = vcs.H
+	}
+
+	pPad := make([]mcl.G1, vcs.nDiff)
+	qPad := make([]mcl.G2, vcs.nDiff)
+	P = append(P, pPad...)
+	Q = append(Q, qPad...)
+
+	var B []mcl.G2
+	var binary []bool
+	b := make([]mcl.G2, vcs.L)
+	for t := range indexVec {
+		binary = ToBinary(indexVec[t], vcs.L)
+		for i := 0; i < L; i++ {
+			if binary[i] == true {
+				b[i] = vcs.VRKSubOneRev[i]
+			} else {
+				b[i] = vcs.VRK[i]
+			}
+		}
+		B = append(B, b...)
+	}
+	bPad := make([]mcl.G2, vcs.mnDiff)
+	B = append(B, bPad...)
+
+	verifier := newAggVerifier(uint32(L), uint32(vcs.TxnLimit+uint64(vcs.nDiff)), vcs.MN, vcs.ck.W, &vcs.kzg1, &vcs.kzg2, P, Q, B)
+	status := verifier.VerifyEdrax(proof)
+	return status, nil
+}
+
+func (p AggregatedProof) Equal(other AggregatedProof) bool {
+	a, _ := p.MarshalBinary()
+	b, _ := other.MarshalBinary()
+	return bytes.Equal(a, b)
+}
+
+func (p AggregatedProof) MarshalBinary() ([]byte, error) {
+
+	var buf bytes.Buffer
+	proof := &p.Proof.GipaKzgProof
+	binary.Write(&buf, binary.LittleEndian, uint64(len(p.Indices)))
+	binary.Write(&buf, binary.LittleEndian, p.Indices)
+	buf.WriteByte(uint8(len(proof.L)))
+	buf.Write(p.Proof.T.Serialize())
+	for i := range proof.L {
+		for j := range proof.L[i].Com {
+			buf.Write(proof.L[i].Com[j].Serialize())
+			buf.Write(proof.R[i].Com[j].Serialize())
+		}
+	}
+	buf.Write(proof.A[0].Serialize())
+	buf.Write(proof.B[0].Serialize())
+	buf.Write(proof.W.Serialize())
+	buf.Write(proof.V.Serialize())
+	buf.Write(proof.Pi1.Serialize())
+	buf.Write(proof.Pi2.Serialize())
+	return buf.Bytes(), nil
+}
+
+// Returns ErrBadBlockSize if data is not an aggregated proof.
+func (p *AggregatedProof) UnmarshalBinary(data []byte) error {
+
+	r := bytes.NewReader(data)
+	var count uint64
+	if err := binary.Read(r, binary.LittleEndian, &count); err != nil || count > MAX_AGG_SIZE {
+		return newError(ErrBadBlockSize, "AggregatedProof: Bad header")
+	}
+	indices := make([]uint64, count)
+	if err := binary.Read(r, binary.LittleEndian, indices); err != nil {
+		return newError(ErrBadBlockSize, "AggregatedProof: Truncated indices")
+	}
+	rounds, err := r.ReadByte()
+	if err != nil || rounds > 64 {
+		return newError(ErrBadBlockSize, "AggregatedProof: Bad number of rounds")
+	}
+
+	var result AggregatedProof
+	result.Indices = indices
+	proof := &result.Proof.GipaKzgProof
+	proof.L = make([]cm.Com, rounds)
+	proof.R = make([]cm.Com, rounds)
+	kr := keyFileReader{r: r}
+	kr.deserialize(&result.Proof.T, GetGTByteSize())
+	for i := range proof.L {
+		for j := range proof.L[i].Com {
+			kr.deserialize(&proof.L[i].Com[j], GetGTByteSize())
+			kr.deserialize(&proof.R[i].Com[j], GetGTByteSize())
+		}
+	}
+	kr.deserialize(&proof.A[0], GetG1ByteSize())
+	kr.deserialize(&proof.B[0], GetG2ByteSize())
+	kr.deserialize(&proof.W, GetG1ByteSize())
+	kr.deserialize(&proof.V, GetG2ByteSize())
+	kr.deserialize(&proof.Pi1, GetG1ByteSize())
+	kr.deserialize(&proof.Pi2, GetG2ByteSize())
+	if kr.err != nil || r.Len() != 0 {
+		return newError(ErrBadBlockSize, "AggregatedProof: Truncated or trailing data")
+	}
+	*p = result
+	return nil
+}
+
+func (p AggregatedProof) MarshalText() ([]byte, error) {
+	return marshalText(p)
+}
+
+func (p *AggregatedProof) UnmarshalText(text []byte) error {
+	return unmarshalText(p, text)
+}
+
+// Hex of the byte encoding.
+func marshalText(v encoding.BinaryMarshaler) ([]byte, error) {
+	data, err := v.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	text := make([]byte, hex.EncodedLen(len(data)))
+	hex.Encode(text, data)
+	return text, nil
+}
+
+func unmarshalText(v encoding.BinaryUnmarshaler, text []byte) error {
+	data := make([]byte, hex.DecodedLen(len(text)))
+	if _, err := hex.Decode(data, text); err != nil {
+		return err
+	}
+	return v.UnmarshalBinary(data)
+}
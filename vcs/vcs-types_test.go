@@ -0,0 +1,138 @@
+package vcs
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/alinush/go-mcl"
+)
+
+// The typed values verify and update as the functions they wrap, and survive their byte and text encodings.
+func TestTypes(t *testing.T) {
+
+	mcl.InitFromString("bls12-381")
+
+	L := uint8(6)
+	txnLimit := uint64(4)
+	vcs, err := NewVCSInMemory(16, L, txnLimit)
+	check(err)
+	aFr := GenerateVector(vcs.N)
+	vcs.OpenAll(aFr)
+	digest := Digest(vcs.Commit(aFr, uint64(L)))
+
+	proofs := make([]ProofPath, txnLimit)
+	values := make([]mcl.Fr, txnLimit)
+	for k := range proofs {
+		proofs[k], err = vcs.Proof(uint64(k) * 13 % vcs.N)
+		check(err)
+		values[k] = aFr[proofs[k].Index]
+	}
+	batch := UpdateBatch{Indices: []uint64{3, 13, 40}, Deltas: GenerateVector(3)}
+
+	t.Run(fmt.Sprintf("%d/Verify;%d", L, txnLimit), func(t *testing.T) {
+		for k := range proofs {
+			if !proofs[k].Verify(vcs, digest, values[k]) {
+				t.Errorf("Proof %d failed to verify", k)
+			}
+		}
+		other := proofs[0]
+		other.Index ^= 1
+		if other.Verify(vcs, digest, values[0]) {
+			t.Errorf("Proof verified for the wrong index")
+		}
+		if _, err := vcs.Proof(vcs.N); !errors.Is(err, ErrBadIndex) {
+			t.Errorf("Expected ErrBadIndex, got %v", err)
+		}
+	})
+
+	t.Run(fmt.Sprintf("%d/Update;%d", L, txnLimit), func(t *testing.T) {
+		updated, err := digest.Update(vcs, batch)
+		check(err)
+		expected, err := vcs.UpdateComVec(mcl.G1(digest), batch.Indices, batch.Deltas)
+		check(err)
+		if !updated.Equal(Digest(expected)) {
+			t.Errorf("Digest mismatch")
+		}
+
+		proof, err := proofs[1].Update(vcs, batch)
+		check(err)
+		nodes := proofs[1].Nodes
+		for i := range batch.Indices {
+			nodes, err = vcs.UpdateProof(nodes, proofs[1].Index, batch.Indices[i], batch.Deltas[i])
+			check(err)
+		}
+		if !SliceIsEqual(proof.Nodes, nodes) || proof.Index != proofs[1].Index {
+			t.Errorf("Proof mismatch")
+		}
+		value := values[1]
+		mcl.FrAdd(&value, &value, &batch.Deltas[1]) // proofs[1] is of index 13
+		if !proof.Verify(vcs, updated, value) {
+			t.Errorf("Updated proof failed to verify")
+		}
+
+		if _, err := digest.Update(vcs, UpdateBatch{Indices: batch.Indices[1:], Deltas: batch.Deltas}); !errors.Is(err, ErrBadBlockSize) {
+			t.Errorf("Expected ErrBadBlockSize, got %v", err)
+		}
+		if _, err := vcs.UpdateComVec(mcl.G1(digest), batch.Indices[1:], batch.Deltas); !errors.Is(err, ErrBadBlockSize) {
+			t.Errorf("UpdateComVec: Expected ErrBadBlockSize, got %v", err)
+		}
+		if _, err := vcs.UpdateProof(proofs[1].Nodes[1:], proofs[1].Index, 3, batch.Deltas[0]); !errors.Is(err, ErrBadProofLength) {
+			t.Errorf("UpdateProof: Expected ErrBadProofLength, got %v", err)
+		}
+	})
+
+	t.Run(fmt.Sprintf("%d/Aggregate;%d", L, txnLimit), func(t *testing.T) {
+		aggProof, err := vcs.Aggregate(proofs)
+		check(err)
+		if status, err := aggProof.Verify(vcs, digest, values); !status || err != nil {
+			t.Errorf("Aggregated proof failed to verify: %v", err)
+		}
+
+		var decoded AggregatedProof
+		text, err := aggProof.MarshalText()
+		check(err)
+		check(decoded.UnmarshalText(text))
+		if !decoded.Equal(aggProof) {
+			t.Errorf("Aggregated proof changed through its text encoding")
+		}
+		if status, err := decoded.Verify(vcs, digest, values); !status || err != nil {
+			t.Errorf("Decoded aggregated proof failed to verify: %v", err)
+		}
+		data, err := aggProof.MarshalBinary()
+		check(err)
+		if err := decoded.UnmarshalBinary(data[:len(data)-1]); !errors.Is(err, ErrBadBlockSize) {
+			t.Errorf("Expected ErrBadBlockSize, got %v", err)
+		}
+	})
+
+	t.Run(fmt.Sprintf("%d/Encoding;%d", L, txnLimit), func(t *testing.T) {
+		var d Digest
+		check(d.UnmarshalText([]byte(digest.String())))
+		if !d.Equal(digest) {
+			t.Errorf("Digest changed through its text encoding")
+		}
+
+		var p ProofPath
+		data, err := proofs[2].MarshalBinary()
+		check(err)
+		if len(data) != 9+int(L)*GetG1ByteSize() {
+			t.Errorf("Proof takes %d bytes", len(data))
+		}
+		check(p.UnmarshalBinary(data))
+		if !p.Equal(proofs[2]) {
+			t.Errorf("Proof changed through its byte encoding")
+		}
+		if err := p.UnmarshalBinary(data[:len(data)-1]); !errors.Is(err, ErrBadProofLength) {
+			t.Errorf("Expected ErrBadProofLength, got %v", err)
+		}
+
+		var b UpdateBatch
+		text, err := batch.MarshalText()
+		check(err)
+		check(b.UnmarshalText(text))
+		if !b.Equal(batch) || b.Len() != 3 {
+			t.Errorf("Batch changed through its text encoding")
+		}
+	})
+}
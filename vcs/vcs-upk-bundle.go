@@ -0,0 +1,134 @@
+// Portable UPK paths for light clients.
+// A client that keeps its own proof up to date with UpdateProof needs the UPK path of every index that changes.
+// A bundle holds the paths of a set of indices, with the ancestors they share written once.
+// Layout: UPK_BUNDLE_MAGIC, version (u32), ell (u64), number of indices (u64), the indices (u64 each),
+// then the nodes of upkNodes in order. All integers are little endian.
+package vcs
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/alinush/go-mcl"
+)
+
+const UPK_BUNDLE_MAGIC = "HYPERUPK"
+const UPK_BUNDLE_VERSION = 1
+
+// Write the bundle of the UPK paths of indices. The tree must be in memory or mapped.
+func (vcs *VCS) ExportUpkBundle(w io.Writer, indices []uint64) error {
+	upk_db := make(map[uint64][]mcl.G1, len(indices))
+	for _, index := range indices {
+		upk_db[index] = vcs.GetUpk(index)
+	}
+	return WriteUpkBundle(w, vcs.L, indices, upk_db)
+}
+
+// Write the bundle of the UPK paths of indices taken from upk_db, e.g., from UpkDbLoad or GenUpkFake.
+func WriteUpkBundle(w io.Writer, L uint8, indices []uint64, upk_db map[uint64][]mcl.G1) error {
+
+	for _, index := range indices {
+		if len(upk_db[index]) != int(L) {
+			return fmt.Errorf("UPK bundle: Missing the UPK of index %d", index)
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+	kw := keyFileWriter{w: bw}
+	header := make([]byte, len(UPK_BUNDLE_MAGIC)+4+8+8)
+	copy(header, UPK_BUNDLE_MAGIC)
+	binary.LittleEndian.PutUint32(header[len(UPK_BUNDLE_MAGIC):], UPK_BUNDLE_VERSION)
+	binary.LittleEndian.PutUint64(header[len(UPK_BUNDLE_MAGIC)+4:], uint64(L))
+	binary.LittleEndian.PutUint64(header[len(UPK_BUNDLE_MAGIC)+12:], uint64(len(indices)))
+	kw.write(header)
+	indexBytes := make([]byte, 8)
+	for _, index := range indices {
+		binary.LittleEndian.PutUint64(indexBytes, index)
+		kw.write(indexBytes)
+	}
+
+	values := make(map[uint64]mcl.G1)
+	for _, index := range indices {
+		for l := uint8(1); l <= L; l++ {
+			k := index & ((uint64(1) << l) - 1)
+			values[(uint64(1)<<l)-1+k] = upk_db[index][l-1]
+		}
+	}
+	for _, j := range upkNodes(L, indices) {
+		value := values[j]
+		kw.write(value.Serialize())
+	}
+	if kw.err != nil {
+		return kw.err
+	}
+	return bw.Flush()
+}
+
+// Read a bundle and check every node against VRK. Returns the upk_db of the indices in the bundle.
+// Only the public parameters are needed, i.e., G, H and VRK.
+func (vcs *VCS) ImportUpkBundle(r io.Reader) (map[uint64][]mcl.G1, error) {
+
+	indices, nodes, values, err := readUpkBundle(bufio.NewReader(r), vcs.L)
+	if err != nil {
+		return nil, err
+	}
+	if !vcs.verifyUpkNodes(nodes, values) {
+		return nil, errors.New("UPK bundle: Verification failed")
+	}
+	return upkDb(vcs.L, indices, nodes, values), nil
+}
+
+// Parse a bundle of paths for ell. The nodes are decoded, but not checked against VRK.
+func readUpkBundle(br io.Reader, ell uint8) ([]uint64, []uint64, []mcl.G1, error) {
+
+	magic := make([]byte, len(UPK_BUNDLE_MAGIC))
+	if _, err := io.ReadFull(br, magic); err != nil || string(magic) != UPK_BUNDLE_MAGIC {
+		return nil, nil, nil, errors.New("UPK bundle: Not a UPK bundle")
+	}
+	var version uint32
+	var L, count uint64
+	if err := binary.Read(br, binary.LittleEndian, &version); err != nil {
+		return nil, nil, nil, err
+	}
+	if version > UPK_BUNDLE_VERSION {
+		return nil, nil, nil, fmt.Errorf("UPK bundle: Unsupported version %d. Supports up to %d", version, UPK_BUNDLE_VERSION)
+	}
+	for _, x := range []interface{}{&L, &count} {
+		if err := binary.Read(br, binary.LittleEndian, x); err != nil {
+			return nil, nil, nil, fmt.Errorf("UPK bundle: Truncated header: %v", err)
+		}
+	}
+	if L != uint64(ell) {
+		return nil, nil, nil, fmt.Errorf("UPK bundle: Paths are for ell = %d, expected ell = %d", L, ell)
+	}
+	N := uint64(1) << ell
+	if count > N {
+		return nil, nil, nil, fmt.Errorf("UPK bundle: Bad header, %d indices", count)
+	}
+
+	indices := make([]uint64, count)
+	for i := range indices {
+		if err := binary.Read(br, binary.LittleEndian, &indices[i]); err != nil {
+			return nil, nil, nil, fmt.Errorf("UPK bundle: Truncated index %d", i)
+		}
+		if indices[i] >= N {
+			return nil, nil, nil, fmt.Errorf("UPK bundle: Index %d is out of range", indices[i])
+		}
+	}
+
+	nodes := upkNodes(ell, indices)
+	values := make([]mcl.G1, len(nodes))
+	data := make([]byte, GetG1ByteSize())
+	for a := range values {
+		if _, err := io.ReadFull(br, data); err != nil {
+			return nil, nil, nil, fmt.Errorf("UPK bundle: Truncated node %d", a)
+		}
+		if err := values[a].Deserialize(data); err != nil {
+			return nil, nil, nil, fmt.Errorf("UPK bundle: Bad node %d: %v", a, err)
+		}
+	}
+	return indices, nodes, values, nil
+}
@@ -0,0 +1,68 @@
+package vcs
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/alinush/go-mcl"
+)
+
+func TestUpkBundle(t *testing.T) {
+
+	mcl.InitFromString("bls12-381")
+
+	L := uint8(10)
+	K := 64
+	vcs, err := NewVCSInMemory(16, L, 8)
+	check(err)
+
+	indices := make([]uint64, K)
+	for i := range indices {
+		indices[i] = uint64(i) * 37 % vcs.N
+	}
+	indices[K-1] = indices[0] // Duplicates are allowed
+
+	var bundle bytes.Buffer
+	check(vcs.ExportUpkBundle(&bundle, indices))
+
+	t.Run(fmt.Sprintf("%d/Import;%d", L, K), func(t *testing.T) {
+		upk_db, err := vcs.ImportUpkBundle(bytes.NewReader(bundle.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, index := range indices {
+			if !SliceIsEqual(upk_db[index], vcs.GetUpk(index)) {
+				t.Fatalf("UPK mismatch at index %d", index)
+			}
+		}
+		full := K * int(L) * GetG1ByteSize()
+		if bundle.Len() >= full {
+			t.Errorf("Bundle is %d bytes, paths without sharing are %d bytes", bundle.Len(), full)
+		}
+	})
+
+	t.Run(fmt.Sprintf("%d/Tampered;%d", L, K), func(t *testing.T) {
+		upk_db := make(map[uint64][]mcl.G1)
+		for _, index := range indices {
+			upk_db[index] = vcs.GetUpk(index)
+		}
+		upk_db[indices[5]][L-1] = vcs.G
+		var tampered bytes.Buffer
+		check(WriteUpkBundle(&tampered, L, indices, upk_db))
+		if _, err := vcs.ImportUpkBundle(&tampered); err == nil {
+			t.Errorf("Tampered bundle was accepted")
+		}
+	})
+
+	t.Run(fmt.Sprintf("%d/WrongEll;%d", L, K), func(t *testing.T) {
+		other, err := NewVCSInMemory(16, L-1, 8)
+		check(err)
+		if _, err := other.ImportUpkBundle(bytes.NewReader(bundle.Bytes())); err == nil {
+			t.Errorf("Bundle for another ell was accepted")
+		}
+		if _, err := vcs.ImportUpkBundle(bytes.NewReader(bundle.Bytes()[:bundle.Len()-1])); err == nil {
+			t.Errorf("Truncated bundle was accepted")
+		}
+	})
+}
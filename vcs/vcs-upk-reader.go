@@ -0,0 +1,147 @@
+// Random-access reads of UPK paths from the key files.
+// Pruned mode (see vcs-pruned.go) only needs the UPK of the indices it serves.
+// UpkReader reads just those paths from the upk-*.data files, without the trapdoors and without loading the UPK tree.
+package vcs
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/alinush/go-mcl"
+)
+
+type UpkReader struct {
+	L      uint8
+	shards []ManifestEntry // Sorted by Start
+	files  []KeyReader     // files[i] holds shards[i]
+}
+
+// Open the UPK shards of the folder for reading. Run this after Init and LoadManifest.
+func (vcs *VCS) NewUpkReader() (*UpkReader, error) {
+
+	r := UpkReader{L: vcs.L}
+	var err error
+	if r.shards, err = vcs.UpkShards(); err != nil {
+		return nil, err
+	}
+	r.files = make([]KeyReader, 0, len(r.shards))
+	for i := range r.shards {
+		f, err := vcs.store.Open(r.shards[i].Name)
+		if err != nil {
+			r.Close()
+			return nil, corrupt(vcs.folderPath+r.shards[i].Name, err)
+		}
+		r.files = append(r.files, f)
+	}
+	return &r, nil
+}
+
+func (r *UpkReader) Close() {
+	for i := range r.files {
+		r.files[i].Close()
+	}
+}
+
+// Read the UPK of every index in indices. Same format as GetUpk and GenUpkFake.
+// Ancestors shared by the indices are read once. Returns the upk_db and the number of nodes read.
+func (r *UpkReader) ReadUpkDb(indices []uint64) (map[uint64][]mcl.G1, int, error) {
+
+	nodes := upkNodes(r.L, indices)
+
+	// One reader per shard. Nodes are sorted, thus each shard gets a contiguous run.
+	values := make([]mcl.G1, len(nodes))
+	errs := make([]error, len(r.shards))
+	var wg sync.WaitGroup
+	start := 0
+	for s := range r.shards {
+		stop := sort.Search(len(nodes), func(a int) bool { return nodes[a] >= r.shards[s].Stop })
+		if start < stop {
+			wg.Add(1)
+			go func(s, start, stop int) {
+				defer wg.Done()
+				errs[s] = r.readNodes(s, nodes[start:stop], values[start:stop])
+			}(s, start, stop)
+		}
+		start = stop
+	}
+	wg.Wait()
+	if start != len(nodes) {
+		return nil, 0, newError(ErrKeyFolderCorrupt, "UpkReader: Node %d is not in any shard", nodes[start])
+	}
+	for _, err := range errs {
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+
+	return upkDb(r.L, indices, nodes, values), len(nodes), nil
+}
+
+// Positions of the UPK nodes on the paths of indices, sorted and without duplicates. UPK[l][k] is at 2^l - 1 + k.
+// Level 0 is not part of any path.
+func upkNodes(L uint8, indices []uint64) []uint64 {
+
+	nodeSet := make(map[uint64]bool)
+	for _, index := range indices {
+		for l := uint8(1); l <= L; l++ {
+			k := index & ((uint64(1) << l) - 1)
+			nodeSet[(uint64(1)<<l)-1+k] = true
+		}
+	}
+	nodes := make([]uint64, 0, len(nodeSet))
+	for j := range nodeSet {
+		nodes = append(nodes, j)
+	}
+	sort.Slice(nodes, func(a, b int) bool { return nodes[a] < nodes[b] })
+	return nodes
+}
+
+// upk_db of indices, given the values of the nodes returned by upkNodes.
+func upkDb(L uint8, indices []uint64, nodes []uint64, values []mcl.G1) map[uint64][]mcl.G1 {
+
+	lookup := make(map[uint64]int, len(nodes))
+	for a, j := range nodes {
+		lookup[j] = a
+	}
+	upk_db := make(map[uint64][]mcl.G1, len(indices))
+	for _, index := range indices {
+		upk := make([]mcl.G1, L)
+		for l := uint8(1); l <= L; l++ {
+			k := index & ((uint64(1) << l) - 1)
+			upk[l-1] = values[lookup[(uint64(1)<<l)-1+k]]
+		}
+		upk_db[index] = upk
+	}
+	return upk_db
+}
+
+func (r *UpkReader) readNodes(s int, nodes []uint64, values []mcl.G1) error {
+
+	data := make([]byte, GetG1ByteSize())
+	for a, j := range nodes {
+		offset := int64(j-r.shards[s].Start) * int64(GetG1ByteSize())
+		if _, err := r.files[s].ReadAt(data, offset); err != nil {
+			return corrupt(r.shards[s].Name, err)
+		}
+		if err := values[a].Deserialize(data); err != nil {
+			return corrupt(r.shards[s].Name, err)
+		}
+	}
+	return nil
+}
+
+// Build the upk_db of the pruned mode from the key files.
+// Only the public parameters and the paths of indices are read.
+func (vcs *VCS) UpkDbLoad(indices []uint64) (map[uint64][]mcl.G1, error) {
+	r, err := vcs.NewUpkReader()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	upk_db, count, err := r.ReadUpkDb(indices)
+	if err != nil {
+		return nil, err
+	}
+	vcs.log("Read", count, "UPK nodes for", len(indices), "indices")
+	return upk_db, nil
+}
@@ -0,0 +1,81 @@
+package vcs
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/alinush/go-mcl"
+)
+
+// Paths read from the files must match GetUpk, and shared ancestors are read once.
+func TestUpkReader(t *testing.T) {
+
+	mcl.InitFromString("bls12-381")
+
+	L := uint8(10)
+	N := uint64(1) << L
+	folder := t.TempDir()
+
+	vcs := VCS{Config: Config{NCores: 16}}
+	check(vcs.Init(L, folder, 8))
+	check(vcs.TrapdoorsSample())
+	check(vcs.SavePublicParams())
+	check(vcs.SaveVrk())
+	check(vcs.UpkGenDriver())
+	check(vcs.SaveManifest())
+
+	pruned := VCS{}
+	check(pruned.Init(L, folder, 8))
+	check(pruned.LoadManifest())
+	check(pruned.LoadPublicParams(L))
+	reader, err := pruned.NewUpkReader()
+	check(err)
+	defer reader.Close()
+
+	t.Run(fmt.Sprintf("%d/SharedAncestors;", L), func(t *testing.T) {
+		_, count, err := reader.ReadUpkDb([]uint64{0, N / 2, 0})
+		check(err)
+		if count != int(L)+1 {
+			t.Errorf("Read %d nodes, expected %d", count, L+1)
+		}
+		_, count, err = reader.ReadUpkDb([]uint64{0, 1})
+		check(err)
+		if count != 2*int(L) {
+			t.Errorf("Read %d nodes, expected %d", count, 2*L)
+		}
+	})
+
+	indices := make([]uint64, 64)
+	for i := range indices {
+		indices[i] = uint64(i) * 997 % N
+	}
+	indices[63] = N - 1
+
+	t.Run(fmt.Sprintf("%d/ReadUpkDb;%d", L, len(indices)), func(t *testing.T) {
+		upk_db, _, err := reader.ReadUpkDb(indices)
+		check(err)
+		for _, index := range indices {
+			if !SliceIsEqual(upk_db[index], vcs.GetUpk(index)) {
+				t.Fatalf("UPK mismatch at index %d", index)
+			}
+			if !vcs.VerifyUPK(index, upk_db[index]) {
+				t.Fatalf("VerifyUPK failed at index %d", index)
+			}
+		}
+	})
+
+	t.Run(fmt.Sprintf("%d/UpdateComVecDB;%d", L, len(indices)), func(t *testing.T) {
+		var digest mcl.G1
+		delta := GenerateVector(uint64(len(indices)))
+		upk_db, err := pruned.UpkDbLoad(indices)
+		check(err)
+		a, err := vcs.UpdateComVec(digest, indices, delta)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b := pruned.UpdateComVecDB(upk_db, digest, indices, delta)
+		if !a.IsEqual(&b) {
+			t.Errorf("UpdateComVecDB mismatch")
+		}
+	})
+}
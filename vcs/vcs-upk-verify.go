@@ -0,0 +1,130 @@
+// Batched verification of UPK paths.
+// UPK[l][k] is UPK[l-1][k mod 2^(l-1)] raised to s or (1 - s), depending on the bit l - 1 of k, and UPK[1][k] is G raised to the same.
+// Each node is checked once against its parent with the pairing e(node, h) = e(parent, VRK or VRKSubOne),
+// and all the checks are folded into one multi-pairing with random coefficients.
+package vcs
+
+import (
+	"github.com/alinush/go-mcl"
+)
+
+// Verify the UPK paths of indices, upkPaths[t] being that of indices[t] in the format of GetUpk.
+// Nodes shared by the paths are checked once. Returns false and the position in indices of the first path that fails.
+// The position is -1 when all the paths verify, and len(indices) when upkPaths is not of the same length as indices.
+func (vcs *VCS) VerifyUPKBatch(indices []uint64, upkPaths [][]mcl.G1) (bool, int) {
+
+	if len(upkPaths) != len(indices) {
+		return false, len(indices) // Not a path
+	}
+
+	// Distinct nodes and the first path they appear in.
+	first := make(map[uint64]int)
+	values := make(map[uint64]mcl.G1)
+	for t, index := range indices {
+		if len(upkPaths[t]) != int(vcs.L) || index >= vcs.N {
+			return false, t
+		}
+		for l := uint8(1); l <= vcs.L; l++ {
+			j := (uint64(1) << l) - 1 + index&((uint64(1)<<l)-1)
+			value, ok := values[j]
+			if !ok {
+				values[j] = upkPaths[t][l-1]
+				first[j] = t
+			} else if !value.IsEqual(&upkPaths[t][l-1]) {
+				return false, t // Another path has a different node
+			}
+		}
+	}
+
+	nodes := upkNodes(vcs.L, indices)
+	nodeValues := make([]mcl.G1, len(nodes))
+	for a, j := range nodes {
+		nodeValues[a] = values[j]
+	}
+	if vcs.verifyUpkNodes(nodes, nodeValues) {
+		return true, -1
+	}
+
+	// A path fails if and only if one of its nodes does not match its parent.
+	lookup := upkLookup(nodes)
+	bad := len(indices)
+	for a, j := range nodes {
+		if first[j] >= bad {
+			continue
+		}
+		parent, q, ok := vcs.upkParent(lookup, nodes, nodeValues, a)
+		var lhs, rhs mcl.GT
+		if ok {
+			mcl.Pairing(&lhs, &parent, &q)
+			mcl.Pairing(&rhs, &nodeValues[a], &vcs.H)
+		}
+		if !ok || !lhs.IsEqual(&rhs) {
+			bad = first[j]
+		}
+	}
+	if bad == len(indices) {
+		return true, -1 // Every node is good. The batch failed only due to the random coefficients.
+	}
+	return false, bad
+}
+
+func upkLookup(nodes []uint64) map[uint64]int {
+	lookup := make(map[uint64]int, len(nodes))
+	for a, j := range nodes {
+		lookup[j] = a
+	}
+	return lookup
+}
+
+// Parent of nodes[a] and the VRK it is paired with. Not ok if the parent is not one of nodes.
+func (vcs *VCS) upkParent(lookup map[uint64]int, nodes []uint64, values []mcl.G1, a int) (mcl.G1, mcl.G2, bool) {
+
+	l, k := IndexInTheLevel(nodes[a])
+	parent := vcs.G
+	if l > 1 {
+		mask := (uint64(1) << (l - 1)) - 1
+		p, ok := lookup[(uint64(1)<<(l-1))-1+(k&mask)]
+		if !ok {
+			return parent, mcl.G2{}, false
+		}
+		parent = values[p]
+	}
+	if k&(uint64(1)<<(l-1)) == 0 {
+		return parent, vcs.VRKSubOne[l-1], true
+	}
+	return parent, vcs.VRK[l-1], true
+}
+
+// Check that each node is its parent raised to s or (1 - s), as in VerifyUPK. Level 1 is checked against G.
+// nodes must contain the parent of each node at level 2 and above.
+// Uses a random linear combination of the checks, hence a single multi-pairing.
+func (vcs *VCS) verifyUpkNodes(nodes []uint64, values []mcl.G1) bool {
+
+	if len(nodes) == 0 {
+		return true
+	}
+
+	lookup := upkLookup(nodes)
+	var r mcl.Fr
+	var temp, result mcl.G1
+	lhsP := make([]mcl.G1, len(nodes))
+	lhsQ := make([]mcl.G2, len(nodes))
+	for a := range nodes {
+		parent, q, ok := vcs.upkParent(lookup, nodes, values, a)
+		if !ok {
+			return false
+		}
+		lhsQ[a] = q
+
+		r.Random()
+		mcl.G1Mul(&lhsP[a], &parent, &r)
+		mcl.G1Mul(&temp, &values[a], &r)
+		mcl.G1Add(&result, &result, &temp)
+	}
+
+	var lhs, rhs mcl.GT
+	mcl.Pairing(&rhs, &result, &vcs.H)
+	mcl.MillerLoopVec(&lhs, lhsP, lhsQ)
+	mcl.FinalExp(&lhs, &lhs)
+	return lhs.IsEqual(&rhs)
+}
@@ -0,0 +1,77 @@
+package vcs
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/alinush/go-mcl"
+)
+
+func TestVerifyUPKBatch(t *testing.T) {
+
+	mcl.InitFromString("bls12-381")
+
+	L := uint8(10)
+	K := 32
+	vcs, err := NewVCSInMemory(16, L, 8)
+	check(err)
+
+	indices := make([]uint64, K)
+	paths := func() [][]mcl.G1 {
+		upkPaths := make([][]mcl.G1, K)
+		for i := range indices {
+			upkPaths[i] = vcs.GetUpk(indices[i])
+		}
+		return upkPaths
+	}
+	for i := range indices {
+		indices[i] = uint64(i) * 29 % vcs.N
+	}
+
+	t.Run(fmt.Sprintf("%d/Valid;%d", L, K), func(t *testing.T) {
+		upkPaths := paths()
+		for i := range indices {
+			if !vcs.VerifyUPK(indices[i], upkPaths[i]) {
+				t.Fatalf("VerifyUPK failed at index %d", indices[i])
+			}
+		}
+		if status, bad := vcs.VerifyUPKBatch(indices, upkPaths); !status || bad != -1 {
+			t.Errorf("VerifyUPKBatch failed at %d", bad)
+		}
+	})
+
+	t.Run(fmt.Sprintf("%d/BadLeaf;%d", L, K), func(t *testing.T) {
+		upkPaths := paths()
+		upkPaths[7][L-1] = vcs.G
+		if status, bad := vcs.VerifyUPKBatch(indices, upkPaths); status || bad != 7 {
+			t.Errorf("Expected path 7 to fail, got %v, %d", status, bad)
+		}
+	})
+
+	// Every path goes through level 1. A consistent bad node there fails the first path.
+	t.Run(fmt.Sprintf("%d/BadAncestor;%d", L, K), func(t *testing.T) {
+		upkPaths := paths()
+		for i := range upkPaths {
+			upkPaths[i][0] = vcs.G
+		}
+		if status, bad := vcs.VerifyUPKBatch(indices, upkPaths); status || bad != 0 {
+			t.Errorf("Expected path 0 to fail, got %v, %d", status, bad)
+		}
+	})
+
+	// Paths that disagree on a shared node.
+	t.Run(fmt.Sprintf("%d/Conflict;%d", L, K), func(t *testing.T) {
+		upkPaths := paths()
+		upkPaths[20][0] = vcs.G
+		if status, bad := vcs.VerifyUPKBatch(indices, upkPaths); status || bad != 20 {
+			t.Errorf("Expected path 20 to fail, got %v, %d", status, bad)
+		}
+	})
+
+	t.Run(fmt.Sprintf("%d/BadLength;%d", L, K), func(t *testing.T) {
+		upkPaths := paths()
+		if status, bad := vcs.VerifyUPKBatch(indices, upkPaths[1:]); status || bad != len(indices) {
+			t.Errorf("Expected position %d for missing paths, got %v, %d", len(indices), status, bad)
+		}
+	})
+}
@@ -2,7 +2,6 @@ package vcs
 
 import (
 	"fmt"
-	"log"
 	"os"
 
 	"github.com/alinush/go-mcl"
@@ -84,12 +83,19 @@ func minUint64(a uint64, b uint64) uint64 {
 	return b
 }
 
-func fileSize(path string) int64 {
+func maxUint64(a uint64, b uint64) uint64 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func fileSize(path string) (int64, error) {
 	fi, err := os.Stat(path)
 	if err != nil {
-		log.Fatal(err)
+		return 0, err
 	}
-	return fi.Size()
+	return fi.Size(), nil
 }
 
 func BoundsPrint(start, stop uint64) string {
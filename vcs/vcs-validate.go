@@ -0,0 +1,190 @@
+// Structural validation of the parameters, e.g., keys downloaded from a third party.
+// UPK, VRK and the aggregation keys are checked against each other using randomized batched pairing checks.
+// Once a batch fails, it is bisected to find the first inconsistent element.
+package vcs
+
+import (
+	"fmt"
+
+	"github.com/alinush/go-mcl"
+)
+
+// Location of the first inconsistency found by ValidateParams.
+// For UPK, Level and Index are the coordinates in the tree.
+// For VRK, VRKSubOne and VRKSubOneRev, Level is the trapdoor index.
+// For KZG1, KZG2 and CK, Index is the position in the aggregation keys.
+type ParamsError struct {
+	Key   string
+	Level uint8
+	Index uint64
+}
+
+func (e *ParamsError) Error() string {
+	return fmt.Sprintf("Inconsistent %s at level %d, index %d", e.Key, e.Level, e.Index)
+}
+
+// Check that the loaded parameters are internally consistent. Returns nil if they are.
+// UPK[l][k] = UPK[l-1][k'] ^ (1 - s_{l-1}) or UPK[l-1][k'] ^ s_{l-1}, where k' is k without its (l-1)th bit.
+// This is the same relation that VerifyUPK checks for a single path.
+// Aggregation keys are checked only if they are loaded.
+func (vcs *VCS) ValidateParams() error {
+
+	var temp mcl.G2
+	for i := uint8(0); i < vcs.L; i++ {
+		mcl.G2Sub(&temp, &vcs.H, &vcs.VRK[i])
+		if vcs.VRK[i].IsZero() || temp.IsZero() {
+			return &ParamsError{"VRK", i, 0} // s_i is 0 or 1
+		}
+		if !vcs.VRKSubOne[i].IsEqual(&temp) {
+			return &ParamsError{"VRKSubOne", i, 0}
+		}
+		mcl.G2Neg(&temp, &temp)
+		if !vcs.VRKSubOneRev[i].IsEqual(&temp) {
+			return &ParamsError{"VRKSubOneRev", i, 0}
+		}
+	}
+
+	if len(vcs.UPK) != int(vcs.L)+1 {
+		return &ParamsError{"UPK", uint8(len(vcs.UPK)), 0}
+	}
+	for l := range vcs.UPK {
+		if uint64(len(vcs.UPK[l])) != uint64(1)<<l {
+			return &ParamsError{"UPK", uint8(l), uint64(len(vcs.UPK[l]))}
+		}
+	}
+	if !vcs.UPK[0][0].IsEqual(&vcs.G) {
+		return &ParamsError{"UPK", 0, 0}
+	}
+
+	// Top to bottom, thus the parent level is already known to be fine.
+	for l := uint8(1); l <= vcs.L; l++ {
+		level := l
+		rangeCheck := func(lo uint64, hi uint64) bool {
+			return vcs.upkRangeCheck(level, lo, hi)
+		}
+		size := uint64(1) << l
+		if !rangeCheck(0, size) {
+			return &ParamsError{"UPK", l, bisect(0, size, rangeCheck)}
+		}
+	}
+
+	if len(vcs.kzg1.PK) == 0 {
+		return nil
+	}
+	return vcs.validateAggKeys()
+}
+
+func (vcs *VCS) validateAggKeys() error {
+
+	degree := uint64(len(vcs.kzg1.PK))
+	if degree != uint64(len(vcs.kzg2.PK)) || degree < 2*vcs.ck.M-1 {
+		return &ParamsError{"KZG1", 0, degree}
+	}
+	if len(vcs.kzg1.VK) != 2 || !vcs.kzg1.VK[0].IsEqual(&vcs.H) || !vcs.kzg1.PK[0].IsEqual(&vcs.G) {
+		return &ParamsError{"KZG1", 0, 0}
+	}
+	if len(vcs.kzg2.VK) != 2 || !vcs.kzg2.VK[0].IsEqual(&vcs.G) || !vcs.kzg2.PK[0].IsEqual(&vcs.H) {
+		return &ParamsError{"KZG2", 0, 0}
+	}
+	for i := uint64(0); i < vcs.ck.M; i++ {
+		if !vcs.ck.W[i].IsEqual(&vcs.kzg1.PK[2*i]) || !vcs.ck.V[i].IsEqual(&vcs.kzg2.PK[2*i]) {
+			return &ParamsError{"CK", 0, i}
+		}
+	}
+
+	// Position i is checked against position i + 1
+	if !vcs.kzg1RangeCheck(0, degree-1) {
+		return &ParamsError{"KZG1", 0, bisect(0, degree-1, vcs.kzg1RangeCheck) + 1}
+	}
+	if !vcs.kzg2RangeCheck(0, degree-1) {
+		return &ParamsError{"KZG2", 0, bisect(0, degree-1, vcs.kzg2RangeCheck) + 1}
+	}
+	return nil
+}
+
+// Index of the first element in [lo, hi) that fails rangeCheck.
+// Assumes that rangeCheck fails on [lo, hi).
+func bisect(lo uint64, hi uint64, rangeCheck func(uint64, uint64) bool) uint64 {
+	for hi-lo > 1 {
+		mid := lo + (hi-lo)/2
+		if !rangeCheck(lo, mid) {
+			hi = mid
+		} else {
+			lo = mid
+		}
+	}
+	return lo
+}
+
+func randomFrVec(n uint64) []mcl.Fr {
+	r := make([]mcl.Fr, n)
+	for i := range r {
+		r[i].Random()
+	}
+	return r
+}
+
+// Randomized check of UPK[l][lo:hi] against level l - 1.
+// The first half of level l pairs with VRKSubOne[l-1] and the second half with VRK[l-1].
+func (vcs *VCS) upkRangeCheck(l uint8, lo uint64, hi uint64) bool {
+
+	half := uint64(1) << (l - 1)
+	mid := hi
+	if mid > half {
+		mid = half
+	}
+	if mid < lo {
+		mid = lo
+	}
+	r := randomFrVec(hi - lo)
+
+	ps := make([]mcl.G1, 3)
+	qs := []mcl.G2{vcs.H, vcs.VRKSubOne[l-1], vcs.VRK[l-1]}
+	mcl.G1MulVec(&ps[0], vcs.UPK[l][lo:hi], r)
+	if lo < mid {
+		mcl.G1MulVec(&ps[1], vcs.UPK[l-1][lo:mid], r[:mid-lo])
+	}
+	if mid < hi { // Then mid >= half
+		mcl.G1MulVec(&ps[2], vcs.UPK[l-1][mid-half:hi-half], r[mid-lo:])
+	}
+	mcl.G1Neg(&ps[1], &ps[1])
+	mcl.G1Neg(&ps[2], &ps[2])
+
+	var result mcl.GT
+	mcl.MillerLoopVec(&result, ps, qs)
+	mcl.FinalExp(&result, &result)
+	return result.IsOne()
+}
+
+// e(g^{alpha^{i+1}}, h) = e(g^{alpha^i}, h^{alpha}) for i in [lo, hi)
+func (vcs *VCS) kzg1RangeCheck(lo uint64, hi uint64) bool {
+
+	r := randomFrVec(hi - lo)
+	ps := make([]mcl.G1, 2)
+	qs := []mcl.G2{vcs.H, vcs.kzg1.VK[1]}
+	mcl.G1MulVec(&ps[0], vcs.kzg1.PK[lo+1:hi+1], r)
+	mcl.G1MulVec(&ps[1], vcs.kzg1.PK[lo:hi], r)
+	mcl.G1Neg(&ps[1], &ps[1])
+
+	var result mcl.GT
+	mcl.MillerLoopVec(&result, ps, qs)
+	mcl.FinalExp(&result, &result)
+	return result.IsOne()
+}
+
+// e(g, h^{beta^{i+1}}) = e(g^{beta}, h^{beta^i}) for i in [lo, hi)
+func (vcs *VCS) kzg2RangeCheck(lo uint64, hi uint64) bool {
+
+	r := randomFrVec(hi - lo)
+	ps := make([]mcl.G1, 2)
+	qs := make([]mcl.G2, 2)
+	ps[0] = vcs.G
+	mcl.G2MulVec(&qs[0], vcs.kzg2.PK[lo+1:hi+1], r)
+	mcl.G1Neg(&ps[1], &vcs.kzg2.VK[1])
+	mcl.G2MulVec(&qs[1], vcs.kzg2.PK[lo:hi], r)
+
+	var result mcl.GT
+	mcl.MillerLoopVec(&result, ps, qs)
+	mcl.FinalExp(&result, &result)
+	return result.IsOne()
+}
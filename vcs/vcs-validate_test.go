@@ -0,0 +1,69 @@
+package vcs
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/alinush/go-mcl"
+)
+
+// Corrupt the keys one by one and check that ValidateParams finds the exact location.
+func TestValidateParams(t *testing.T) {
+
+	mcl.InitFromString("bls12-381")
+
+	L := uint8(8)
+	folder := t.TempDir()
+
+	vcs := VCS{}
+	check(vcs.KeyGenNoTrapdoor(16, L, folder, 16))
+	vcs = VCS{}
+	check(vcs.KeyGenLoad(16, L, folder, 16))
+
+	t.Run(fmt.Sprintf("%d/Valid;", L), func(t *testing.T) {
+		if err := vcs.ValidateParams(); err != nil {
+			t.Errorf("Valid keys failed the validation: %v", err)
+		}
+	})
+
+	var junkG1 mcl.G1
+	var junkG2 mcl.G2
+	junkG1.Random()
+	junkG2.Random()
+
+	upkCases := []ParamsError{{"UPK", L, 0}, {"UPK", L, 200}, {"UPK", 5, 7}, {"UPK", 1, 1}}
+	for _, want := range upkCases {
+		t.Run(fmt.Sprintf("%d/CorruptUPK;%d-%d", L, want.Level, want.Index), func(t *testing.T) {
+			node := vcs.UPK[want.Level][want.Index]
+			vcs.UPK[want.Level][want.Index] = junkG1
+			err := vcs.ValidateParams()
+			vcs.UPK[want.Level][want.Index] = node
+			got, ok := err.(*ParamsError)
+			if !ok || *got != want {
+				t.Errorf("Expected %v, found %v", &want, err)
+			}
+		})
+	}
+
+	t.Run(fmt.Sprintf("%d/CorruptVRK;", L), func(t *testing.T) {
+		vrk := vcs.VRKSubOneRev[3]
+		vcs.VRKSubOneRev[3] = junkG2
+		err := vcs.ValidateParams()
+		vcs.VRKSubOneRev[3] = vrk
+		got, ok := err.(*ParamsError)
+		if !ok || *got != (ParamsError{"VRKSubOneRev", 3, 0}) {
+			t.Errorf("Expected VRKSubOneRev at 3, found %v", err)
+		}
+	})
+
+	t.Run(fmt.Sprintf("%d/CorruptKZG;", L), func(t *testing.T) {
+		pk := vcs.kzg2.PK[5]
+		vcs.kzg2.PK[5] = junkG2
+		err := vcs.ValidateParams()
+		vcs.kzg2.PK[5] = pk
+		got, ok := err.(*ParamsError)
+		if !ok || *got != (ParamsError{"KZG2", 0, 5}) {
+			t.Errorf("Expected KZG2 at 5, found %v", err)
+		}
+	})
+}
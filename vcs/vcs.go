@@ -1,6 +1,9 @@
 package vcs
 
 import (
+	"io"
+	"sync"
+
 	"github.com/alinush/go-mcl"
 	"github.com/hyperproofs/gipa-go/batch"
 	"github.com/hyperproofs/gipa-go/cm"
@@ -8,6 +11,8 @@ import (
 )
 
 type VCS struct {
+	Config // Settings of the instance. See vcs-config.go
+
 	PRK          []mcl.G1   // PRK is technically not needed for our experiments
 	UPK          [][]mcl.G1 // UPK forms a tree. The UPK API in code is different from the paper.
 	VRK          []mcl.G2
@@ -29,6 +34,7 @@ type VCS struct {
 	ProofTree [][]mcl.G1 // Figure 2 from the paper is illustrative of how the ProofTree is saved.
 	// Note that lowest level f(w) is not saved in this tree.
 	// Proof serving node saves this proof tree all the time. Unable to fit beyond 2^26 in memory.
+	treeMu sync.RWMutex // Guards ProofTree: updates are applied under the write lock. See vcs-proofserver.go
 
 	// GIPA Stuff
 	MN       uint64 // Power of 2 which is nearest to TxnLimit * L. In GIPA notation let M = L, n = TxnLimit = 1024
@@ -41,32 +47,58 @@ type VCS struct {
 	kzg1 kzg.KZG1Settings // KZG + GIPA
 	kzg2 kzg.KZG2Settings // KZG + GIPA
 
-	folderPath string
+	folderPath string // Only for messages. Files are read and written through store
+
+	aggProver batch.Prover
+	aggMu     sync.Mutex // Held while GIPA runs, also for a proof that AggProveContext stopped waiting for
 
-	aggProver   batch.Prover
-	aggVerifier batch.Verifier
+	Transcript []Contribution // Contributions to the setup ceremony. See vcs-ceremony.go
 
 	DISCARD_PRK bool // We do not use: g, g^{s_1}, g^{s_2}, g^{s_1}{s_2}, g^{s_3}.....
 	// Thus, PRK is discarded by default
 	// UPK tree is enough for the prover
 	PARAM_TOO_LARGE bool
+
+	hasTrapdoors bool // False when only the public parameters are loaded
+
+	rand io.Reader // Source of randomness for the trapdoors and fake proofs. nil uses the CSPRNG. See vcs-rand.go
+
+	gTable *FixedBaseG1    // Fixed-base table of G for keygen. See vcs-fixedbase.go
+	ratios *trapdoorRatios // Ratios of the trapdoors for incremental exponents
+
+	nfiles   uint8     // Number of UPK and PRK shards of the folder. NumShards, or taken from the manifest
+	manifest *Manifest // nil for folders generated before the manifest existed. See vcs-manifest.go
+	folderL  uint8     // ell of the key folder. Can be larger than L. See vcs-derive.go
+
+	upkMmap *PointsMmap // UPK tree backed by the key files, used when PARAM_TOO_LARGE. See vcs-mmap.go
+	prkMmap *PointsMmap // Same for PRK
+
+	Progress func(KeyGenProgress) // Called as UPK and PRK are generated. See vcs-checkpoint.go
+
+	Store KeyStore // Where the keys are saved and loaded. nil uses the folder given to Init. See vcs-keystore.go
+	store KeyStore // Store, or the folder given to Init
 }
 
 // Instantiate a new vector commitment instance
 // Space for UPK and PRK will be created when keys are created and saved.
 // This reduces the memory footprint.
-func (vcs *VCS) Init(L uint8, folder string, txnLimit uint64) {
+// Returns ErrBadEll if ell is 0 or >= 32, and ErrBlockTooLarge if L * txnLimit > MAX_AGG_SIZE.
+func (vcs *VCS) Init(L uint8, folder string, txnLimit uint64) error {
 
-	NFILES = 16
-	PRKNAME = "/prk-%02d.data"
-	VRKNAME = "/vrk.data"
-	TRAPDOORNAME = "/trapdoors.data"
-	UPKNAME = "/upk-%02d.data"
 	if L == 0 || L >= 32 {
-		panic("KeyGen: Error. Either ell is 0 or >= 32")
+		return newError(ErrBadEll, "Init: Either ell is 0 or >= 32, got %d", L)
+	}
+	if txnLimit*uint64(L) > MAX_AGG_SIZE {
+		return newError(ErrBlockTooLarge, "Init: Try with smaller block size. At most %d updates for ell = %d", MaxTxnLimit(L), L)
 	}
 
+	vcs.Config = vcs.Config.withDefaults()
+	vcs.nfiles = vcs.NumShards
 	vcs.folderPath = folder
+	vcs.store = vcs.Store
+	if vcs.store == nil {
+		vcs.store = NewDirKeyStore(folder)
+	}
 
 	vcs.L = L
 	vcs.N = uint64(1) << L
@@ -90,31 +122,43 @@ func (vcs *VCS) Init(L uint8, folder string, txnLimit uint64) {
 
 	vcs.TxnLimit = txnLimit
 
-	if txnLimit*uint64(L) > MAX_AGG_SIZE {
-		panic("Try with smaller block size")
-	}
-
-	vcs.DISCARD_PRK = true // It is assumed true by default.
-	if L > 24 {
+	vcs.DISCARD_PRK = !vcs.WithPRK // It is assumed true by default.
+	if L > vcs.MemoryEll {
 		vcs.PARAM_TOO_LARGE = true // When UPK and PRK is large, keys are just flushed to files without keeping it in memory.
 	}
+	return nil
 }
 
-// Generate trapdoors for the VCS. Be sure to run this after ```Init```.
-func (vcs *VCS) TrapdoorsGen() {
+// Generate trapdoors for the VCS and save them. Be sure to run this after ```Init```.
+func (vcs *VCS) TrapdoorsGen() error {
+	if err := vcs.TrapdoorsSample(); err != nil {
+		return err
+	}
+	if err := vcs.SaveTrapdoor(); err != nil {
+		return err
+	}
+	return vcs.SavePublicParams()
+}
 
-	// Need to find a source of randomness and generate trapdoors
-	// Need to seed the randomness
+// Sample the trapdoors and compute VRK. Nothing is written to disk.
+// Returns the error of the random source, see SetRandomSource. The trapdoors are then left partially sampled.
+func (vcs *VCS) TrapdoorsSample() error {
 
 	// Sample generators
-	vcs.G.Random()
-	vcs.H.Random()
+	if err := RandomG1(vcs.rand, &vcs.G); err != nil {
+		return err
+	}
+	if err := RandomG2(vcs.rand, &vcs.H); err != nil {
+		return err
+	}
 
 	// Generate trapdoors
 	var frOne mcl.Fr
 	frOne.SetInt64(1)
 	for i := range vcs.trapdoors {
-		vcs.trapdoors[i].Random()
+		if err := RandomFr(vcs.rand, &vcs.trapdoors[i]); err != nil {
+			return err
+		}
 		mcl.FrSub(&vcs.trapdoorsSubOne[i], &frOne, &vcs.trapdoors[i])
 		mcl.FrSub(&vcs.trapdoorsSubOneRev[i], &vcs.trapdoors[i], &frOne)
 	}
@@ -135,37 +179,115 @@ func (vcs *VCS) TrapdoorsGen() {
 	}
 
 	// Generate alpha and beta for KZG
-	vcs.alpha.Random()
-	vcs.beta.Random()
+	if err := RandomFr(vcs.rand, &vcs.alpha); err != nil {
+		return err
+	}
+	if err := RandomFr(vcs.rand, &vcs.beta); err != nil {
+		return err
+	}
+
+	vcs.hasTrapdoors = true
+	return nil
+}
 
-	vcs.SaveTrapdoor()
+// Overwrite the trapdoors in memory.
+// This is best effort, as Go may have copied them elsewhere (e.g., on the stack).
+func (vcs *VCS) ZeroizeTrapdoors() {
+	for i := range vcs.trapdoors {
+		vcs.trapdoors[i].Clear()
+		vcs.trapdoorsSubOne[i].Clear()
+		vcs.trapdoorsSubOneRev[i].Clear()
+	}
+	vcs.alpha.Clear()
+	vcs.beta.Clear()
+	vcs.hasTrapdoors = false
+}
+
+func (vcs *VCS) HasTrapdoors() bool {
+	return vcs.hasTrapdoors
 }
 
 // Generates PRK VRK UPK etc
 // Use this only once to generate the parameters.
-func (vcs *VCS) KeyGen(ncores uint8, L uint8, folder string, txnLimit uint64) {
+func (vcs *VCS) KeyGen(ncores uint8, L uint8, folder string, txnLimit uint64) error {
+
+	vcs.setCores(ncores)
+	if err := vcs.Init(L, folder, txnLimit); err != nil {
+		return err
+	}
+	if err := vcs.ClearCheckpoint(); err != nil { // Shards of an earlier run use other trapdoors
+		return err
+	}
+	if err := vcs.TrapdoorsGen(); err != nil {
+		return err
+	}
+	return vcs.keyGenFromTrapdoors() // See KeyGenResume
+}
+
+// Same as KeyGen, but the trapdoors are never written to disk and are zeroized once the keys are generated.
+// Such a folder can only be used with KeyGenLoad, and not with KeyGenLoadFake.
+func (vcs *VCS) KeyGenNoTrapdoor(ncores uint8, L uint8, folder string, txnLimit uint64) error {
 
-	NCORES = ncores               // Maximum number threads created. Set this to number of available cores.
-	vcs.Init(L, folder, txnLimit) //
-	vcs.TrapdoorsGen()
-	vcs.PrkUpkGen()
-	vcs.GenAggGipa()
+	vcs.setCores(ncores)
+	if err := vcs.Init(L, folder, txnLimit); err != nil {
+		return err
+	}
+	if err := vcs.ClearCheckpoint(); err != nil {
+		return err
+	}
+	defer vcs.ZeroizeTrapdoors() // Also when a step fails
+	if err := vcs.TrapdoorsSample(); err != nil {
+		return err
+	}
+	for _, step := range []func() error{vcs.SavePublicParams, vcs.SaveVrk, vcs.PrkUpkGen, vcs.GenAggGipa} {
+		if err := step(); err != nil {
+			return err
+		}
+	}
+	vcs.ZeroizeTrapdoors()
+	if err := vcs.SaveManifest(); err != nil {
+		return err
+	}
+	return vcs.ClearCheckpoint() // Cannot be resumed without the trapdoors
 }
 
 // Defacto entry to VCS.
 // Use this to load the files always
-func (vcs *VCS) KeyGenLoad(ncores uint8, L uint8, folder string, txnLimit uint64) {
-	NCORES = ncores
-	vcs.Init(L, folder, txnLimit)
-	vcs.LoadTrapdoor(L)
-	vcs.PrkUpkLoad()
-	vcs.LoadAggGipa()
+// Only public parameters are loaded. Trapdoors are not needed to commit, open, update, verify and aggregate.
+// Errors are ErrBadEll, ErrBlockTooLarge and ErrKeyFolderCorrupt.
+func (vcs *VCS) KeyGenLoad(ncores uint8, L uint8, folder string, txnLimit uint64) error {
+	vcs.setCores(ncores)
+	if err := vcs.Init(L, folder, txnLimit); err != nil {
+		return err
+	}
+	if err := vcs.LoadManifest(); err != nil {
+		return err
+	}
+	if err := vcs.LoadParams(L); err != nil {
+		return err
+	}
+	if vcs.PARAM_TOO_LARGE {
+		if err := vcs.UpkMapDriver(); err != nil { // UPK tree does not fit in memory
+			return err
+		}
+		if !vcs.DISCARD_PRK {
+			if err := vcs.PrkMapDriver(); err != nil {
+				return err
+			}
+		}
+	} else if err := vcs.PrkUpkLoad(); err != nil {
+		return err
+	}
+	return vcs.LoadAggGipa()
 }
 
 // Do not remove L from the parameters. I am using it OpenAll
+// With the mapped UPK tree, level L is decoded for the call. See upkLevel. It panics if a point does not decode, CommitContext returns the error.
 func (vcs *VCS) Commit(a []mcl.Fr, L uint64) mcl.G1 {
 	var digest mcl.G1
-	mcl.G1MulVec(&digest, vcs.UPK[L], a) // Not L - 1 as L = 0 has just vcs.G
+	upk, err := vcs.upkLevel(uint8(L))
+	check(err)
+	mcl.G1MulVec(&digest, upk, a) // Not L - 1 as L = 0 has just vcs.G
 	return digest
 }
 
@@ -191,8 +313,11 @@ func (vcs *VCS) OpenAllRec(a []mcl.Fr, start uint64, end uint64, L uint8) {
 	vcs.OpenAllRec(a, mid, end, L-1)
 }
 
+// Proofs are not served while the tree is built. See OpenAllContext to keep serving the previous tree.
 func (vcs *VCS) OpenAll(a []mcl.Fr) {
 
+	vcs.treeMu.Lock()
+	defer vcs.treeMu.Unlock()
 	vcs.ProofTree = make([][]mcl.G1, vcs.L)
 	for i := uint8(0); i < vcs.L; i++ {
 		vcs.ProofTree[i] = make([]mcl.G1, 1<<i)
@@ -204,6 +329,14 @@ func (vcs *VCS) OpenAll(a []mcl.Fr) {
 // Index 0 has one 0-variables, index L - 1 has L-1 variable
 func (vcs *VCS) GetProofPath(index uint64) []mcl.G1 {
 
+	vcs.treeMu.RLock()
+	defer vcs.treeMu.RUnlock()
+	return vcs.proofPath(index)
+}
+
+// Call with the read lock held.
+func (vcs *VCS) proofPath(index uint64) []mcl.G1 {
+
 	proof := make([]mcl.G1, vcs.L)
 	id := index
 	for j := uint8(0); j < vcs.L; j++ {
@@ -219,47 +352,24 @@ type TreeGPS struct {
 	index uint64
 }
 
+// False for a malformed proof, i.e., not of length L, or an index out of range. See ProofPath.Verify.
 func (vcs *VCS) Verify(digest mcl.G1, index uint64, a_i mcl.Fr, proof []mcl.G1) bool {
-
-	if len(proof) != int(vcs.L) {
-		panic("Verify: Bad proof!")
-	}
-
-	// temp variables
-	var rhs mcl.GT
-	var p mcl.G1
-	var ps []mcl.G1
-	var qs []mcl.G2
-
-	ps = make([]mcl.G1, vcs.L+1)
-	qs = make([]mcl.G2, vcs.L+1)
-	binary := ToBinary(index, vcs.L)
-	for i := uint8(0); i < vcs.L; i++ {
-		if binary[i] {
-			qs[i] = vcs.VRKSubOneRev[i]
-		} else {
-			qs[i] = vcs.VRK[i]
-		}
-		ps[i] = proof[i]
-	}
-
-	// Move e(digest/g^{a_i}, h) to other side. Thus it will be e(g^{a_i}/digest, h)
-	mcl.G1Mul(&p, &vcs.G, &a_i)
-	mcl.G1Sub(&p, &p, &digest)
-	ps[vcs.L] = p
-	qs[vcs.L] = vcs.H
-
-	mcl.MillerLoopVec(&rhs, ps, qs)
-	mcl.FinalExp(&rhs, &rhs)
-	return rhs.IsOne()
+	return ProofPath{Index: index, L: vcs.L, Nodes: proof}.Verify(vcs, Digest(digest), a_i)
 }
 
+// Returns whether all the proofs verify and the number of Miller loops of the proofs, i.e., distinct nodes.
+// False, and no Miller loop, if any proof is malformed. See Verify.
 func (vcs *VCS) VerifyMemoized(digest mcl.G1, indexVec []uint64, a_i []mcl.Fr, proofVec [][]mcl.G1) (bool, int) {
 
 	// fmt.Println(vcs.VRKSubOneRev[i].IsEqual(&qs[i]), vcs.VRKSubOneRev[i].IsZero())
 
-	if len(proofVec) != len(indexVec) {
-		panic("Verify: Bad proof!")
+	if len(proofVec) != len(indexVec) || len(a_i) != len(indexVec) {
+		return false, 0
+	}
+	for t := range proofVec {
+		if len(proofVec[t]) != int(vcs.L) || indexVec[t] >= vcs.N {
+			return false, 0
+		}
 	}
 
 	var p mcl.G1   // temp variables
@@ -310,46 +420,39 @@ func (vcs *VCS) VerifyMemoized(digest mcl.G1, indexVec []uint64, a_i []mcl.Fr, p
 	return status, len(db)
 }
 
-func (vcs *VCS) UpdateCom(digest mcl.G1, updateindex uint64, delta mcl.Fr) mcl.G1 {
-	var temp mcl.G1
-	var result mcl.G1
-	mcl.G1Mul(&temp, &vcs.UPK[vcs.L][updateindex], &delta)
-	mcl.G1Add(&result, &digest, &temp)
-	return result
+func (vcs *VCS) UpdateCom(digest mcl.G1, updateindex uint64, delta mcl.Fr) (mcl.G1, error) {
+	return vcs.UpdateComVec(digest, []uint64{updateindex}, []mcl.Fr{delta})
 }
 
-func (vcs *VCS) UpdateComVec(digest mcl.G1, updateindex []uint64, delta []mcl.Fr) mcl.G1 {
-	N := len(updateindex)
-	// if N != len(delta) {
-	// 	fmt.Print("UpdateComVec: Error")
-	// }
-	var result mcl.G1
-	var temp mcl.G1
-	upks := make([]mcl.G1, N)
-	for i := 0; i < N; i++ {
-		upks[i] = vcs.UPK[vcs.L][updateindex[i]]
-	}
+// Returns the errors of Digest.Update, and digest as is.
+func (vcs *VCS) UpdateComVec(digest mcl.G1, updateindex []uint64, delta []mcl.Fr) (mcl.G1, error) {
+	result, err := Digest(digest).Update(vcs, UpdateBatch{Indices: updateindex, Deltas: delta})
+	return mcl.G1(result), err
+}
 
-	mcl.G1MulVec(&temp, upks, delta)
-	mcl.G1Add(&result, &digest, &temp)
-	return result
+// Returns the errors of ProofPath.Update, e.g., for a malformed proof or updateindex.
+func (vcs *VCS) UpdateProof(proof []mcl.G1, localindex uint64, updateindex uint64, delta mcl.Fr) ([]mcl.G1, error) {
+	result, err := ProofPath{Index: localindex, L: vcs.L, Nodes: proof}.Update(vcs, UpdateBatch{Indices: []uint64{updateindex}, Deltas: []mcl.Fr{delta}})
+	if err != nil {
+		return nil, err
+	}
+	return result.Nodes, nil
 }
 
-func (vcs *VCS) UpdateProof(proof []mcl.G1, localindex uint64, updateindex uint64, delta mcl.Fr) []mcl.G1 {
+// upk is the UPK path of updateindex, as returned by GetUpk.
+func updateProof(G *mcl.G1, upk []mcl.G1, ell uint8, proof []mcl.G1, localindex uint64, updateindex uint64, delta mcl.Fr) []mcl.G1 {
 
 	newProof := make([]mcl.G1, len(proof))
 	copy(newProof, proof)
 	var temp mcl.G1
-	updateindexBinary := ToBinary(updateindex, vcs.L) // LSB first
-	localindexBinary := ToBinary(localindex, vcs.L)   // LSB first
-	// upk := vcs.UPK[updateindex]
-	upk := vcs.GetUpk(updateindex)
-	L := int(vcs.L)
+	updateindexBinary := ToBinary(updateindex, ell) // LSB first
+	localindexBinary := ToBinary(localindex, ell)   // LSB first
+	L := int(ell)
 	for i := L; i > 0; i-- {
 		if i-1 > 0 {
 			mcl.G1Mul(&temp, &upk[i-2], &delta)
 		} else {
-			mcl.G1Mul(&temp, &vcs.G, &delta)
+			mcl.G1Mul(&temp, G, &delta)
 		}
 		if updateindexBinary[i-1] == false && localindexBinary[i-1] == true {
 			mcl.G1Sub(&newProof[i-1], &proof[i-1], &temp)
@@ -366,9 +469,9 @@ func (vcs *VCS) UpdateProof(proof []mcl.G1, localindex uint64, updateindex uint6
 	return newProof
 }
 
+// The change of each node is computed first, and then added under the write lock. Thus, no proof mixes the old and new nodes.
 func (vcs *VCS) UpdateProofTree(updateindex uint64, delta mcl.Fr) {
 
-	var q_i mcl.G1
 	updateindexBinary := ToBinary(updateindex, vcs.L)       // LSB first
 	updateindexBinary = ReverseSliceBool(updateindexBinary) // MSB first
 
@@ -377,28 +480,39 @@ func (vcs *VCS) UpdateProofTree(updateindex uint64, delta mcl.Fr) {
 
 	L := int(vcs.L)
 	Y := FindTreeGPS(updateindex, L)
-
-	var x, upk_i int // These will serve as GPS in the tree
-	var y uint64     // These will serve as GPS in the tree
+	q := make([]mcl.G1, L)
 
 	// Start from the top of the prooftree (which implies start from the bottom of the UPK tree)
 	for i := 0; i < L; i++ {
-		x = i
-		y = Y[i]
-		upk_i = L - i - 1
-		mcl.G1Mul(&q_i, &upk[upk_i], &delta)
-
-		if updateindexBinary[x] {
-			mcl.G1Add(&vcs.ProofTree[x][y], &vcs.ProofTree[x][y], &q_i)
-		} else {
-			mcl.G1Sub(&vcs.ProofTree[x][y], &vcs.ProofTree[x][y], &q_i)
+		mcl.G1Mul(&q[i], &upk[L-i-1], &delta)
+		if !updateindexBinary[i] {
+			mcl.G1Neg(&q[i], &q[i])
 		}
 	}
+
+	vcs.treeMu.Lock()
+	defer vcs.treeMu.Unlock()
+	for i := 0; i < L; i++ {
+		mcl.G1Add(&vcs.ProofTree[i][Y[i]], &vcs.ProofTree[i][Y[i]], &q[i])
+	}
 }
 
+// Returns the number of distinct nodes updated. See UpdateProofTree for the locking.
+// Panics as GetUpk. ProofServer.ApplyBlock returns the error instead.
 func (vcs *VCS) UpdateProofTreeBulk(updateindexVec []uint64, deltaVec []mcl.Fr) int {
 
-	// ProofTree[][]
+	changes, err := vcs.proofTreeChanges(updateindexVec, deltaVec)
+	check(err)
+	vcs.treeMu.Lock()
+	defer vcs.treeMu.Unlock()
+	vcs.applyProofTreeChanges(changes)
+	return len(changes)
+}
+
+// Change of each node of the proof tree affected by the updates. The tree itself is not read.
+// Returns the error of getUpk, e.g., for a mapped point that does not decode.
+func (vcs *VCS) proofTreeChanges(updateindexVec []uint64, deltaVec []mcl.Fr) (map[TreeGPS]mcl.G1, error) {
+
 	var q_i mcl.G1
 
 	var x, upk_i uint8 // These will serve as GPS in the tree
@@ -414,7 +528,10 @@ func (vcs *VCS) UpdateProofTreeBulk(updateindexVec []uint64, deltaVec []mcl.Fr)
 		updateindexBinary := ToBinary(updateindex, vcs.L)       // LSB first
 		updateindexBinary = ReverseSliceBool(updateindexBinary) // MSB first
 
-		upk := vcs.GetUpk(updateindex)                     // Pop upk_{u,l} as it containts ell variables.
+		upk, err := vcs.getUpk(updateindex) // Pop upk_{u,l} as it containts ell variables.
+		if err != nil {
+			return nil, err
+		}
 		upk = append([]mcl.G1{vcs.G}, upk[:len(upk)-1]...) // Since the root of the proof tree contains only ell - 1 variables, we need to pop the upk.
 
 		L := vcs.L
@@ -436,23 +553,40 @@ func (vcs *VCS) UpdateProofTreeBulk(updateindexVec []uint64, deltaVec []mcl.Fr)
 		}
 	}
 
+	changes := make(map[TreeGPS]mcl.G1, len(g1Db))
 	for key := range g1Db {
-		A := g1Db[key]
-		B := frDb[key]
-		mcl.G1MulVec(&q_i, A, B)
-		mcl.G1Add(&vcs.ProofTree[key.level][key.index], &vcs.ProofTree[key.level][key.index], &q_i)
+		mcl.G1MulVec(&q_i, g1Db[key], frDb[key])
+		changes[key] = q_i
+	}
+	return changes, nil
+}
+
+// Call with the write lock held.
+func (vcs *VCS) applyProofTreeChanges(changes map[TreeGPS]mcl.G1) {
+	for key, q := range changes {
+		mcl.G1Add(&vcs.ProofTree[key.level][key.index], &vcs.ProofTree[key.level][key.index], &q)
 	}
-	return len(g1Db)
 }
 
 // g^{(1-s_1)}, g^{(1-s_2)(1-s_1)}, g^{(1-s_3)(1-s_2)(1-s_1)}
+// Panics if a mapped point does not decode. See getUpk for the error.
 func (vcs *VCS) GetUpk(i uint64) []mcl.G1 {
+	upk, err := vcs.getUpk(i)
+	check(err)
+	return upk
+}
+
+// Same as GetUpk, with the error of upkNode instead of a panic.
+func (vcs *VCS) getUpk(i uint64) ([]mcl.G1, error) {
 
 	k := i
 	upk := make([]mcl.G1, vcs.L)
 	for j := uint8(vcs.L); j > 0; j-- {
 		k = k & (^(1 << j)) // Clears the jth bit of k. Technically everything before jth and before has to be cleared.
-		upk[j-1] = vcs.UPK[j][k]
+		var err error
+		if upk[j-1], err = vcs.upkNode(j, k); err != nil {
+			return nil, err
+		}
 	}
-	return upk
+	return upk, nil
 }
@@ -2,7 +2,7 @@ package vcs
 
 import (
 	"fmt"
-	"math/rand"
+	"os"
 	"testing"
 
 	"github.com/alinush/go-mcl"
@@ -12,10 +12,10 @@ import (
 // Basic unit test cases for testing VCS functionality.
 func TestVCS(t *testing.T) {
 
-	// This method assumes that VRK, UPK are already computed and saved on disk.
-	// Be sure to run the run vcs.KeyGen if PRK, VRK, UPK keys are not computed.
+	// Keys are generated in memory. See vcs-inmemory.go
 
 	mcl.InitFromString("bls12-381")
+	r := testReader(t)
 	fmt.Println("Curve order", mcl.GetCurveOrder())
 	// Get K random positions in the tree
 	var L uint8
@@ -27,8 +27,8 @@ func TestVCS(t *testing.T) {
 		L = ell[loop]
 		N := uint64(1) << L
 
-		vcs := VCS{}
-		vcs.KeyGenLoad(16, L, "../pkvk-17", txnLimit)
+		vcs, err := NewVCSInMemory(16, L, txnLimit)
+		check(err)
 
 		indexVec := make([]uint64, K)   // List of indices that chanaged (there can be duplicates.)
 		proofVec := make([][]mcl.G1, K) // Proofs of the changed indices.
@@ -39,14 +39,16 @@ func TestVCS(t *testing.T) {
 		var status bool
 
 		{
-			aFr := GenerateVector(N)
+			aFr, err := GenerateVectorFrom(N, r)
+			check(err)
 			digest = vcs.Commit(aFr, uint64(L))
 			vcs.OpenAll(aFr)
 
 			for k := 0; k < K; k++ {
-				indexVec[k] = uint64(rand.Intn(int(N))) // Can contain duplicates
+				indexVec[k], err = RandomIndex(r, N) // Can contain duplicates
+				check(err)
 				proofVec[k] = vcs.GetProofPath(indexVec[k])
-				deltaVec[k].Random()
+				check(RandomFr(r, &deltaVec[k]))
 				valueVec[k] = aFr[indexVec[k]]
 			}
 		}
@@ -88,7 +90,8 @@ func TestVCS(t *testing.T) {
 			proofVec[k] = vcs.GetProofPath(indexVec[k])
 		}
 
-		digest = vcs.UpdateComVec(digest, indexVec, deltaVec)
+		digest, err = vcs.UpdateComVec(digest, indexVec, deltaVec)
+		check(err)
 
 		t.Run(fmt.Sprintf("%d/UpdateProofTree;", L), func(t *testing.T) {
 			status = true
@@ -107,7 +110,8 @@ func TestVCS(t *testing.T) {
 		for k := 0; k < K; k++ {
 			proofVec[k] = vcs.GetProofPath(indexVec[k])
 		}
-		digest = vcs.UpdateComVec(digest, indexVec, deltaVec)
+		digest, err = vcs.UpdateComVec(digest, indexVec, deltaVec)
+		check(err)
 
 		t.Run(fmt.Sprintf("%d/UpdateProofTreeBulk;", L), func(t *testing.T) {
 			status = true
@@ -118,11 +122,16 @@ func TestVCS(t *testing.T) {
 		})
 
 		var aggProof batch.Proof
-		aggProof = vcs.AggProve(indexVec, proofVec)
+		aggProof, err = vcs.AggProve(indexVec, proofVec)
+		check(err)
 
 		t.Run(fmt.Sprintf("%d/AggregateVerify;%d", L, txnLimit), func(t *testing.T) {
 
-			status = status && vcs.AggVerify(aggProof, digest, indexVec, valueVec)
+			ok, err := vcs.AggVerify(aggProof, digest, indexVec, valueVec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			status = status && ok
 			if status == false {
 				t.Errorf("Aggregation failed")
 			}
@@ -134,7 +143,8 @@ func TestVCS(t *testing.T) {
 		for k := 0; k < K; k++ {
 			proofVec[k] = vcs.GetProofPath(indexVec[k])
 		}
-		digest = vcs.UpdateComVec(digest, indexVec, deltaVec)
+		digest, err = vcs.UpdateComVec(digest, indexVec, deltaVec)
+		check(err)
 
 		var aggIndex []uint64
 		var aggProofIndv [][]mcl.G1
@@ -145,16 +155,22 @@ func TestVCS(t *testing.T) {
 		aggValue = make([]mcl.Fr, txnLimit)
 
 		for j := uint64(0); j < txnLimit; j++ {
-			id := uint64(rand.Intn(int(K))) // Pick an index from the saved list of vector positions
+			id, err := RandomIndex(r, uint64(K)) // Pick an index from the saved list of vector positions
+			check(err)
 			aggIndex[j] = indexVec[id]
 			aggProofIndv[j] = proofVec[id]
 			aggValue[j] = valueVec[id]
 		}
 
-		aggProof = vcs.AggProve(aggIndex, aggProofIndv)
+		aggProof, err = vcs.AggProve(aggIndex, aggProofIndv)
+		check(err)
 		t.Run(fmt.Sprintf("%d/AggregateVerify2;%d", L, txnLimit), func(t *testing.T) {
 
-			status = status && vcs.AggVerify(aggProof, digest, aggIndex, aggValue)
+			ok, err := vcs.AggVerify(aggProof, digest, aggIndex, aggValue)
+			if err != nil {
+				t.Fatal(err)
+			}
+			status = status && ok
 			if status == false {
 				t.Errorf("Aggregation#2 failed")
 			}
@@ -196,3 +212,74 @@ func SecondaryStateUpdate(indexVec []uint64, deltaVec []mcl.Fr, valueVec []mcl.F
 
 	return valueVec
 }
+
+// Keys generated without a trapdoor file are enough for the public API.
+func TestVCSNoTrapdoor(t *testing.T) {
+
+	mcl.InitFromString("bls12-381")
+	r := testReader(t)
+
+	L := uint8(8)
+	N := uint64(1) << L
+	K := 16
+	folder := t.TempDir()
+
+	{
+		vcs := VCS{}
+		check(vcs.KeyGenNoTrapdoor(16, L, folder, uint64(K)))
+		if vcs.HasTrapdoors() {
+			t.Errorf("Trapdoors are still in memory after KeyGenNoTrapdoor")
+		}
+	}
+
+	if _, err := os.Stat(folder + TRAPDOORNAME); err == nil {
+		t.Fatalf("KeyGenNoTrapdoor wrote a trapdoor file")
+	}
+
+	vcs := VCS{}
+	check(vcs.KeyGenLoad(16, L, folder, uint64(K)))
+	if vcs.HasTrapdoors() {
+		t.Errorf("KeyGenLoad loaded the trapdoors")
+	}
+
+	aFr, err := GenerateVectorFrom(N, r)
+	check(err)
+	digest := vcs.Commit(aFr, uint64(L))
+	vcs.OpenAll(aFr)
+
+	indexVec := make([]uint64, K)
+	proofVec := make([][]mcl.G1, K)
+	deltaVec := make([]mcl.Fr, K)
+	valueVec := make([]mcl.Fr, K)
+	for k := 0; k < K; k++ {
+		indexVec[k], err = RandomIndex(r, N)
+		check(err)
+		check(RandomFr(r, &deltaVec[k]))
+		valueVec[k] = aFr[indexVec[k]]
+	}
+
+	vcs.UpdateProofTreeBulk(indexVec, deltaVec)
+	valueVec = SecondaryStateUpdate(indexVec, deltaVec, valueVec)
+	digest, err = vcs.UpdateComVec(digest, indexVec, deltaVec)
+	check(err)
+	for k := 0; k < K; k++ {
+		proofVec[k] = vcs.GetProofPath(indexVec[k])
+	}
+
+	t.Run(fmt.Sprintf("%d/VerifyMemoized;%d", L, K), func(t *testing.T) {
+		status, _ := vcs.VerifyMemoized(digest, indexVec, valueVec, proofVec)
+		if !status {
+			t.Errorf("Verification without trapdoors failed")
+		}
+	})
+
+	t.Run(fmt.Sprintf("%d/AggregateVerify;%d", L, K), func(t *testing.T) {
+		aggProof, err := vcs.AggProve(indexVec, proofVec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok, err := vcs.AggVerify(aggProof, digest, indexVec, valueVec); err != nil || !ok {
+			t.Errorf("Aggregation without trapdoors failed")
+		}
+	})
+}
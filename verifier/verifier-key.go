@@ -0,0 +1,131 @@
+// Reader of the verifier key written by vcs.VerifierKey.Write. Nothing else is needed to verify.
+// Layout: magic, version (u32), ell (u64), TxnLimit (u64), then G, H, and VRK[i] and VRKSubOneRev[i] for each i.
+// Unless TxnLimit is 0, then the MN points of W, and KZG1VK[i] and KZG2VK[i] for i = 0, 1. Integers are little endian.
+package verifier
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+
+	"github.com/alinush/go-mcl"
+	"github.com/hyperproofs/gipa-go/utils"
+	"github.com/hyperproofs/kzg-go/kzg"
+)
+
+const KEY_MAGIC = "HYPERVER" // Same as vcs.VERIFIER_KEY_MAGIC
+const KEY_VERSION = 1
+const MAX_AGG_SIZE = 1 << 19 // Same as vcs.MAX_AGG_SIZE
+const G1_BYTES = 48          // Same as vcs.GetG1ByteSize
+const G2_BYTES = 96
+
+var ErrBadKey = errors.New("verifier: bad verifier key")
+var ErrBadBlockSize = errors.New("verifier: wrong number of updates")
+
+// Read the key from the file at path. See Read.
+func Load(path string) (*Verifier, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Read(f)
+}
+
+// Read the key from r. Returns ErrBadKey if it is malformed or truncated.
+func Read(r io.Reader) (*Verifier, error) {
+
+	br := bufio.NewReader(r)
+	header := make([]byte, len(KEY_MAGIC)+20)
+	if _, err := io.ReadFull(br, header); err != nil || string(header[:len(KEY_MAGIC)]) != KEY_MAGIC {
+		return nil, fmt.Errorf("%w: Not a verifier key", ErrBadKey)
+	}
+	if version := binary.LittleEndian.Uint32(header[len(KEY_MAGIC):]); version > KEY_VERSION {
+		return nil, fmt.Errorf("%w: Unsupported version %d. Supports up to %d", ErrBadKey, version, KEY_VERSION)
+	}
+	L := binary.LittleEndian.Uint64(header[len(KEY_MAGIC)+4:])
+	txnLimit := binary.LittleEndian.Uint64(header[len(KEY_MAGIC)+12:])
+	if L == 0 || L >= 32 || txnLimit > MAX_AGG_SIZE/L {
+		return nil, fmt.Errorf("%w: ell = %d and %d updates are out of range", ErrBadKey, L, txnLimit)
+	}
+
+	v := Verifier{L: uint8(L), N: uint64(1) << L, TxnLimit: txnLimit}
+	v.VRK = make([]mcl.G2, L)
+	v.VRKSubOne = make([]mcl.G2, L)
+	v.VRKSubOneRev = make([]mcl.G2, L)
+
+	d := decoder{r: br}
+	d.g1(&v.G)
+	d.g2(&v.H)
+	for i := range v.VRK {
+		d.g2(&v.VRK[i])
+		d.g2(&v.VRKSubOneRev[i])
+		mcl.G2Sub(&v.VRKSubOne[i], &v.H, &v.VRK[i])
+	}
+	if txnLimit != 0 {
+		v.setAggSizes()
+		v.W = make([]mcl.G1, v.MN)
+		for i := range v.W {
+			d.g1(&v.W[i])
+		}
+		vk1 := make([]mcl.G2, 2)
+		vk2 := make([]mcl.G1, 2)
+		for i := range vk1 {
+			d.g2(&vk1[i])
+			d.g1(&vk2[i])
+		}
+		// Only the first KZG prover key, i.e., the generator, is read when verifying. See AggVerify.
+		v.kzg1 = kzg.NewKZG1Settings([]mcl.G1{v.G}, vk1)
+		v.kzg2 = kzg.NewKZG2Settings([]mcl.G2{v.H}, vk2)
+	}
+	if d.err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrBadKey, d.err)
+	}
+	return &v, nil
+}
+
+// MN and the padding of the GIPA instance, as in vcs.
+func (v *Verifier) setAggSizes() {
+	L := uint64(v.L)
+	v.MN = utils.NextPowOf2(L * v.TxnLimit)
+	v.nDiff = int64(uint64(math.Ceil(float64(v.MN)/float64(L))) - v.TxnLimit)
+	v.mnDiff = int64(v.MN - L*v.TxnLimit)
+}
+
+// Decodes points one after the other. The first error is kept and the rest are skipped.
+type decoder struct {
+	r   io.Reader
+	err error
+	buf []byte
+}
+
+func (d *decoder) next(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if cap(d.buf) < n {
+		d.buf = make([]byte, n)
+	}
+	d.buf = d.buf[:n]
+	if _, err := io.ReadFull(d.r, d.buf); err != nil {
+		d.err = err
+		return nil
+	}
+	return d.buf
+}
+
+func (d *decoder) g1(p *mcl.G1) {
+	if data := d.next(G1_BYTES); data != nil {
+		d.err = p.Deserialize(data)
+	}
+}
+
+func (d *decoder) g2(p *mcl.G2) {
+	if data := d.next(G2_BYTES); data != nil {
+		d.err = p.Deserialize(data)
+	}
+}
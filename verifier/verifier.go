@@ -0,0 +1,214 @@
+// Package verifier checks Hyperproofs with the verifier key alone, i.e., without the vcs package and its key folder.
+// It holds G, H, VRK and, for aggregated proofs, the GIPA verifier keys. Light clients keep their digest up to date
+// with the UPK leaves of the updated indices, e.g., from a vcs.UpdateKey, checked once with VerifyUpk.
+// Proofs are in the order of vcs.GetProofPath. mcl must be initialised first, i.e., mcl.InitFromString("bls12-381").
+package verifier
+
+import (
+	"fmt"
+	"math/bits"
+
+	"github.com/alinush/go-mcl"
+	"github.com/hyperproofs/gipa-go/batch"
+	"github.com/hyperproofs/gipa-go/gipakzg"
+	"github.com/hyperproofs/kzg-go/kzg"
+)
+
+type Verifier struct {
+	L            uint8
+	N            uint64
+	TxnLimit     uint64 // 0 if the key has no aggregation keys
+	G            mcl.G1
+	H            mcl.G2
+	VRK          []mcl.G2
+	VRKSubOne    []mcl.G2 // H - VRK
+	VRKSubOneRev []mcl.G2
+
+	MN     uint64
+	nDiff  int64
+	mnDiff int64
+	W      []mcl.G1
+	kzg1   *kzg.KZG1Settings
+	kzg2   *kzg.KZG2Settings
+}
+
+// Same as vcs.Verify. False for a malformed proof, i.e., not of length L, or an index out of range.
+func (v *Verifier) Verify(digest mcl.G1, index uint64, a_i mcl.Fr, proof []mcl.G1) bool {
+
+	if len(proof) != int(v.L) || index >= v.N {
+		return false
+	}
+
+	var rhs mcl.GT
+	var p mcl.G1
+	ps := make([]mcl.G1, v.L+1)
+	qs := make([]mcl.G2, v.L+1)
+	for i := uint8(0); i < v.L; i++ {
+		qs[i] = v.vrk(index, i)
+		ps[i] = proof[i]
+	}
+
+	// e(g^{a_i}/digest, h)
+	mcl.G1Mul(&p, &v.G, &a_i)
+	mcl.G1Sub(&p, &p, &digest)
+	ps[v.L] = p
+	qs[v.L] = v.H
+
+	mcl.MillerLoopVec(&rhs, ps, qs)
+	mcl.FinalExp(&rhs, &rhs)
+	return rhs.IsOne()
+}
+
+// Same as vcs.VerifyMemoized. Returns whether all the proofs verify and the number of Miller loops, i.e., distinct nodes.
+func (v *Verifier) VerifyMemoized(digest mcl.G1, indexVec []uint64, a_i []mcl.Fr, proofVec [][]mcl.G1) (bool, int) {
+
+	if len(proofVec) != len(indexVec) || len(a_i) != len(indexVec) {
+		return false, 0
+	}
+	for t := range proofVec {
+		if len(proofVec[t]) != int(v.L) || indexVec[t] >= v.N {
+			return false, 0
+		}
+	}
+
+	type node struct {
+		level uint8
+		index uint64
+	}
+	var p mcl.G1
+	var lhs, prod mcl.GT
+	db := make(map[node]mcl.GT)
+	status := true
+	for t := range proofVec {
+		prod.SetInt64(1)
+		index := indexVec[t]
+		for i := v.L; i > 0; i-- {
+			loc := node{i, index}
+			result, ok := db[loc]
+			if !ok {
+				q := v.vrk(indexVec[t], v.L-i)
+				mcl.MillerLoop(&result, &proofVec[t][v.L-i], &q)
+				db[loc] = result
+			}
+			mcl.GTMul(&prod, &prod, &result)
+			index = index >> 1
+		}
+
+		mcl.G1Mul(&p, &v.G, &a_i[t])
+		mcl.G1Sub(&p, &p, &digest)
+		mcl.MillerLoop(&lhs, &p, &v.H)
+		mcl.GTMul(&prod, &prod, &lhs)
+		mcl.FinalExp(&prod, &prod)
+		status = status && prod.IsOne()
+	}
+	return status, len(db)
+}
+
+// Same as vcs.AggVerify. Returns ErrBadBlockSize if the key has no aggregation keys or the vectors do not hold TxnLimit updates.
+func (v *Verifier) AggVerify(proof batch.Proof, digest mcl.G1, indexVec []uint64, a_i []mcl.Fr) (bool, error) {
+
+	txnLimit := int(v.TxnLimit)
+	if txnLimit == 0 {
+		return false, fmt.Errorf("%w: The key has no aggregation keys", ErrBadBlockSize)
+	}
+	if len(indexVec) != txnLimit || len(a_i) != txnLimit {
+		return false, fmt.Errorf("%w: Got %d indices and %d values, expected %d", ErrBadBlockSize, len(indexVec), len(a_i), txnLimit)
+	}
+	rounds := bits.TrailingZeros64(v.MN) // MN is a power of 2
+	if len(proof.GipaKzgProof.L) != rounds || len(proof.GipaKzgProof.R) != rounds {
+		return false, nil
+	}
+	for t := range indexVec {
+		if indexVec[t] >= v.N {
+			return false, nil
+		}
+	}
+
+	P := make([]mcl.G1, txnLimit+int(v.nDiff))
+	Q := make([]mcl.G2, txnLimit+int(v.nDiff))
+	for t := range a_i {
+		mcl.G1Mul(&P[t], &v.G, &a_i[t])
+		mcl.G1Sub(&P[t], &digest, &P[t])
+		Q[t] = v.H
+	}
+	B := make([]mcl.G2, v.MN) // Padded with mnDiff zeros
+	for t := range indexVec {
+		for i := uint8(0); i < v.L; i++ {
+			B[t*int(v.L)+int(i)] = v.vrk(indexVec[t], i)
+		}
+	}
+
+	// Not Init, which requires the 2MN - 1 KZG prover keys although only the generator is read.
+	verifier := batch.Verifier{
+		Verifier: gipakzg.Verifier{M: v.MN, KZG1: *v.kzg1, KZG2: *v.kzg2},
+		W:        v.W,
+		N:        uint32(v.TxnLimit + uint64(v.nDiff)),
+		M:        uint32(v.L),
+		MN:       v.MN,
+		B:        B,
+		P:        P,
+		Q:        Q,
+	}
+	return verifier.VerifyEdrax(proof), nil
+}
+
+// Check the UPK path of index, in the format of vcs.GetUpk, against VRK. The leaf is path[L-1].
+// Each node is its parent raised to s or (1 - s), the parent of level 1 being G.
+func (v *Verifier) VerifyUpk(index uint64, path []mcl.G1) bool {
+
+	if len(path) != int(v.L) || index >= v.N {
+		return false
+	}
+
+	// e(node, H) = e(parent, VRK or VRKSubOne) for each level, with random coefficients.
+	var r mcl.Fr
+	var temp, sum mcl.G1
+	ps := make([]mcl.G1, v.L)
+	qs := make([]mcl.G2, v.L)
+	parent := v.G
+	for l := uint8(1); l <= v.L; l++ {
+		if index&(uint64(1)<<(l-1)) == 0 {
+			qs[l-1] = v.VRKSubOne[l-1]
+		} else {
+			qs[l-1] = v.VRK[l-1]
+		}
+		r.Random()
+		mcl.G1Mul(&ps[l-1], &parent, &r)
+		mcl.G1Mul(&temp, &path[l-1], &r)
+		mcl.G1Add(&sum, &sum, &temp)
+		parent = path[l-1]
+	}
+
+	var lhs, rhs mcl.GT
+	mcl.Pairing(&rhs, &sum, &v.H)
+	mcl.MillerLoopVec(&lhs, ps, qs)
+	mcl.FinalExp(&lhs, &lhs)
+	return lhs.IsEqual(&rhs)
+}
+
+// Digest after adding delta to the value whose UPK leaf is leaf.
+func (v *Verifier) UpdateCom(digest mcl.G1, leaf mcl.G1, delta mcl.Fr) mcl.G1 {
+	var temp, result mcl.G1
+	mcl.G1Mul(&temp, &leaf, &delta)
+	mcl.G1Add(&result, &digest, &temp)
+	return result
+}
+
+// Digest after adding delta[t] to the value whose UPK leaf is leaves[t]. Returns ErrBadBlockSize if the lengths differ.
+func (v *Verifier) UpdateComVec(digest mcl.G1, leaves []mcl.G1, delta []mcl.Fr) (mcl.G1, error) {
+	if len(leaves) != len(delta) {
+		return digest, fmt.Errorf("%w: Got %d leaves and %d values", ErrBadBlockSize, len(leaves), len(delta))
+	}
+	var temp, result mcl.G1
+	mcl.G1MulVec(&temp, leaves, delta)
+	mcl.G1Add(&result, &digest, &temp)
+	return result, nil
+}
+
+// VRK paired with proof[i] of index.
+func (v *Verifier) vrk(index uint64, i uint8) mcl.G2 {
+	if (index>>i)&1 == 1 {
+		return v.VRKSubOneRev[i]
+	}
+	return v.VRK[i]
+}
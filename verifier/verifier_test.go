@@ -0,0 +1,128 @@
+package verifier
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/alinush/go-mcl"
+	"github.com/hyperproofs/gipa-go/batch"
+	"github.com/hyperproofs/hyperproofs-go/vcs"
+)
+
+// The verifier agrees with vcs on proofs from an instance it only knows through its verifier key.
+func TestVerifier(t *testing.T) {
+
+	mcl.InitFromString("bls12-381")
+
+	L := uint8(6)
+	txnLimit := uint64(4)
+	keys, err := vcs.NewVCSInMemory(16, L, txnLimit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	aFr := vcs.GenerateVector(keys.N)
+	keys.OpenAll(aFr)
+	digest := keys.Commit(aFr, uint64(L))
+
+	indexVec := make([]uint64, txnLimit)
+	valueVec := make([]mcl.Fr, txnLimit)
+	proofVec := make([][]mcl.G1, txnLimit)
+	for k := range indexVec {
+		indexVec[k] = uint64(k) * 13 % keys.N
+		valueVec[k] = aFr[indexVec[k]]
+		proofVec[k] = keys.GetProofPath(indexVec[k])
+	}
+
+	var buf bytes.Buffer
+	if err := keys.VerifierKey(true).Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	v, err := Read(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run(fmt.Sprintf("%d/Verify;%d", L, txnLimit), func(t *testing.T) {
+		for k := range indexVec {
+			if !v.Verify(digest, indexVec[k], valueVec[k], proofVec[k]) {
+				t.Errorf("Proof %d failed to verify", k)
+			}
+		}
+		if v.Verify(digest, indexVec[0]^1, valueVec[0], proofVec[0]) {
+			t.Errorf("Proof verified for the wrong index")
+		}
+		status, loops := v.VerifyMemoized(digest, indexVec, valueVec, proofVec)
+		expectedStatus, expectedLoops := keys.VerifyMemoized(digest, indexVec, valueVec, proofVec)
+		if !status || status != expectedStatus || loops != expectedLoops {
+			t.Errorf("VerifyMemoized does not match vcs: %v, %d", status, loops)
+		}
+	})
+
+	t.Run(fmt.Sprintf("%d/AggVerify;%d", L, txnLimit), func(t *testing.T) {
+		aggProof, err := keys.AggProve(indexVec, proofVec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if status, err := v.AggVerify(aggProof, digest, indexVec, valueVec); !status || err != nil {
+			t.Errorf("Aggregated proof failed to verify: %v", err)
+		}
+		if status, _ := v.AggVerify(aggProof, digest, indexVec, vcs.GenerateVector(txnLimit)); status {
+			t.Errorf("Aggregated proof verified for the wrong values")
+		}
+		if _, err := v.AggVerify(aggProof, digest, indexVec[1:], valueVec[1:]); !errors.Is(err, ErrBadBlockSize) {
+			t.Errorf("Expected ErrBadBlockSize, got %v", err)
+		}
+	})
+
+	t.Run(fmt.Sprintf("%d/UpdateCom;%d", L, txnLimit), func(t *testing.T) {
+		leaves := make([]mcl.G1, txnLimit)
+		for k := range indexVec {
+			path := keys.GetUpk(indexVec[k])
+			if !v.VerifyUpk(indexVec[k], path) {
+				t.Fatalf("UPK path of %d failed to verify", indexVec[k])
+			}
+			if v.VerifyUpk(indexVec[k]^1, path) {
+				t.Errorf("UPK path verified for the wrong index")
+			}
+			leaves[k] = path[L-1]
+		}
+		deltaVec := vcs.GenerateVector(txnLimit)
+		updated, err := v.UpdateComVec(digest, leaves, deltaVec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected, err := keys.UpdateComVec(digest, indexVec, deltaVec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !updated.IsEqual(&expected) {
+			t.Errorf("Digest mismatch")
+		}
+		single := v.UpdateCom(digest, leaves[0], deltaVec[0])
+		if expected, err = keys.UpdateCom(digest, indexVec[0], deltaVec[0]); err != nil {
+			t.Fatal(err)
+		}
+		if !single.IsEqual(&expected) {
+			t.Errorf("Digest mismatch")
+		}
+	})
+
+	t.Run(fmt.Sprintf("%d/BadKey;%d", L, txnLimit), func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := keys.VerifierKey(false).Write(&buf); err != nil {
+			t.Fatal(err)
+		}
+		small, err := Read(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := small.AggVerify(batch.Proof{}, digest, indexVec, valueVec); !errors.Is(err, ErrBadBlockSize) {
+			t.Errorf("Expected ErrBadBlockSize, got %v", err)
+		}
+		if _, err := Read(bytes.NewReader(buf.Bytes()[:buf.Len()-1])); !errors.Is(err, ErrBadKey) {
+			t.Errorf("Expected ErrBadKey, got %v", err)
+		}
+	})
+}